@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/Spear5030/yagophkeeper/internal/client/app"
+	"github.com/Spear5030/yagophkeeper/internal/client/clipboard"
 	"github.com/Spear5030/yagophkeeper/internal/client/config"
 	"log"
 )
@@ -10,6 +11,13 @@ var Version string
 var BuildTime string
 
 func main() {
+	if clipboard.IsClearer() {
+		// фоновая очистка буфера, запущенная командой copy
+		if err := clipboard.RunClearer(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	cfg, err := config.New()
 	if err != nil {
 		log.Fatal(err)
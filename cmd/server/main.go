@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"github.com/Spear5030/yagophkeeper/internal/server/app"
 	"github.com/Spear5030/yagophkeeper/internal/server/config"
 	"log"
+	"time"
 )
 
 func main() {
@@ -11,6 +13,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cfg.Unlock != "" || cfg.Lockouts {
+		if err = admin(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	a, err := app.New(cfg)
 	if err != nil {
 		log.Fatal(err)
@@ -20,3 +28,33 @@ func main() {
 		log.Println(err)
 	}
 }
+
+// admin выполняет административную команду из флагов вместо запуска сервера
+func admin(cfg config.Config) error {
+	a, err := app.NewAdmin(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Unlock != "" {
+		if err = a.ClearLockout(cfg.Unlock); err != nil {
+			return err
+		}
+		fmt.Println("Login lockout cleared for", cfg.Unlock)
+		return nil
+	}
+	lockouts, err := a.ListLockouts()
+	if err != nil {
+		return err
+	}
+	if len(lockouts) == 0 {
+		fmt.Println("No failed login attempts")
+	}
+	for _, l := range lockouts {
+		state := "not locked"
+		if l.LockedUntil.After(time.Now()) {
+			state = "locked until " + l.LockedUntil.Format(time.RFC3339)
+		}
+		fmt.Printf("%s: %d failures, last %s, %s\n", l.Key, l.Failures, l.LastFailure.Format(time.RFC3339), state)
+	}
+	return nil
+}
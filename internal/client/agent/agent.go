@@ -0,0 +1,261 @@
+// Package agent хранит ключи разблокированного хранилища в памяти и шифрует ими данные
+// по запросам других запусков клиента через Unix-сокет, по аналогии с ssh-agent.
+// Ключи из агента не выдаются. Подключиться к агенту может только процесс того же пользователя,
+// на платформах без проверки учетных данных собеседника агент не запускается.
+package agent
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/client/storage"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	opPing = "ping"
+	opSeal = "seal"
+	opOpen = "open"
+	opStop = "stop"
+
+	dialTimeout = 2 * time.Second
+)
+
+var (
+	ErrNotRunning     = errors.New("agent is not running")
+	ErrAlreadyRunning = errors.New("agent is already running")
+	ErrUnsupported    = errors.New("agent is not supported on this platform: peer credentials are unavailable, use GK_MASTER")
+)
+
+type request struct {
+	Op             string
+	Key            storage.KeyID
+	Data           []byte
+	AdditionalData []byte
+}
+
+type response struct {
+	Data []byte
+	Err  string
+}
+
+// keySource хранилище, открытое мастер-паролем
+type keySource interface {
+	ExportKeys() (storage.Keys, error)
+}
+
+type Agent struct {
+	socket string
+	vault  keySource
+	logger *zap.Logger
+}
+
+func New(socket string, vault keySource, logger *zap.Logger) *Agent {
+	return &Agent{socket: socket, vault: vault, logger: logger}
+}
+
+// Serve запускает агент и блокируется до команды stop или до истечения timeout без запросов.
+// При выходе ключи стираются из памяти, сокет удаляется
+func (a *Agent) Serve(timeout time.Duration) error {
+	if !peerCredSupported {
+		return ErrUnsupported
+	}
+	keys, err := a.vault.ExportKeys()
+	if err != nil {
+		return err
+	}
+	defer wipe(keys)
+	l, err := listen(a.socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(a.socket)
+
+	var once sync.Once
+	done := make(chan struct{})
+	stop := func() { once.Do(func() { close(done) }) }
+	activity := make(chan struct{}, 1)
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-activity:
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(timeout)
+			case <-timer.C:
+				a.logger.Info("agent idle timeout, locking")
+				stop()
+				return
+			}
+		}
+	}()
+	go func() {
+		<-done
+		l.Close()
+	}()
+
+	a.logger.Info("agent started", zap.String("socket", a.socket), zap.Duration("timeout", timeout))
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			a.logger.Debug(err.Error())
+			continue
+		}
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+		if a.handle(conn.(*net.UnixConn), keys) {
+			stop()
+		}
+	}
+}
+
+// handle обрабатывает один запрос и возвращает true, если агент нужно остановить
+func (a *Agent) handle(conn *net.UnixConn, keys storage.Keys) bool {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := checkPeer(conn); err != nil {
+		a.logger.Warn("agent connection rejected", zap.Error(err))
+		return false
+	}
+	var req request
+	if err := gob.NewDecoder(conn).Decode(&req); err != nil {
+		a.logger.Debug(err.Error())
+		return false
+	}
+	var resp response
+	var err error
+	switch req.Op {
+	case opPing, opStop:
+	case opSeal:
+		resp.Data, err = keys.Seal(req.Key, req.Data, req.AdditionalData)
+	case opOpen:
+		resp.Data, err = keys.Open(req.Key, req.Data, req.AdditionalData)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	}
+	if err := gob.NewEncoder(conn).Encode(resp); err != nil {
+		a.logger.Debug(err.Error())
+	}
+	return req.Op == opStop
+}
+
+// listen создает сокет, доступный только владельцу. Сокет, оставшийся после аварийного
+// завершения агента, удаляется
+func listen(socket string) (*net.UnixListener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, err
+	}
+	if _, err := call(socket, request{Op: opPing}); err == nil {
+		return nil, ErrAlreadyRunning
+	}
+	_ = os.Remove(socket)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Client шифрует и расшифровывает данные ключами в запущенном агенте
+type Client struct {
+	socket string
+}
+
+// Dial проверяет, что агент запущен, и возвращает клиент к нему
+func Dial(socket string) (*Client, error) {
+	if _, err := call(socket, request{Op: opPing}); err != nil {
+		return nil, err
+	}
+	return &Client{socket: socket}, nil
+}
+
+// Seal шифрует b ключом id в агенте
+func (c *Client) Seal(id storage.KeyID, b []byte, additionalData []byte) ([]byte, error) {
+	resp, err := call(c.socket, request{Op: opSeal, Key: id, Data: b, AdditionalData: additionalData})
+	return resp.Data, err
+}
+
+// Open расшифровывает b ключом id в агенте
+func (c *Client) Open(id storage.KeyID, b []byte, additionalData []byte) ([]byte, error) {
+	resp, err := call(c.socket, request{Op: opOpen, Key: id, Data: b, AdditionalData: additionalData})
+	return resp.Data, err
+}
+
+// Stop останавливает запущенный агент
+func (a *Agent) Stop() error {
+	_, err := call(a.socket, request{Op: opStop})
+	return err
+}
+
+func call(socket string, req request) (response, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return response{}, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err = gob.NewEncoder(conn).Encode(req); err != nil {
+		return response{}, err
+	}
+	var resp response
+	if err = gob.NewDecoder(conn).Decode(&resp); err != nil {
+		return response{}, err
+	}
+	if resp.Err != "" {
+		return response{}, remoteError(resp.Err)
+	}
+	return resp, nil
+}
+
+// remoteError восстанавливает ошибки хранилища, чтобы клиент мог проверить их через errors.Is
+func remoteError(msg string) error {
+	for _, err := range []error{storage.ErrLocked, storage.ErrWrongMasterPass} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+func wipe(keys storage.Keys) {
+	for _, b := range [][]byte{keys.Key, keys.RecordKey} {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+}
+
+// DefaultSocket путь к сокету агента по умолчанию: в XDG_RUNTIME_DIR или во временном каталоге пользователя
+func DefaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("yagophkeeper-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "yagophkeeper")
+	}
+	return filepath.Join(dir, "agent.sock")
+}
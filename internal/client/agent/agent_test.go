@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/client/storage"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeVault struct {
+	keys storage.Keys
+}
+
+func (v fakeVault) ExportKeys() (storage.Keys, error) {
+	return v.keys, nil
+}
+
+func TestAgent(t *testing.T) {
+	lg, _ := logger.New(true)
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	keys := storage.Keys{
+		KDF:       []byte("kdf"),
+		Key:       []byte("0123456789abcdef0123456789abcdef"),
+		Email:     "test@test.ts",
+		RecordKey: []byte("fedcba9876543210fedcba9876543210"),
+	}
+	a := New(socket, fakeVault{keys: keys}, lg)
+
+	_, err := Dial(socket)
+	require.ErrorIs(t, err, ErrNotRunning)
+
+	done := make(chan error)
+	go func() { done <- a.Serve(time.Minute) }()
+	require.Eventually(t, func() bool {
+		_, err := Dial(socket)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	c, err := Dial(socket)
+	require.NoError(t, err)
+	fileID := storage.KeyID{KDF: keys.KDF}
+	sealed, err := c.Seal(fileID, []byte("secret"), []byte("header"))
+	require.NoError(t, err)
+	plain, err := keys.Open(fileID, sealed, []byte("header"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), plain)
+	plain, err = c.Open(fileID, sealed, []byte("header"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), plain)
+	_, err = c.Open(fileID, sealed, []byte("other"))
+	require.ErrorIs(t, err, storage.ErrWrongMasterPass)
+
+	recordID := storage.KeyID{Email: "TEST@test.ts"}
+	sealed, err = c.Seal(recordID, []byte("record"), nil)
+	require.NoError(t, err)
+	plain, err = keys.Open(recordID, sealed, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("record"), plain)
+
+	_, err = c.Seal(storage.KeyID{KDF: []byte("other")}, []byte("secret"), nil)
+	require.ErrorIs(t, err, storage.ErrLocked)
+	_, err = c.Seal(storage.KeyID{Email: "other@test.ts"}, []byte("secret"), nil)
+	require.ErrorIs(t, err, storage.ErrLocked)
+	_, err = call(socket, request{Op: "keys"})
+	require.Error(t, err)
+
+	require.ErrorIs(t, New(socket, fakeVault{}, lg).Serve(time.Minute), ErrAlreadyRunning)
+
+	require.NoError(t, a.Stop())
+	require.NoError(t, <-done)
+	_, err = Dial(socket)
+	require.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestAgentIdleTimeout(t *testing.T) {
+	lg, _ := logger.New(true)
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	a := New(socket, fakeVault{keys: storage.Keys{Key: []byte("key")}}, lg)
+	done := make(chan error)
+	go func() { done <- a.Serve(100 * time.Millisecond) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not lock after idle timeout")
+	}
+	_, err := Dial(socket)
+	require.ErrorIs(t, err, ErrNotRunning)
+}
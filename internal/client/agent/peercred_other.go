@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package agent
+
+import "net"
+
+// peerCredSupported на остальных платформах учетные данные собеседника не проверяются,
+// поэтому агент не запускается
+const peerCredSupported = false
+
+func checkPeer(conn *net.UnixConn) error {
+	return ErrUnsupported
+}
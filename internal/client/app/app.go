@@ -1,14 +1,16 @@
 package app
 
 import (
+	"github.com/Spear5030/yagophkeeper/internal/client/agent"
 	"github.com/Spear5030/yagophkeeper/internal/client/cli"
+	"github.com/Spear5030/yagophkeeper/internal/client/clipboard"
 	"github.com/Spear5030/yagophkeeper/internal/client/config"
 	"github.com/Spear5030/yagophkeeper/internal/client/grpcclient"
 	"github.com/Spear5030/yagophkeeper/internal/client/storage"
 	"github.com/Spear5030/yagophkeeper/internal/client/usecase"
 	"github.com/Spear5030/yagophkeeper/pkg/logger"
 	"go.uber.org/zap"
-	"log"
+	"os"
 )
 
 type App struct {
@@ -21,13 +23,16 @@ func New(cfg config.Config, version string, buildTime string) (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	repo, err := storage.New(cfg.FileStorage, cfg.MasterPass, lg)
+	if cfg.AgentSocket == "" {
+		cfg.AgentSocket = agent.DefaultSocket()
+	}
+	clip, err := clipboard.New(cfg.Clipboard)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	grpcl := grpcclient.New(cfg.Addr, cfg.Cert, repo.GetToken())
-	useCase := usecase.New(repo, grpcl, version, buildTime, lg)
-	cliclient := cli.New(lg, useCase)
+	v := &vault{cfg: cfg, version: version, buildTime: buildTime, logger: lg}
+	cliclient := cli.New(lg, v.unlock, agent.New(cfg.AgentSocket, v, lg),
+		clipboard.NewManager(clip, cfg.Clipboard, cfg.ClipboardTimeout), version, buildTime)
 
 	return &App{
 			logger: lg,
@@ -35,6 +40,66 @@ func New(cfg config.Config, version string, buildTime string) (*App, error) {
 		nil
 }
 
+// vault открывает хранилище по требованию команды. Команды, которым хранилище не нужно,
+// мастер-пароль не запрашивают
+type vault struct {
+	cfg       config.Config
+	version   string
+	buildTime string
+	logger    *zap.Logger
+	keys      interface {
+		ExportKeys() (storage.Keys, error)
+	}
+}
+
+// unlock открывает хранилище мастер-паролем из GK_MASTER, затем через агент.
+// Мастер-пароль запрашивается в терминале, только если агент не запущен
+func (v *vault) unlock(c *cli.CLI, interactive bool) error {
+	masterPass := v.cfg.MasterPass
+	var keyring storage.Keyring
+	if masterPass == "" {
+		client, err := agent.Dial(v.cfg.AgentSocket)
+		if err == nil {
+			keyring = client
+		} else {
+			v.logger.Debug(err.Error())
+			if !interactive {
+				return config.ErrNoMasterPass
+			}
+			masterPass, err = promptMasterPass(v.cfg.FileStorage)
+			if err != nil {
+				v.logger.Debug(err.Error())
+				return config.ErrNoMasterPass
+			}
+		}
+	}
+	repo, err := storage.NewWithKeyring(v.cfg.FileStorage, masterPass, keyring, v.logger)
+	if err != nil {
+		return err
+	}
+	repo.SetKeepBackups(v.cfg.Backups)
+	v.keys = repo
+	grpcl := grpcclient.New(v.cfg.Addr, v.cfg.Cert, repo.GetTokens(), repo.SaveTokens)
+	c.SetUsecase(usecase.New(repo, grpcl, v.version, v.buildTime, v.logger))
+	return nil
+}
+
+// ExportKeys отдает агенту ключи хранилища, открытого перед запуском команды agent
+func (v *vault) ExportKeys() (storage.Keys, error) {
+	if v.keys == nil {
+		return storage.Keys{}, storage.ErrLocked
+	}
+	return v.keys.ExportKeys()
+}
+
+// promptMasterPass запрашивает мастер-пароль в терминале. Для нового хранилища пароль вводится дважды
+func promptMasterPass(filename string) (string, error) {
+	if fi, err := os.Stat(filename); err == nil && fi.Size() > 0 {
+		return cli.ReadSecret("Master password: ")
+	}
+	return cli.ReadNewSecret("New master password: ")
+}
+
 func (app *App) Run() error {
 	app.cli.Execute()
 	return nil
@@ -1,47 +1,156 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/client/exporter"
+	"github.com/Spear5030/yagophkeeper/internal/client/importer"
+	"github.com/Spear5030/yagophkeeper/internal/client/tui"
+	ucase "github.com/Spear5030/yagophkeeper/internal/client/usecase"
 	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type usecase interface {
 	ListSecrets() []string
+	GetLogins() []domain.LoginPassword
+	GetTextData() []domain.TextData
+	GetBinaryData() []domain.BinaryData
+	GetCardsData() []domain.CardData
+	GetOTPs() []domain.OTPSecret
 	AddLoginPassword(domain.LoginPassword) error
 	AddTextData(domain.TextData) error
 	AddBinaryData(domain.BinaryData) error
+	AddBinaryFile(bd domain.BinaryData, r io.Reader) error
+	UpdateBinaryFile(bd domain.BinaryData, r io.Reader) error
+	GetBinaryContent(key int, w io.Writer) error
 	AddCardData(domain.CardData) error
+	GetLoginPassword(key int) (domain.LoginPassword, error)
+	GetText(key int) (domain.TextData, error)
+	GetBinary(key int) (domain.BinaryData, error)
+	GetCard(key int) (domain.CardData, error)
+	GetOTP(key int) (domain.OTPSecret, error)
+	AddOTP(domain.OTPSecret) error
+	UpdateOTP(domain.OTPSecret) error
+	DeleteOTP(key int) error
+	GetOTPCode(key int) (string, time.Duration, error)
+	ParseOTPURI(uri string) (domain.OTPSecret, error)
+	GetSecretField(secretType string, key int, field string) (string, error)
+	Search(query string) []domain.SearchResult
+	Import(format string, data []byte, dryRun bool) (domain.ImportReport, error)
+	ImportArchive(data []byte, password string, dryRun bool) (domain.ImportReport, error)
+	Export(format string) ([]byte, []string, error)
+	ExportArchive(password string) ([]byte, error)
+	GeneratePassword(policy domain.PasswordPolicy) (string, error)
+	GetPasswordProfile(name string) (domain.PasswordPolicy, error)
+	ListPasswordProfiles() map[string]domain.PasswordPolicy
+	SavePasswordProfile(name string, policy domain.PasswordPolicy) error
+	DeletePasswordProfile(name string) error
+	UpdateLoginPassword(domain.LoginPassword) error
+	UpdateTextData(domain.TextData) error
+	UpdateBinaryData(domain.BinaryData) error
+	UpdateCardData(domain.CardData) error
+	DeleteLoginPassword(key int) error
+	DeleteTextData(key int) error
+	DeleteBinaryData(key int) error
+	DeleteCardData(key int) error
 	RegisterUser(user domain.User) error
 	LoginUser(user domain.User) error
+	Logout() error
+	ListDevices() ([]domain.Device, error)
+	RevokeDevice(id string) error
 	CheckSync() (time.Time, error)
 	GetLocalSyncTime() time.Time
 	SyncData() error
-	GetVersion() string
-	GetBuildTime() string
+	ListConflicts() ([]domain.Conflict, error)
+	ResolveConflict(id string, resolution domain.Resolution) error
+	ListVaultVersions() ([]domain.VaultVersion, error)
+	RestoreVersion(number int64) (int, error)
+	ListBackups() ([]domain.Backup, error)
+	RestoreBackup(name string) error
+	ChangeMasterPassword(oldPass string, newPass string) error
+	ChangeAccountPassword(password string, newPassword string) error
+	DeleteAccount(password string) error
+	AdoptMasterPassword(newPass string) error
 }
 
+type clipboard interface {
+	Copy(text string, clearAfter time.Duration) error
+	DefaultTimeout() time.Duration
+}
+
+type agent interface {
+	Serve(timeout time.Duration) error
+	Stop() error
+}
+
+// Unlock открывает хранилище и передает usecase в CLI через SetUsecase.
+// Без interactive мастер-пароль не запрашивается
+type Unlock func(cli *CLI, interactive bool) error
+
 type CLI struct {
-	logger  *zap.Logger
-	usecase usecase
+	logger    *zap.Logger
+	unlock    Unlock
+	usecase   usecase
+	agent     agent
+	clipboard clipboard
+	version   string
+	buildTime string
 }
 
-func New(logger *zap.Logger, usecase usecase) *CLI {
-	c := CLI{logger: logger, usecase: usecase}
+func New(logger *zap.Logger, unlock Unlock, agent agent, clipboard clipboard, version string, buildTime string) *CLI {
+	c := CLI{logger: logger, unlock: unlock, agent: agent, clipboard: clipboard, version: version, buildTime: buildTime}
+	rootCmd.PersistentPreRunE = c.openVault
 	c.ListSecrets()
+	c.SearchCmd()
+	c.ImportCmd()
+	c.ExportCmd()
 	c.RegisterUser()
 	c.LoginUser()
+	c.Logout()
+	c.Devices()
 	c.CheckSync()
 	c.Sync()
+	c.Conflicts()
+	c.Resolve()
+	c.History()
+	c.Restore()
+	c.BackupListCmd()
+	c.BackupRestoreCmd()
+	c.Passwd()
+	c.AccountPasswdCmd()
+	c.AccountDeleteCmd()
 	c.AddLPCmd()
 	c.AddCardCmd()
 	c.AddTextCmd()
 	c.AddBinaryCmd()
+	c.AddOTPCmd()
+	c.UpdateLPCmd()
+	c.UpdateTextCmd()
+	c.UpdateBinaryCmd()
+	c.UpdateCardCmd()
+	c.UpdateOTPCmd()
+	c.GetBinaryCmd()
+	c.OTPCmd()
+	c.CopyCmd()
+	c.GenerateCmd()
+	c.DeleteCmd()
 	c.Version()
+	c.TUI()
+	c.Agent()
 	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(accountCmd)
 	return &c
 }
 
@@ -60,60 +169,266 @@ func (cli *CLI) ListSecrets() {
 	rootCmd.AddCommand(listCmd)
 }
 
+func (cli *CLI) SearchCmd() {
+	var searchCmd = &cobra.Command{
+		Use:   "search <query>",
+		Short: "search secrets",
+		Long: `search secrets of all types. Words are matched fuzzy by names and as substring in meta and text.
+Filters: login:, title:, issuer:, account:, holder:, url:, meta:, text:, tag:, folder:, type:login|text|card|binary|otp.
+Values with spaces are quoted: meta:"home wifi"`,
+		Run: func(cmd *cobra.Command, args []string) {
+			results := cli.usecase.Search(strings.Join(args, " "))
+			for _, r := range results {
+				line := fmt.Sprintf("[%s] Key[%d] %s", r.Type, r.Key, r.Title)
+				if r.Favorite {
+					line = "* " + line
+				}
+				if r.Details != "" {
+					line += " - " + r.Details
+				}
+				if r.Folder != "" {
+					line += " /" + r.Folder
+				}
+				if len(r.Tags) > 0 {
+					line += " #" + strings.Join(r.Tags, " #")
+				}
+				fmt.Println(line)
+			}
+			if len(results) == 0 {
+				fmt.Println("nothing found")
+			}
+		},
+	}
+	rootCmd.AddCommand(searchCmd)
+}
+
+func (cli *CLI) ImportCmd() {
+	var format string
+	var dryRun, stdin bool
+	var importCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "import secrets from other password managers",
+		Long: `import secrets from unencrypted export of other password manager.
+Formats: ` + strings.Join(importer.Formats, ", ") + `, archive.
+Fields without matching secret field are saved as custom fields, not transferred fields and items are reported.
+Archive password is prompted or read from stdin. Use --dry-run to see what would be created`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			var report domain.ImportReport
+			if format == exporter.Archive {
+				var password string
+				password, err = readPassword("Archive password: ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				report, err = cli.usecase.ImportArchive(data, password, dryRun)
+			} else {
+				report, err = cli.usecase.Import(format, data, dryRun)
+			}
+			verb := "Created"
+			if dryRun {
+				verb = "Would create"
+			}
+			for _, line := range report.Created {
+				fmt.Printf("%s %s\n", verb, line)
+			}
+			if len(report.Dropped) > 0 {
+				fmt.Println("Not imported:")
+				for _, line := range report.Dropped {
+					fmt.Println("  " + line)
+				}
+			}
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("%d secrets, %d dropped\n", len(report.Created), len(report.Dropped))
+		},
+	}
+	importCmd.Flags().StringVarP(&format, "format", "f", "", "export format: "+strings.Join(importer.Formats, "|")+"|archive (required)")
+	importCmd.MarkFlagRequired("format")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report what would be imported")
+	importCmd.Flags().BoolVar(&stdin, "password-stdin", false, "read archive password from stdin")
+	rootCmd.AddCommand(importCmd)
+}
+
+func (cli *CLI) ExportCmd() {
+	var format, out string
+	var unencrypted, stdin bool
+	var exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "export all secrets",
+		Long: `export all secrets to file or stdout.
+json and csv contain secrets in plain text and require --unencrypted. csv holds only logins and notes.
+archive is encrypted with separate password, which is prompted twice or read from stdin,
+and can be imported on another installation with import --format archive`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var data []byte
+			var skipped []string
+			var err error
+			switch {
+			case format == exporter.Archive:
+				var password string
+				password, err = readPassword("Archive password: ", stdin, true)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				data, err = cli.usecase.ExportArchive(password)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			case !unencrypted:
+				fmt.Printf("%s export contains all secrets in plain text, confirm with --unencrypted or use --format archive\n", format)
+				return
+			default:
+				data, skipped, err = cli.usecase.Export(format)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if out == "" {
+				os.Stdout.Write(data)
+			} else if err = os.WriteFile(out, data, 0600); err != nil {
+				fmt.Println(err)
+				return
+			}
+			// отчет в stderr, чтобы не смешиваться с экспортом в stdout
+			for _, line := range skipped {
+				fmt.Fprintln(os.Stderr, "Not exported: "+line)
+			}
+		},
+	}
+	exportCmd.Flags().StringVarP(&format, "format", "f", exporter.Archive, "format: "+strings.Join(exporter.Formats, "|"))
+	exportCmd.Flags().StringVarP(&out, "out", "o", "", "output file, stdout if empty")
+	exportCmd.Flags().BoolVar(&unencrypted, "unencrypted", false, "confirm plain text export")
+	exportCmd.Flags().BoolVar(&stdin, "password-stdin", false, "read archive password from stdin")
+	rootCmd.AddCommand(exportCmd)
+}
+
 func (cli *CLI) AddLPCmd() {
+	var meta metaFlags
 	var lp = &domain.LoginPassword{}
+	var generate, stdin bool
+	var flags policyFlags
 	var addLPCmd = &cobra.Command{
 		Use:   "login",
 		Short: "add login-password secret",
-		Long:  `add login-password secret`,
+		Long: `add login-password secret. Password is prompted without echo or read from stdin.
+With --generate password is generated by profile and printed`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := cli.usecase.AddLoginPassword(*lp)
+			if cmd.Flags().Changed("password") {
+				fmt.Println(ErrPasswordFlag)
+				return
+			}
+			var err error
+			switch {
+			case generate:
+				policy, err := cli.policy(&flags, cmd.Flags())
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				lp.Password, err = cli.usecase.GeneratePassword(policy)
+			default:
+				lp.Password, err = readPassword("Password: ", stdin, false)
+			}
 			if err != nil {
 				fmt.Println(err)
+				return
+			}
+			if err := meta.apply(cmd.Flags(), &lp.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.AddLoginPassword(*lp)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if generate {
+				fmt.Println(lp.Password)
 			}
 		},
 	}
 	addLPCmd.Flags().StringVarP(&lp.Login, "login", "l", "", "login (required)")
 	addLPCmd.MarkFlagRequired("login")
-	addLPCmd.Flags().StringVarP(&lp.Password, "password", "p", "", "password (required)")
-	addLPCmd.MarkFlagRequired("password")
+	removedPasswordFlag(addLPCmd.Flags())
+	addLPCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read password from stdin")
+	addLPCmd.Flags().BoolVarP(&generate, "generate", "g", false, "generate password")
+	addLPCmd.MarkFlagsMutuallyExclusive("password-stdin", "generate")
+	addLPCmd.Flags().StringVarP(&flags.profile, "profile", "P", "default", "generation profile for --generate")
+	flags.register(addLPCmd.Flags())
 	addLPCmd.Flags().StringVarP(&lp.Meta, "meta", "m", "", "meta field")
+	meta.register(addLPCmd.Flags(), false)
 	addCmd.AddCommand(addLPCmd)
 }
 
 func (cli *CLI) AddTextCmd() {
+	var meta metaFlags
 	var td = &domain.TextData{}
+	var stdin bool
 	var addTextCmd = &cobra.Command{
 		Use:   "text",
 		Short: "add text secret",
-		Long:  `add text secret`,
+		Long:  `add text secret. Text is prompted without echo or read from stdin`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := cli.usecase.AddTextData(*td)
+			if err := checkSecretFlags(cmd.Flags(), "text"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			var err error
+			td.Text, err = readPassword("Text: ", stdin, false)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err = meta.apply(cmd.Flags(), &td.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.AddTextData(*td)
 			if err != nil {
 				fmt.Println(err)
 			}
 		},
 	}
-	addTextCmd.Flags().StringVarP(&td.Text, "text", "t", "", "text (required)")
-	addTextCmd.MarkFlagRequired("text")
+	removedSecretFlag(addTextCmd.Flags(), "text", "t")
+	addTextCmd.Flags().BoolVarP(&stdin, "text-stdin", "", false, "read text from stdin")
 	addTextCmd.Flags().StringVarP(&td.Meta, "meta", "m", "", "meta field")
+	meta.register(addTextCmd.Flags(), false)
 	addCmd.AddCommand(addTextCmd)
 }
 
 func (cli *CLI) AddBinaryCmd() {
+	var meta metaFlags
 	var bd = &domain.BinaryData{}
 	var path string
-	var err error
 	var AddBinaryCmd = &cobra.Command{
 		Use:   "binary",
 		Short: "add binary secret",
-		Long:  `add binary secret`,
+		Long:  `add binary secret. File is encrypted in chunks and is not loaded in memory entirely`,
 		Run: func(cmd *cobra.Command, args []string) {
-			bd.BinaryData, err = os.ReadFile(path)
+			if err := meta.apply(cmd.Flags(), &bd.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			f, err := os.Open(path)
 			if err != nil {
 				fmt.Println(err)
+				return
 			}
-			err = cli.usecase.AddBinaryData(*bd)
+			defer f.Close()
+			bd.FileName = filepath.Base(path)
+			err = cli.usecase.AddBinaryFile(*bd, f)
 			if err != nil {
 				fmt.Println(err)
 			}
@@ -122,35 +437,391 @@ func (cli *CLI) AddBinaryCmd() {
 	AddBinaryCmd.Flags().StringVarP(&path, "path", "p", "", "path to binary file (required)")
 	AddBinaryCmd.MarkFlagRequired("path")
 	AddBinaryCmd.Flags().StringVarP(&bd.Meta, "meta", "m", "", "meta field")
+	meta.register(AddBinaryCmd.Flags(), false)
 
 	addCmd.AddCommand(AddBinaryCmd)
 }
 
 func (cli *CLI) AddCardCmd() {
+	var meta metaFlags
 	var card = &domain.CardData{}
+	var stdin bool
 	var addCardCmd = &cobra.Command{
 		Use:   "card",
 		Short: "add card secret",
-		Long:  `add card secret`,
+		Long:  `add card secret. CVC is prompted without echo or read from stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkSecretFlags(cmd.Flags(), "cvc"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			var err error
+			card.CVC, err = readPassword("CVC: ", stdin, false)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err := meta.apply(cmd.Flags(), &card.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.AddCardData(*card)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
 	}
 	addCardCmd.Flags().StringVarP(&card.Number, "number", "n", "", "number (required)")
 	addCardCmd.MarkFlagRequired("number")
-	addCardCmd.Flags().StringVarP(&card.CVC, "cvc", "v", "", "cvc (required)")
-	addCardCmd.MarkFlagRequired("cvc")
+	removedSecretFlag(addCardCmd.Flags(), "cvc", "v")
+	addCardCmd.Flags().BoolVarP(&stdin, "cvc-stdin", "", false, "read cvc from stdin")
 	addCardCmd.Flags().StringVarP(&card.CardHolder, "cardholder", "", "", "card holder")
 	addCardCmd.Flags().StringVarP(&card.Meta, "meta", "m", "", "meta field")
+	meta.register(addCardCmd.Flags(), false)
 	addCmd.AddCommand(addCardCmd)
 }
 
+func (cli *CLI) UpdateLPCmd() {
+	var meta metaFlags
+	var key int
+	var lp = domain.LoginPassword{}
+	var ask, stdin bool
+	var updateLPCmd = &cobra.Command{
+		Use:   "login",
+		Short: "update login-password secret",
+		Long:  `update login-password secret. New password is prompted with --ask-password or read with --password-stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if cmd.Flags().Changed("password") {
+				fmt.Println(ErrPasswordFlag)
+				return
+			}
+			old, err := cli.usecase.GetLoginPassword(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if cmd.Flags().Changed("login") {
+				old.Login = lp.Login
+			}
+			if ask || stdin {
+				old.Password, err = readPassword("New password: ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if cmd.Flags().Changed("meta") {
+				old.Meta = lp.Meta
+			}
+			if err := meta.apply(cmd.Flags(), &old.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.UpdateLoginPassword(old)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateLPCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+	updateLPCmd.MarkFlagRequired("key")
+	updateLPCmd.Flags().StringVarP(&lp.Login, "login", "l", "", "login")
+	removedPasswordFlag(updateLPCmd.Flags())
+	updateLPCmd.Flags().BoolVarP(&ask, "ask-password", "", false, "prompt for new password")
+	updateLPCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read new password from stdin")
+	updateLPCmd.MarkFlagsMutuallyExclusive("ask-password", "password-stdin")
+	updateLPCmd.Flags().StringVarP(&lp.Meta, "meta", "m", "", "meta field")
+	meta.register(updateLPCmd.Flags(), true)
+	updateCmd.AddCommand(updateLPCmd)
+}
+
+func (cli *CLI) UpdateTextCmd() {
+	var meta metaFlags
+	var key int
+	var td = domain.TextData{}
+	var ask, stdin bool
+	var updateTextCmd = &cobra.Command{
+		Use:   "text",
+		Short: "update text secret",
+		Long:  `update text secret. New text is prompted with --ask-text or read with --text-stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkSecretFlags(cmd.Flags(), "text"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			old, err := cli.usecase.GetText(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if ask || stdin {
+				old.Text, err = readPassword("New text: ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if cmd.Flags().Changed("meta") {
+				old.Meta = td.Meta
+			}
+			if err := meta.apply(cmd.Flags(), &old.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.UpdateTextData(old)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateTextCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+	updateTextCmd.MarkFlagRequired("key")
+	removedSecretFlag(updateTextCmd.Flags(), "text", "t")
+	updateTextCmd.Flags().BoolVarP(&ask, "ask-text", "", false, "prompt for new text")
+	updateTextCmd.Flags().BoolVarP(&stdin, "text-stdin", "", false, "read new text from stdin")
+	updateTextCmd.MarkFlagsMutuallyExclusive("ask-text", "text-stdin")
+	updateTextCmd.Flags().StringVarP(&td.Meta, "meta", "m", "", "meta field")
+	meta.register(updateTextCmd.Flags(), true)
+	updateCmd.AddCommand(updateTextCmd)
+}
+
+func (cli *CLI) UpdateBinaryCmd() {
+	var meta metaFlags
+	var key int
+	var path string
+	var bd = domain.BinaryData{}
+	var updateBinaryCmd = &cobra.Command{
+		Use:   "binary",
+		Short: "update binary secret",
+		Long:  `update binary secret`,
+		Run: func(cmd *cobra.Command, args []string) {
+			old, err := cli.usecase.GetBinary(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if cmd.Flags().Changed("meta") {
+				old.Meta = bd.Meta
+			}
+			if err := meta.apply(cmd.Flags(), &old.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			if cmd.Flags().Changed("path") {
+				var f *os.File
+				if f, err = os.Open(path); err != nil {
+					fmt.Println(err)
+					return
+				}
+				defer f.Close()
+				old.FileName = filepath.Base(path)
+				err = cli.usecase.UpdateBinaryFile(old, f)
+			} else {
+				err = cli.usecase.UpdateBinaryData(old)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateBinaryCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+	updateBinaryCmd.MarkFlagRequired("key")
+	updateBinaryCmd.Flags().StringVarP(&path, "path", "p", "", "path to binary file")
+	updateBinaryCmd.Flags().StringVarP(&bd.Meta, "meta", "m", "", "meta field")
+	meta.register(updateBinaryCmd.Flags(), true)
+	updateCmd.AddCommand(updateBinaryCmd)
+}
+
+func (cli *CLI) GetBinaryCmd() {
+	var out string
+	var force bool
+	var getBinaryCmd = &cobra.Command{
+		Use:   "binary <key>",
+		Short: "extract binary secret to file",
+		Long: `write binary secret content to file. Content is checked against size and SHA-256 recorded at add time.
+Without --out file is saved to current directory with original name, if --out is a directory - to it`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Println("key must be a number")
+				return
+			}
+			bd, err := cli.usecase.GetBinary(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			path, err := binaryPath(out, bd.FileName)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if _, err = os.Stat(path); err == nil && !force {
+				fmt.Printf("%s already exists, use --force to overwrite\n", path)
+				return
+			}
+			// содержимое пишется во временный файл и переименовывается только после проверки
+			tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.GetBinaryContent(key, tmp)
+			if errClose := tmp.Close(); err == nil {
+				err = errClose
+			}
+			if err == nil {
+				err = os.Rename(tmp.Name(), path)
+			}
+			if err != nil {
+				os.Remove(tmp.Name())
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("Saved %s: %d bytes", path, bd.Size)
+			if bd.MIMEType != "" {
+				fmt.Printf(", %s", bd.MIMEType)
+			}
+			if bd.SHA256 != "" {
+				fmt.Printf(", sha256 %s verified", bd.SHA256)
+			}
+			fmt.Println()
+		},
+	}
+	getBinaryCmd.Flags().StringVarP(&out, "out", "o", "", "output file or directory (default original file name)")
+	getBinaryCmd.Flags().BoolVar(&force, "force", false, "overwrite existing file")
+	getCmd.AddCommand(getBinaryCmd)
+}
+
+// binaryPath путь для извлечения binary. Имя файла приходит с других устройств, поэтому от него берется только base
+func binaryPath(out, fileName string) (string, error) {
+	name := filepath.Base(fileName)
+	if fileName == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		name = ""
+	}
+	if out == "" {
+		out = "."
+	}
+	if info, err := os.Stat(out); err == nil && info.IsDir() {
+		if name == "" {
+			return "", errors.New("binary has no file name, set file path with --out")
+		}
+		return filepath.Join(out, name), nil
+	}
+	return out, nil
+}
+
+func (cli *CLI) UpdateCardCmd() {
+	var meta metaFlags
+	var key int
+	var card = domain.CardData{}
+	var ask, stdin bool
+	var updateCardCmd = &cobra.Command{
+		Use:   "card",
+		Short: "update card secret",
+		Long:  `update card secret. New cvc is prompted with --ask-cvc or read with --cvc-stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkSecretFlags(cmd.Flags(), "cvc"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			old, err := cli.usecase.GetCard(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if cmd.Flags().Changed("number") {
+				old.Number = card.Number
+			}
+			if ask || stdin {
+				old.CVC, err = readPassword("New CVC: ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if cmd.Flags().Changed("cardholder") {
+				old.CardHolder = card.CardHolder
+			}
+			if cmd.Flags().Changed("meta") {
+				old.Meta = card.Meta
+			}
+			if err := meta.apply(cmd.Flags(), &old.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.UpdateCardData(old)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateCardCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+	updateCardCmd.MarkFlagRequired("key")
+	updateCardCmd.Flags().StringVarP(&card.Number, "number", "n", "", "number")
+	removedSecretFlag(updateCardCmd.Flags(), "cvc", "v")
+	updateCardCmd.Flags().BoolVarP(&ask, "ask-cvc", "", false, "prompt for new cvc")
+	updateCardCmd.Flags().BoolVarP(&stdin, "cvc-stdin", "", false, "read new cvc from stdin")
+	updateCardCmd.MarkFlagsMutuallyExclusive("ask-cvc", "cvc-stdin")
+	updateCardCmd.Flags().StringVarP(&card.CardHolder, "cardholder", "", "", "card holder")
+	updateCardCmd.Flags().StringVarP(&card.Meta, "meta", "m", "", "meta field")
+	meta.register(updateCardCmd.Flags(), true)
+	updateCmd.AddCommand(updateCardCmd)
+}
+
+// DeleteCmd добавляет команды удаления для каждого типа секретов
+func (cli *CLI) DeleteCmd() {
+	deleters := []struct {
+		use    string
+		delete func(u usecase, key int) error
+	}{
+		{"login", usecase.DeleteLoginPassword},
+		{"text", usecase.DeleteTextData},
+		{"binary", usecase.DeleteBinaryData},
+		{"card", usecase.DeleteCardData},
+		{"otp", usecase.DeleteOTP},
+	}
+	for _, d := range deleters {
+		var key int
+		deleteFunc := d.delete
+		var deleteTypeCmd = &cobra.Command{
+			Use:   d.use,
+			Short: "delete " + d.use + " secret",
+			Long:  `delete ` + d.use + ` secret. Deletion is sent to server on next sync`,
+			Run: func(cmd *cobra.Command, args []string) {
+				err := deleteFunc(cli.usecase, key)
+				if err != nil {
+					fmt.Println(err)
+				}
+			},
+		}
+		deleteTypeCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+		deleteTypeCmd.MarkFlagRequired("key")
+		deleteCmd.AddCommand(deleteTypeCmd)
+	}
+}
+
 func (cli *CLI) RegisterUser() {
 	var user = domain.User{}
+	var stdin bool
 	var regUserCmd = &cobra.Command{
 		Use:   "register",
 		Short: "register account",
-		Long:  `register account`,
+		Long:  `register account. Password is prompted twice without echo or read from stdin`,
 		Run: func(cmd *cobra.Command, args []string) {
 			cli.logger.Debug("RegisterUser")
-			err := cli.usecase.RegisterUser(user)
+			if cmd.Flags().Changed("password") {
+				fmt.Println(ErrPasswordFlag)
+				return
+			}
+			var err error
+			user.Password, err = readPassword("Account password: ", stdin, true)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.RegisterUser(user)
 			if err != nil {
 				fmt.Println(err)
 			}
@@ -158,8 +829,9 @@ func (cli *CLI) RegisterUser() {
 	}
 	regUserCmd.Flags().StringVarP(&user.Email, "email", "l", "", "email (required)")
 	regUserCmd.MarkFlagRequired("email")
-	regUserCmd.Flags().StringVarP(&user.Password, "password", "p", "", "password (required)")
-	regUserCmd.MarkFlagRequired("password")
+	removedPasswordFlag(regUserCmd.Flags())
+	regUserCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read password from stdin")
+	regUserCmd.Flags().StringVarP(&user.Device, "device", "d", hostname(), "device name")
 	rootCmd.AddCommand(regUserCmd)
 }
 
@@ -170,8 +842,21 @@ func (cli *CLI) Sync() {
 		Long:  `sync secrets with server`,
 		Run: func(cmd *cobra.Command, args []string) {
 			err := cli.usecase.SyncData()
+			if errors.Is(err, ucase.ErrMasterPassChanged) {
+				err = cli.adoptMasterPassword()
+			}
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			conflicts, err := cli.usecase.ListConflicts()
 			if err != nil {
 				fmt.Println(err)
+				return
+			}
+			if len(conflicts) > 0 {
+				printConflicts(conflicts)
+				return
 			}
 			fmt.Println("All secrets synced") //todo return last sync time
 		},
@@ -179,6 +864,252 @@ func (cli *CLI) Sync() {
 	rootCmd.AddCommand(syncCmd)
 }
 
+func (cli *CLI) Conflicts() {
+	var conflictsCmd = &cobra.Command{
+		Use:   "conflicts",
+		Short: "print sync conflicts",
+		Long:  `print secrets changed both locally and on server`,
+		Run: func(cmd *cobra.Command, args []string) {
+			conflicts, err := cli.usecase.ListConflicts()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if len(conflicts) == 0 {
+				fmt.Println("No conflicts")
+				return
+			}
+			printConflicts(conflicts)
+		},
+	}
+	rootCmd.AddCommand(conflictsCmd)
+}
+
+func (cli *CLI) Resolve() {
+	var resolveCmd = &cobra.Command{
+		Use:       "resolve <id> keep-local|keep-remote|keep-both",
+		Short:     "resolve sync conflict",
+		Long:      `resolve sync conflict. Result will be sent to server on next sync`,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{string(domain.KeepLocal), string(domain.KeepRemote), string(domain.KeepBoth)},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.usecase.ResolveConflict(args[0], domain.Resolution(args[1]))
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	rootCmd.AddCommand(resolveCmd)
+}
+
+func printConflicts(conflicts []domain.Conflict) {
+	fmt.Println("Conflicts (resolve with: client resolve <id> keep-local|keep-remote|keep-both):")
+	for _, c := range conflicts {
+		fmt.Printf("[%s] %s\n  local:  %s\n  remote: %s\n", c.ID, c.Type, c.Local, c.Remote)
+	}
+}
+
+func (cli *CLI) History() {
+	var historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "print vault versions",
+		Long:  `print vault versions kept by server. Version is saved after each sync that changed secrets`,
+		Run: func(cmd *cobra.Command, args []string) {
+			versions, err := cli.usecase.ListVaultVersions()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if len(versions) == 0 {
+				fmt.Println("No versions")
+				return
+			}
+			for i, v := range versions {
+				device := v.DeviceName
+				if device == "" {
+					device = "unknown device"
+				}
+				fmt.Printf("[%d] %s %s, %d secrets", v.Number, v.CreatedAt.Local().Format(time.DateTime), device, v.Records)
+				if i == 0 {
+					fmt.Print(" (current)")
+				}
+				fmt.Println()
+			}
+		},
+	}
+	rootCmd.AddCommand(historyCmd)
+}
+
+func (cli *CLI) Restore() {
+	var number int64
+	var restoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "restore vault version",
+		Long: `restore vault to version from history and sync it to all devices.
+Current vault is synced before and stays in history, so restore can be undone`,
+		Run: func(cmd *cobra.Command, args []string) {
+			changed, err := cli.usecase.RestoreVersion(number)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if changed == 0 {
+				fmt.Printf("Vault already matches version %d\n", number)
+				return
+			}
+			fmt.Printf("Restored version %d, %d secrets changed\n", number, changed)
+		},
+	}
+	restoreCmd.Flags().Int64Var(&number, "version", 0, "version number from history (required)")
+	restoreCmd.MarkFlagRequired("version")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func (cli *CLI) BackupListCmd() {
+	var backupListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "print local backups",
+		Long:  `print local vault backups, newest first`,
+		Run: func(cmd *cobra.Command, args []string) {
+			backups, err := cli.usecase.ListBackups()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups")
+				return
+			}
+			for _, b := range backups {
+				fmt.Printf("%s %s, %d bytes\n", b.Name, b.CreatedAt.Local().Format(time.DateTime), b.Size)
+			}
+		},
+	}
+	backupCmd.AddCommand(backupListCmd)
+}
+
+func (cli *CLI) BackupRestoreCmd() {
+	var backupRestoreCmd = &cobra.Command{
+		Use:   "restore <name>",
+		Short: "restore local backup",
+		Long: `replace local vault with backup. Current vault is backed up before, so restore can be undone.
+Changes made on server after backup come back on next sync`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cli.usecase.RestoreBackup(args[0]); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("Restored backup", args[0])
+		},
+	}
+	backupCmd.AddCommand(backupRestoreCmd)
+}
+
+func (cli *CLI) Passwd() {
+	var stdin bool
+	var passwdCmd = &cobra.Command{
+		Use:   "passwd",
+		Short: "change master password",
+		Long: `change master password and re-encrypt vault and binary content with new keys.
+Vault of account is synced first and replaced on server in one request, other devices ask for new password on next sync.
+Record key gets new random salt, accounts created before random salts are moved to it.
+Server history and local backups encrypted with old password are deleted`,
+		Run: func(cmd *cobra.Command, args []string) {
+			oldPass, newPass, err := readPasswordChange("master password", stdin)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err = cli.usecase.ChangeMasterPassword(oldPass, newPass); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("Master password changed")
+			cli.stopAgent()
+		},
+	}
+	passwdCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read current and new master password from first two lines of stdin")
+	rootCmd.AddCommand(passwdCmd)
+}
+
+func (cli *CLI) AccountPasswdCmd() {
+	var stdin bool
+	var accountPasswdCmd = &cobra.Command{
+		Use:   "passwd",
+		Short: "change account password",
+		Long: `change password of account on server. Master password and vault are not changed.
+Other devices are signed out and must login with new password`,
+		Run: func(cmd *cobra.Command, args []string) {
+			password, newPassword, err := readPasswordChange("account password", stdin)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err = cli.usecase.ChangeAccountPassword(password, newPassword); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("Account password changed, other devices are signed out")
+		},
+	}
+	accountPasswdCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read current and new account password from first two lines of stdin")
+	accountCmd.AddCommand(accountPasswdCmd)
+}
+
+func (cli *CLI) AccountDeleteCmd() {
+	var stdin, confirm bool
+	var accountDeleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "delete account",
+		Long: `delete account and all its data on server: secrets, binaries, history and devices.
+Local vault keeps secrets and is disconnected from server. Requires --confirm`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !confirm {
+				fmt.Println("Account and all its data on server will be deleted, run again with --confirm")
+				return
+			}
+			password, err := readPassword("Account password: ", stdin, false)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err = cli.usecase.DeleteAccount(password); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("Account deleted")
+		},
+	}
+	accountDeleteCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read account password from stdin")
+	accountDeleteCmd.Flags().BoolVarP(&confirm, "confirm", "", false, "confirm deletion of account")
+	accountCmd.AddCommand(accountDeleteCmd)
+}
+
+// adoptMasterPassword запрашивает мастер-пароль, смененный на другом устройстве
+func (cli *CLI) adoptMasterPassword() error {
+	fmt.Fprintln(os.Stderr, "Master password was changed on another device")
+	newPass, err := ReadSecret("New master password: ")
+	if errors.Is(err, ErrNotTerminal) {
+		return ucase.ErrMasterPassChanged
+	}
+	if err != nil {
+		return err
+	}
+	if err = cli.usecase.AdoptMasterPassword(newPass); err != nil {
+		return err
+	}
+	cli.stopAgent()
+	return nil
+}
+
+// stopAgent останавливает агент после смены мастер-пароля: его ключи больше не открывают хранилище
+func (cli *CLI) stopAgent() {
+	if cli.agent.Stop() == nil {
+		fmt.Println("Agent stopped, start it again with new master password")
+	}
+}
+
 func (cli *CLI) CheckSync() {
 	var checkSyncCmd = &cobra.Command{
 		Use:   "checksync",
@@ -199,12 +1130,23 @@ func (cli *CLI) CheckSync() {
 
 func (cli *CLI) LoginUser() {
 	var user = domain.User{}
+	var stdin bool
 	var logUserCmd = &cobra.Command{
 		Use:   "login",
 		Short: "login account",
-		Long:  `login account`,
+		Long:  `login account. Password is prompted without echo or read from stdin`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := cli.usecase.LoginUser(user)
+			if cmd.Flags().Changed("password") {
+				fmt.Println(ErrPasswordFlag)
+				return
+			}
+			var err error
+			user.Password, err = readPassword("Account password: ", stdin, false)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.LoginUser(user)
 			if err != nil {
 				fmt.Println(err)
 			}
@@ -212,20 +1154,179 @@ func (cli *CLI) LoginUser() {
 	}
 	logUserCmd.Flags().StringVarP(&user.Email, "email", "l", "", "email (required)")
 	logUserCmd.MarkFlagRequired("email")
-	logUserCmd.Flags().StringVarP(&user.Password, "password", "p", "", "password (required)")
-	logUserCmd.MarkFlagRequired("password")
+	removedPasswordFlag(logUserCmd.Flags())
+	logUserCmd.Flags().BoolVarP(&stdin, "password-stdin", "", false, "read password from stdin")
+	logUserCmd.Flags().StringVarP(&user.Device, "device", "d", hostname(), "device name")
 	rootCmd.AddCommand(logUserCmd)
 }
 
+func (cli *CLI) Logout() {
+	var logoutCmd = &cobra.Command{
+		Use:   "logout",
+		Short: "logout account",
+		Long:  `revoke refresh token on server and remove tokens from local storage`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.usecase.Logout()
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	rootCmd.AddCommand(logoutCmd)
+}
+
+func (cli *CLI) Devices() {
+	var devicesCmd = &cobra.Command{
+		Use:   "devices",
+		Short: "manage devices",
+		Long:  `list devices logged in to account and revoke lost ones`,
+	}
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "print devices",
+		Long:  `print devices logged in to account`,
+		Run: func(cmd *cobra.Command, args []string) {
+			devices, err := cli.usecase.ListDevices()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, d := range devices {
+				state := "active"
+				if d.Revoked {
+					state = "revoked"
+				}
+				if d.Current {
+					state += ", this device"
+				}
+				fmt.Printf("[%s] %s (%s) registered %s, last seen %s\n", d.ID, d.Name, state,
+					d.CreatedAt.Local().Format(time.DateTime), d.LastSeen.Local().Format(time.DateTime))
+			}
+		},
+	}
+	var revokeCmd = &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "revoke device",
+		Long:  `revoke device tokens. Revoked device can not sync until login again`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.usecase.RevokeDevice(args[0])
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	devicesCmd.AddCommand(listCmd, revokeCmd)
+	rootCmd.AddCommand(devicesCmd)
+}
+
+// hostname имя устройства по умолчанию
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+func (cli *CLI) CopyCmd() {
+	var field string
+	var timeout time.Duration
+	var copyCmd = &cobra.Command{
+		Use:   "copy <login|card|text|otp> <key>",
+		Short: "copy secret to clipboard",
+		Long: `copy secret field to clipboard and clear it after timeout (GK_CLIPBOARD_TIMEOUT).
+Default fields: login - password, card - number, text - text, otp - current code.
+Clipboard backend is detected or set by GK_CLIPBOARD: xclip, wl-copy, osc52`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Println("key must be a number")
+				return
+			}
+			value, err := cli.usecase.GetSecretField(args[0], key, field)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if !cmd.Flags().Changed("timeout") {
+				timeout = cli.clipboard.DefaultTimeout()
+			}
+			err = cli.clipboard.Copy(value, timeout)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if timeout > 0 {
+				fmt.Printf("Copied to clipboard, clearing in %s\n", timeout)
+			} else {
+				fmt.Println("Copied to clipboard")
+			}
+		},
+	}
+	copyCmd.Flags().StringVarP(&field, "field", "f", "", "field: password, login, number, cvc, holder, text, code, secret")
+	copyCmd.Flags().DurationVarP(&timeout, "timeout", "t", 0, "clear clipboard after timeout, 0 - do not clear (default GK_CLIPBOARD_TIMEOUT)")
+	rootCmd.AddCommand(copyCmd)
+}
+
 func (cli *CLI) Version() {
 	var versionCmd = &cobra.Command{
-		Use:   "version",
-		Short: "get version",
-		Long:  `get version and build time`,
+		Use:         "version",
+		Short:       "get version",
+		Long:        `get version and build time`,
+		Annotations: map[string]string{vaultAnnotation: vaultNone},
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Version:" + cli.usecase.GetVersion())
-			fmt.Println("Build time:" + cli.usecase.GetBuildTime())
+			fmt.Println("Version:" + cli.version)
+			fmt.Println("Build time:" + cli.buildTime)
 		},
 	}
 	rootCmd.AddCommand(versionCmd)
 }
+
+func (cli *CLI) TUI() {
+	var tuiCmd = &cobra.Command{
+		Use:   "tui",
+		Short: "interactive terminal UI",
+		Long:  `full-screen terminal UI for browsing, editing and syncing secrets`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := tui.New(cli.usecase).Run()
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func (cli *CLI) Agent() {
+	var timeout time.Duration
+	var agentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "keep vault unlocked",
+		Long: `unlock vault with GK_MASTER once and encrypt and decrypt vault data for other client commands over unix socket (GK_AGENT_SOCK).
+Keys never leave the agent. The agent is available on linux, macOS and FreeBSD only.
+Commands started without GK_MASTER use the agent. The agent locks after timeout without requests`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.agent.Serve(timeout)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	agentCmd.Flags().DurationVarP(&timeout, "timeout", "t", 15*time.Minute, "idle timeout")
+	var stopCmd = &cobra.Command{
+		Use:         "stop",
+		Short:       "stop agent",
+		Long:        `stop agent and wipe keys from memory`,
+		Annotations: map[string]string{vaultAnnotation: vaultNone},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.agent.Stop()
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	agentCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(agentCmd)
+}
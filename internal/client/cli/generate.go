@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"fmt"
+	ucase "github.com/Spear5030/yagophkeeper/internal/client/usecase"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sort"
+	"strings"
+)
+
+// policyFlags флаги, переопределяющие поля профиля генерации паролей
+type policyFlags struct {
+	profile          string
+	length           int
+	noLower          bool
+	noUpper          bool
+	noDigits         bool
+	noSymbols        bool
+	excludeAmbiguous bool
+	words            int
+	separator        string
+}
+
+func (f *policyFlags) register(fs *pflag.FlagSet) {
+	fs.IntVar(&f.length, "length", 20, "password length")
+	fs.BoolVar(&f.noLower, "no-lower", false, "without lowercase letters")
+	fs.BoolVar(&f.noUpper, "no-upper", false, "without uppercase letters")
+	fs.BoolVar(&f.noDigits, "no-digits", false, "without digits")
+	fs.BoolVar(&f.noSymbols, "no-symbols", false, "without symbols")
+	fs.BoolVar(&f.excludeAmbiguous, "exclude-ambiguous", false, "exclude ambiguous characters Il1O0o")
+	fs.IntVar(&f.words, "words", 0, "generate diceware passphrase of N words instead of password")
+	fs.StringVar(&f.separator, "separator", "-", "passphrase words separator")
+}
+
+// apply переопределяет поля policy флагами, явно переданными в команду
+func (f *policyFlags) apply(fs *pflag.FlagSet, policy domain.PasswordPolicy) domain.PasswordPolicy {
+	if fs.Changed("length") {
+		policy.Length = f.length
+		policy.Words = 0
+	}
+	if fs.Changed("no-lower") {
+		policy.Lower = !f.noLower
+	}
+	if fs.Changed("no-upper") {
+		policy.Upper = !f.noUpper
+	}
+	if fs.Changed("no-digits") {
+		policy.Digits = !f.noDigits
+	}
+	if fs.Changed("no-symbols") {
+		policy.Symbols = !f.noSymbols
+	}
+	if fs.Changed("exclude-ambiguous") {
+		policy.ExcludeAmbiguous = f.excludeAmbiguous
+	}
+	if fs.Changed("words") {
+		policy.Words = f.words
+	}
+	if fs.Changed("separator") {
+		policy.Separator = f.separator
+	}
+	return policy
+}
+
+// profile возвращает профиль генерации. Пока хранилище не открыто, встроенный профиль берется
+// без мастер-пароля, а для сохраненного профиля хранилище открывается
+func (cli *CLI) profile(name string) (domain.PasswordPolicy, error) {
+	if cli.usecase == nil {
+		if p, ok := ucase.BuiltinProfile(name); ok {
+			return p, nil
+		}
+		if err := cli.open(true); err != nil {
+			return domain.PasswordPolicy{}, err
+		}
+	}
+	return cli.usecase.GetPasswordProfile(name)
+}
+
+// policy возвращает профиль f.profile с примененными флагами
+func (cli *CLI) policy(f *policyFlags, fs *pflag.FlagSet) (domain.PasswordPolicy, error) {
+	policy, err := cli.profile(f.profile)
+	if err != nil {
+		return domain.PasswordPolicy{}, err
+	}
+	if fs.Changed("length") && !fs.Changed("words") {
+		// длина задана для пароля из символов - включаем все классы, если профиль был фразой
+		if policy.Words > 0 {
+			policy = domain.PasswordPolicy{Lower: true, Upper: true, Digits: true, Symbols: true}
+		}
+	}
+	return f.apply(fs, policy), nil
+}
+
+func (cli *CLI) GenerateCmd() {
+	var flags policyFlags
+	var generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "generate password",
+		Long: `generate password or diceware passphrase by profile. Flags override profile fields.
+Built-in profiles: default (20 chars, all classes, no ambiguous), pin (6 digits), passphrase (6 words).
+Saved profiles override built-in ones when vault is unlocked with GK_MASTER or client agent`,
+		Annotations: map[string]string{vaultAnnotation: vaultOptional},
+		Run: func(cmd *cobra.Command, args []string) {
+			policy, err := cli.policy(&flags, cmd.Flags())
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			password, err := ucase.GeneratePassword(policy)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(password)
+		},
+	}
+	generateCmd.Flags().StringVarP(&flags.profile, "profile", "P", "default", "generation profile")
+	flags.register(generateCmd.Flags())
+
+	var profileCmd = &cobra.Command{
+		Use:         "profile",
+		Short:       "manage generation profiles",
+		Long:        `manage named password generation profiles stored in vault`,
+		Annotations: map[string]string{vaultAnnotation: vaultRequired},
+	}
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "print profiles",
+		Long:  `print built-in and saved generation profiles`,
+		Run: func(cmd *cobra.Command, args []string) {
+			profiles := cli.usecase.ListPasswordProfiles()
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s: %s\n", name, describePolicy(profiles[name]))
+			}
+		},
+	}
+	var saveFlags policyFlags
+	var saveCmd = &cobra.Command{
+		Use:   "save <name>",
+		Short: "save profile",
+		Long:  `save generation profile. Profile is based on --profile and changed by passed flags`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			policy, err := cli.policy(&saveFlags, cmd.Flags())
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.SavePasswordProfile(args[0], policy)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	saveCmd.Flags().StringVarP(&saveFlags.profile, "profile", "P", "default", "base profile")
+	saveFlags.register(saveCmd.Flags())
+	var deleteProfileCmd = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete profile",
+		Long:  `delete saved generation profile`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cli.usecase.DeletePasswordProfile(args[0])
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	profileCmd.AddCommand(listCmd, saveCmd, deleteProfileCmd)
+	generateCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(generateCmd)
+}
+
+// describePolicy краткое описание политики для списка профилей
+func describePolicy(p domain.PasswordPolicy) string {
+	if p.Words > 0 {
+		return fmt.Sprintf("%d words, separator %q", p.Words, p.Separator)
+	}
+	var classes []string
+	for _, c := range []struct {
+		enabled bool
+		name    string
+	}{{p.Lower, "lower"}, {p.Upper, "upper"}, {p.Digits, "digits"}, {p.Symbols, "symbols"}} {
+		if c.enabled {
+			classes = append(classes, c.name)
+		}
+	}
+	s := fmt.Sprintf("%d chars, %s", p.Length, strings.Join(classes, "+"))
+	if p.ExcludeAmbiguous {
+		s += ", no ambiguous"
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/spf13/pflag"
+	"strings"
+)
+
+// metaFlags флаги общих метаданных записей для команд add и update
+type metaFlags struct {
+	title        string
+	urls         []string
+	tags         []string
+	folder       string
+	favorite     bool
+	fields       []string
+	boolFields   []string
+	hiddenFields []string
+	removeFields []string
+}
+
+// register добавляет флаги метаданных. update - флаги для команды update, с удалением полей
+func (f *metaFlags) register(fs *pflag.FlagSet, update bool) {
+	fs.StringVar(&f.title, "title", "", "title")
+	fs.StringArrayVar(&f.urls, "url", nil, "url, can be repeated")
+	fs.StringSliceVar(&f.tags, "tag", nil, "tags, comma separated or repeated")
+	fs.StringVar(&f.folder, "folder", "", "folder path, e.g. work/servers")
+	fs.BoolVar(&f.favorite, "favorite", false, "mark as favorite")
+	fs.StringArrayVar(&f.fields, "field", nil, "custom text field name=value, can be repeated")
+	fs.StringArrayVar(&f.boolFields, "bool-field", nil, "custom boolean field name=true|false, can be repeated")
+	fs.StringArrayVar(&f.hiddenFields, "hidden-field", nil, "custom hidden field name, value is prompted, can be repeated")
+	if update {
+		fs.StringArrayVar(&f.removeFields, "remove-field", nil, "remove custom field by name, can be repeated")
+	}
+}
+
+// apply переносит в m явно переданные флаги. Списки URL и тегов заменяются целиком,
+// пользовательские поля добавляются или заменяются по имени
+func (f *metaFlags) apply(fs *pflag.FlagSet, m *domain.Metadata) error {
+	if fs.Changed("title") {
+		m.Title = f.title
+	}
+	if fs.Changed("url") {
+		m.URLs = f.urls
+	}
+	if fs.Changed("tag") {
+		m.Tags = f.tags
+	}
+	if fs.Changed("folder") {
+		m.Folder = f.folder
+	}
+	if fs.Changed("favorite") {
+		m.Favorite = f.favorite
+	}
+	for _, name := range f.removeFields {
+		m.Fields = removeField(m.Fields, name)
+	}
+	for _, kv := range f.fields {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("field %q must be name=value", kv)
+		}
+		m.Fields = setField(m.Fields, domain.CustomField{Name: name, Type: domain.FieldText, Value: value})
+	}
+	for _, kv := range f.boolFields {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("bool field %q must be name=true|false", kv)
+		}
+		m.Fields = setField(m.Fields, domain.CustomField{Name: name, Type: domain.FieldBoolean, Value: value})
+	}
+	for _, name := range f.hiddenFields {
+		value, err := ReadSecret(name + ": ")
+		if err != nil {
+			return err
+		}
+		m.Fields = setField(m.Fields, domain.CustomField{Name: name, Type: domain.FieldHidden, Value: value})
+	}
+	return nil
+}
+
+func setField(fields []domain.CustomField, field domain.CustomField) []domain.CustomField {
+	fields = append([]domain.CustomField(nil), fields...)
+	for i := range fields {
+		if fields[i].Name == field.Name {
+			fields[i] = field
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+func removeField(fields []domain.CustomField, name string) []domain.CustomField {
+	var result []domain.CustomField
+	for _, f := range fields {
+		if f.Name != name {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+func (cli *CLI) AddOTPCmd() {
+	var meta metaFlags
+	var uri string
+	var otp = domain.OTPSecret{}
+	var stdin bool
+	var addOTPCmd = &cobra.Command{
+		Use:   "otp",
+		Short: "add TOTP secret",
+		Long: `add TOTP secret from otpauth:// URI or from base32 secret and parameters.
+Without --uri secret is prompted without echo or read with --secret-stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkSecretFlags(cmd.Flags(), "secret"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			var err error
+			if uri == "" {
+				otp.Secret, err = readPassword("Secret (base32): ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if uri != "" {
+				meta := otp.Meta
+				otp, err = cli.usecase.ParseOTPURI(uri)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				otp.Meta = meta
+			}
+			if err := meta.apply(cmd.Flags(), &otp.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.AddOTP(otp)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	addOTPCmd.Flags().StringVarP(&uri, "uri", "u", "", "otpauth:// URI")
+	removedSecretFlag(addOTPCmd.Flags(), "secret", "s")
+	addOTPCmd.Flags().BoolVarP(&stdin, "secret-stdin", "", false, "read base32 secret from stdin")
+	addOTPCmd.MarkFlagsMutuallyExclusive("uri", "secret-stdin")
+	addOTPCmd.Flags().StringVarP(&otp.Issuer, "issuer", "i", "", "issuer")
+	addOTPCmd.Flags().StringVarP(&otp.Account, "account", "a", "", "account")
+	addOTPCmd.Flags().StringVarP(&otp.Algorithm, "algorithm", "", "SHA1", "SHA1, SHA256 or SHA512")
+	addOTPCmd.Flags().IntVarP(&otp.Digits, "digits", "", 6, "code length")
+	addOTPCmd.Flags().IntVarP(&otp.Period, "period", "", 30, "code period in seconds")
+	addOTPCmd.Flags().StringVarP(&otp.Meta, "meta", "m", "", "meta field")
+	meta.register(addOTPCmd.Flags(), false)
+	addCmd.AddCommand(addOTPCmd)
+}
+
+func (cli *CLI) UpdateOTPCmd() {
+	var meta metaFlags
+	var key int
+	var otp = domain.OTPSecret{}
+	var ask, stdin bool
+	var updateOTPCmd = &cobra.Command{
+		Use:   "otp",
+		Short: "update TOTP secret",
+		Long:  `update TOTP secret. New base32 secret is prompted with --ask-secret or read with --secret-stdin`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkSecretFlags(cmd.Flags(), "secret"); err != nil {
+				fmt.Println(err)
+				return
+			}
+			old, err := cli.usecase.GetOTP(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if ask || stdin {
+				old.Secret, err = readPassword("New secret (base32): ", stdin, false)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+			if cmd.Flags().Changed("issuer") {
+				old.Issuer = otp.Issuer
+			}
+			if cmd.Flags().Changed("account") {
+				old.Account = otp.Account
+			}
+			if cmd.Flags().Changed("algorithm") {
+				old.Algorithm = otp.Algorithm
+			}
+			if cmd.Flags().Changed("digits") {
+				old.Digits = otp.Digits
+			}
+			if cmd.Flags().Changed("period") {
+				old.Period = otp.Period
+			}
+			if cmd.Flags().Changed("meta") {
+				old.Meta = otp.Meta
+			}
+			if err := meta.apply(cmd.Flags(), &old.Metadata); err != nil {
+				fmt.Println(err)
+				return
+			}
+			err = cli.usecase.UpdateOTP(old)
+			if err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	updateOTPCmd.Flags().IntVarP(&key, "key", "k", 0, "secret key (required)")
+	updateOTPCmd.MarkFlagRequired("key")
+	removedSecretFlag(updateOTPCmd.Flags(), "secret", "s")
+	updateOTPCmd.Flags().BoolVarP(&ask, "ask-secret", "", false, "prompt for new base32 secret")
+	updateOTPCmd.Flags().BoolVarP(&stdin, "secret-stdin", "", false, "read new base32 secret from stdin")
+	updateOTPCmd.MarkFlagsMutuallyExclusive("ask-secret", "secret-stdin")
+	updateOTPCmd.Flags().StringVarP(&otp.Issuer, "issuer", "i", "", "issuer")
+	updateOTPCmd.Flags().StringVarP(&otp.Account, "account", "a", "", "account")
+	updateOTPCmd.Flags().StringVarP(&otp.Algorithm, "algorithm", "", "", "SHA1, SHA256 or SHA512")
+	updateOTPCmd.Flags().IntVarP(&otp.Digits, "digits", "", 0, "code length")
+	updateOTPCmd.Flags().IntVarP(&otp.Period, "period", "", 0, "code period in seconds")
+	updateOTPCmd.Flags().StringVarP(&otp.Meta, "meta", "m", "", "meta field")
+	meta.register(updateOTPCmd.Flags(), true)
+	updateCmd.AddCommand(updateOTPCmd)
+}
+
+func (cli *CLI) OTPCmd() {
+	var otpCmd = &cobra.Command{
+		Use:   "otp <key>",
+		Short: "print TOTP code",
+		Long:  `print current TOTP code and time until it changes`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Println("key must be a number")
+				return
+			}
+			code, remaining, err := cli.usecase.GetOTPCode(key)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("%s (%s left)\n", code, remaining.Round(time.Second))
+		},
+	}
+	rootCmd.AddCommand(otpCmd)
+}
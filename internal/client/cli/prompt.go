@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var (
+	ErrNotTerminal      = errors.New("stdin is not a terminal: use --password-stdin")
+	ErrPasswordMismatch = errors.New("passwords do not match")
+	ErrEmptyPassword    = errors.New("password is empty")
+	ErrPasswordFlag     = errors.New("-p/--password is removed: passwords in arguments are saved in shell history and visible in process list. " +
+		"Enter password at prompt or pipe it with --password-stdin")
+	ErrSecretFlag = errors.New("secrets in arguments are saved in shell history and visible in process list")
+)
+
+// ReadSecret запрашивает секрет в терминале без эха. Приглашение выводится в stderr,
+// чтобы не смешиваться с выводом команды
+func ReadSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNotTerminal
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(secret) == 0 {
+		return "", ErrEmptyPassword
+	}
+	return string(secret), nil
+}
+
+// ReadNewSecret запрашивает новый секрет дважды и проверяет совпадение
+func ReadNewSecret(prompt string) (string, error) {
+	secret, err := ReadSecret(prompt)
+	if err != nil {
+		return "", err
+	}
+	confirm, err := ReadSecret("Repeat " + strings.ToLower(prompt[:1]) + prompt[1:])
+	if err != nil {
+		return "", err
+	}
+	if secret != confirm {
+		return "", ErrPasswordMismatch
+	}
+	return secret, nil
+}
+
+// removedPasswordFlag регистрирует скрытый флаг -p/--password, оставленный только для того,
+// чтобы старые скрипты получали ErrPasswordFlag, а не ошибку разбора флагов
+func removedPasswordFlag(flags *pflag.FlagSet) {
+	flags.StringP("password", "p", "", "removed, use prompt or --password-stdin")
+	_ = flags.MarkHidden("password")
+}
+
+// removedSecretFlag регистрирует скрытый флаг секрета, удаленный так же, как -p/--password
+func removedSecretFlag(flags *pflag.FlagSet, name string, shorthand string) {
+	flags.StringP(name, shorthand, "", "removed, use prompt or stdin")
+	_ = flags.MarkHidden(name)
+}
+
+// checkSecretFlags возвращает ошибку, если передан удаленный флаг секрета name.
+// Вместо него секрет вводится в запросе или читается с флагом --<name>-stdin
+func checkSecretFlags(flags *pflag.FlagSet, name string) error {
+	if !flags.Changed(name) {
+		return nil
+	}
+	return fmt.Errorf("--%s is removed: %w. Enter it at prompt or pipe it with --%s-stdin", name, ErrSecretFlag, name)
+}
+
+// readStdin читает секрет из первой строки stdin для использования в скриптах
+func readStdin() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", ErrEmptyPassword
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", ErrEmptyPassword
+	}
+	return line, nil
+}
+
+// readPassword возвращает пароль из stdin, если fromStdin, иначе запрашивает в терминале.
+// При confirm пароль в терминале вводится дважды
+func readPassword(prompt string, fromStdin bool, confirm bool) (string, error) {
+	if fromStdin {
+		return readStdin()
+	}
+	if confirm {
+		return ReadNewSecret(prompt)
+	}
+	return ReadSecret(prompt)
+}
+
+// readPasswordChange возвращает текущий и новый пароль, label - название пароля в запросах,
+// например "master password". Из stdin они читаются первыми двумя строками
+func readPasswordChange(label string, fromStdin bool) (string, string, error) {
+	if !fromStdin {
+		oldPass, err := ReadSecret("Current " + label + ": ")
+		if err != nil {
+			return "", "", err
+		}
+		newPass, err := ReadNewSecret("New " + label + ": ")
+		return oldPass, newPass, err
+	}
+	r := bufio.NewReader(os.Stdin)
+	var passwords [2]string
+	for i := range passwords {
+		line, err := r.ReadString('\n')
+		passwords[i] = strings.TrimRight(line, "\r\n")
+		if passwords[i] == "" {
+			if err != nil && err != io.EOF {
+				return "", "", err
+			}
+			return "", "", ErrEmptyPassword
+		}
+	}
+	return passwords[0], passwords[1], nil
+}
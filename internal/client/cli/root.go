@@ -23,6 +23,36 @@ var addCmd = &cobra.Command{
 	Long:  `add secret`,
 }
 
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "update secret",
+	Long:  `update secret by key. Only passed fields are changed`,
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "get secret",
+	Long:  `get secret content by key`,
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "delete secret",
+	Long:  `delete secret by key`,
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "local vault backups",
+	Long:  `local encrypted vault backups. Backup is made before every write of vault file`,
+}
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "server account",
+	Long:  `manage account on server: change account password or delete account`,
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func (cli *CLI) Execute() {
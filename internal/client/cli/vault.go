@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vaultAnnotation аннотация команды, определяющая, нужно ли ей хранилище. Подкоманды наследуют ее
+const vaultAnnotation = "vault"
+
+const (
+	// vaultRequired хранилище открывается до запуска команды, при необходимости с запросом мастер-пароля
+	vaultRequired = "required"
+	// vaultOptional хранилище открывается, только если мастер-пароль не нужно вводить
+	vaultOptional = "optional"
+	// vaultNone команде хранилище не нужно
+	vaultNone = "none"
+)
+
+// openVault открывает хранилище перед запуском команды, которой оно нужно
+func (cli *CLI) openVault(cmd *cobra.Command, args []string) error {
+	switch vaultMode(cmd) {
+	case vaultNone:
+		return nil
+	case vaultOptional:
+		// хранилище заблокировано - команда обходится без него
+		_ = cli.open(false)
+		return nil
+	}
+	cmd.SilenceUsage = true
+	return cli.open(true)
+}
+
+// open открывает хранилище, если оно еще не открыто
+func (cli *CLI) open(interactive bool) error {
+	if cli.usecase != nil {
+		return nil
+	}
+	return cli.unlock(cli, interactive)
+}
+
+// SetUsecase передает CLI usecase открытого хранилища
+func (cli *CLI) SetUsecase(usecase usecase) {
+	cli.usecase = usecase
+}
+
+// vaultMode возвращает режим хранилища команды. Справке и автодополнению хранилище не нужно
+func vaultMode(cmd *cobra.Command) string {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return vaultNone
+		}
+		if mode, ok := c.Annotations[vaultAnnotation]; ok {
+			return mode
+		}
+	}
+	return vaultRequired
+}
@@ -0,0 +1,80 @@
+package clipboard
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// backendEnv имя бэкенда, то же что в конфигурации клиента
+	backendEnv = "GK_CLIPBOARD"
+	// clearEnv признак фонового процесса очистки, значение - время до очистки
+	clearEnv = "GK_CLIPBOARD_CLEAR"
+)
+
+// spawnClearer запускает фоновую копию клиента, которая очистит буфер через after.
+// Текст передается через pipe, чтобы не попасть в аргументы и окружение процесса
+func spawnClearer(name, text string, after time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := exec.Command(exe)
+	cmd.Stdin = r
+	cmd.Env = append(clearerEnv(), backendEnv+"="+name, clearEnv+"="+after.String())
+	cmd.SysProcAttr = detachAttr()
+	if err = cmd.Start(); err != nil {
+		w.Close()
+		return err
+	}
+	// текст меньше буфера pipe, запись не блокируется
+	_, err = io.WriteString(w, text)
+	w.Close()
+	if err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// clearerEnv окружение без мастер-пароля: процессу очистки он не нужен
+func clearerEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GK_MASTER=") || strings.HasPrefix(kv, backendEnv+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// IsClearer сообщает, что процесс запущен как фоновая очистка буфера
+func IsClearer() bool {
+	return os.Getenv(clearEnv) != ""
+}
+
+// RunClearer читает скопированный текст из stdin, ждет и очищает буфер, если текст в нем не сменился
+func RunClearer() error {
+	after, err := time.ParseDuration(os.Getenv(clearEnv))
+	if err != nil {
+		return err
+	}
+	text, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	b, err := New(os.Getenv(backendEnv))
+	if err != nil {
+		return err
+	}
+	time.Sleep(after)
+	return Clear(b, string(text))
+}
@@ -0,0 +1,153 @@
+// Package clipboard копирование секретов в буфер обмена с последующей очисткой.
+// Очистку выполняет отдельный фоновый процесс клиента, чтобы команда copy не блокировала терминал
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	Xclip  = "xclip"
+	WlCopy = "wl-copy"
+	OSC52  = "osc52"
+)
+
+var (
+	// ErrUnsupported бэкенд не умеет читать буфер обмена
+	ErrUnsupported    = errors.New("clipboard read is not supported")
+	ErrUnknownBackend = errors.New("unknown clipboard backend")
+)
+
+// Backend буфер обмена
+type Backend interface {
+	Copy(text string) error
+	// Paste возвращает содержимое буфера или ErrUnsupported
+	Paste() (string, error)
+	Clear() error
+}
+
+// New возвращает бэкенд по имени. Пустое имя - автоопределение:
+// wl-copy под Wayland, xclip под X11, иначе escape-последовательность OSC 52 терминала
+func New(name string) (Backend, error) {
+	if name == "" {
+		name = detect()
+	}
+	switch name {
+	case Xclip:
+		return &command{
+			copy:  []string{"xclip", "-selection", "clipboard", "-in"},
+			paste: []string{"xclip", "-selection", "clipboard", "-out"},
+		}, nil
+	case WlCopy:
+		return &command{
+			copy:  []string{"wl-copy"},
+			paste: []string{"wl-paste", "--no-newline"},
+			clear: []string{"wl-copy", "--clear"},
+		}, nil
+	case OSC52:
+		return &osc52{}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+}
+
+func detect() string {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return WlCopy
+		}
+	}
+	if os.Getenv("DISPLAY") != "" {
+		if _, err := exec.LookPath("xclip"); err == nil {
+			return Xclip
+		}
+	}
+	return OSC52
+}
+
+// Clear очищает буфер, если в нем все еще text. Если бэкенд не умеет читать буфер, очищает безусловно
+func Clear(b Backend, text string) error {
+	current, err := b.Paste()
+	switch {
+	case errors.Is(err, ErrUnsupported):
+	case err != nil:
+		return err
+	case current != text:
+		// пользователь уже скопировал что-то другое
+		return nil
+	}
+	return b.Clear()
+}
+
+// command бэкенд на внешних утилитах. Текст передается через stdin, а не аргументами
+type command struct {
+	copy  []string
+	paste []string
+	// clear команда очистки, если пусто - копируется пустая строка
+	clear []string
+}
+
+func (c *command) Copy(text string) error {
+	cmd := exec.Command(c.copy[0], c.copy[1:]...)
+	cmd.Stdin = bytes.NewBufferString(text)
+	return run(cmd)
+}
+
+func (c *command) Paste() (string, error) {
+	out, err := exec.Command(c.paste[0], c.paste[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", c.paste[0], err)
+	}
+	return string(out), nil
+}
+
+func (c *command) Clear() error {
+	if len(c.clear) == 0 {
+		return c.Copy("")
+	}
+	return run(exec.Command(c.clear[0], c.clear[1:]...))
+}
+
+// run запускает команду без перехвата вывода: xclip и wl-copy оставляют в фоне процесс,
+// владеющий буфером, и перехваченный вывод не закрылся бы до его завершения
+func run(cmd *exec.Cmd) error {
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return nil
+}
+
+// Manager копирует текст и запускает фоновую очистку
+type Manager struct {
+	backend Backend
+	name    string
+	timeout time.Duration
+	// spawn запускает очистку, в тестах подменяется
+	spawn func(name, text string, after time.Duration) error
+}
+
+// NewManager возвращает Manager. name передается фоновому процессу очистки,
+// timeout - время до очистки по умолчанию
+func NewManager(backend Backend, name string, timeout time.Duration) *Manager {
+	return &Manager{backend: backend, name: name, timeout: timeout, spawn: spawnClearer}
+}
+
+// DefaultTimeout время до очистки по умолчанию
+func (m *Manager) DefaultTimeout() time.Duration {
+	return m.timeout
+}
+
+// Copy копирует text в буфер и, если clearAfter > 0, очищает его через clearAfter
+func (m *Manager) Copy(text string, clearAfter time.Duration) error {
+	if err := m.backend.Copy(text); err != nil {
+		return err
+	}
+	if clearAfter <= 0 {
+		return nil
+	}
+	return m.spawn(m.name, text, clearAfter)
+}
@@ -0,0 +1,59 @@
+package clipboard
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClear(t *testing.T) {
+	var f Fake
+	require.NoError(t, f.Copy("secret"))
+	require.NoError(t, Clear(&f, "secret"))
+	text, _ := f.Paste()
+	require.Empty(t, text)
+
+	// буфер уже занят другим текстом - не трогаем
+	require.NoError(t, f.Copy("other"))
+	require.NoError(t, Clear(&f, "secret"))
+	text, _ = f.Paste()
+	require.Equal(t, "other", text)
+}
+
+func TestManagerCopy(t *testing.T) {
+	var f Fake
+	done := make(chan struct{})
+	m := NewManager(&f, "", time.Minute)
+	m.spawn = func(name, text string, after time.Duration) error {
+		go func() {
+			time.Sleep(after)
+			require.NoError(t, Clear(&f, text))
+			close(done)
+		}()
+		return nil
+	}
+
+	require.NoError(t, m.Copy("secret", 10*time.Millisecond))
+	text, _ := f.Paste()
+	require.Equal(t, "secret", text)
+	<-done
+	text, _ = f.Paste()
+	require.Empty(t, text)
+}
+
+func TestManagerCopyWithoutClear(t *testing.T) {
+	var f Fake
+	m := NewManager(&f, "", time.Minute)
+	m.spawn = func(name, text string, after time.Duration) error {
+		t.Fatal("clearer must not be started")
+		return nil
+	}
+	require.NoError(t, m.Copy("secret", 0))
+	text, _ := f.Paste()
+	require.Equal(t, "secret", text)
+}
+
+func TestNewUnknown(t *testing.T) {
+	_, err := New("pigeon")
+	require.ErrorIs(t, err, ErrUnknownBackend)
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package clipboard
+
+import "syscall"
+
+// detachAttr выносит процесс очистки в отдельную группу, чтобы Ctrl+C в терминале его не завершал.
+// Сессия остается прежней - OSC 52 пишет в управляющий терминал
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,26 @@
+package clipboard
+
+import "sync"
+
+// Fake буфер обмена в памяти для тестов
+type Fake struct {
+	mu   sync.Mutex
+	text string
+}
+
+func (f *Fake) Copy(text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.text = text
+	return nil
+}
+
+func (f *Fake) Paste() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.text, nil
+}
+
+func (f *Fake) Clear() error {
+	return f.Copy("")
+}
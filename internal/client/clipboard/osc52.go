@@ -0,0 +1,29 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// osc52 копирует через escape-последовательность OSC 52, которую поддерживают многие терминалы,
+// в том числе при работе по ssh. Прочитать буфер так нельзя
+type osc52 struct{}
+
+func (o *osc52) Copy(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+	defer tty.Close()
+	_, err = fmt.Fprintf(tty, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+func (o *osc52) Paste() (string, error) {
+	return "", ErrUnsupported
+}
+
+func (o *osc52) Clear() error {
+	return o.Copy("")
+}
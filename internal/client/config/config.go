@@ -1,12 +1,32 @@
 package config
 
-import "github.com/caarlos0/env"
+import (
+	"errors"
+	"github.com/caarlos0/env"
+	"time"
+)
+
+// legacyDefaultMaster мастер-пароль, который раньше был значением по умолчанию. Он опубликован в исходниках
+const legacyDefaultMaster = "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47"
+
+var (
+	ErrNoMasterPass      = errors.New("master password is not set: use GK_MASTER, client agent or run in terminal")
+	ErrDefaultMasterPass = errors.New("built-in default master password is not allowed: set your own GK_MASTER")
+)
 
 type Config struct {
 	FileStorage string `env:"GK_CLIENT_FILE" envDefault:"user.dat"`
 	Addr        string `env:"GK_SERVER_ADDR" envDefault:":22345"`
 	Cert        string `env:"GK_CLIENT_CERT" envDefault:"cert/ca-cert.pem"`
-	MasterPass  string `env:"GK_MASTER" envDefault:"N1PCdw3M2B1TfJhoaY2mL736p2vCUc47"`
+	MasterPass  string `env:"GK_MASTER"`
+	// AgentSocket сокет агента, используется если GK_MASTER не задан
+	AgentSocket string `env:"GK_AGENT_SOCK"`
+	// Clipboard бэкенд буфера обмена: xclip, wl-copy, osc52. Пусто - автоопределение
+	Clipboard string `env:"GK_CLIPBOARD"`
+	// ClipboardTimeout время до очистки скопированного секрета, 0 - не очищать
+	ClipboardTimeout time.Duration `env:"GK_CLIPBOARD_TIMEOUT" envDefault:"30s"`
+	// Backups количество локальных резервных копий файла хранилища, 0 - не делать копии
+	Backups int `env:"GK_CLIENT_BACKUPS" envDefault:"10"`
 }
 
 var cfg Config
@@ -15,5 +35,8 @@ func New() (Config, error) {
 	if err := env.Parse(&cfg); err != nil {
 		return Config{}, err
 	}
+	if cfg.MasterPass == legacyDefaultMaster {
+		return Config{}, ErrDefaultMasterPass
+	}
 	return cfg, nil
 }
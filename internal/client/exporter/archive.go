@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"golang.org/x/crypto/argon2"
+	"io"
+)
+
+var (
+	ErrNotArchive    = errors.New("not a yagophkeeper archive")
+	ErrWrongPassword = errors.New("wrong archive password")
+	ErrEmptyPassword = errors.New("archive password is empty")
+)
+
+// archiveMagic начало архива. Формат: magic, длина соли (1 байт), соль, time (4 байта), memory (4 байта),
+// threads (1 байт), затем nonce и AES-GCM шифротекст сжатого JSON экспорта. Заголовок защищен как additional data.
+// Архив не зависит от мастер-пароля и аккаунта и загружается на любой установке
+var archiveMagic = []byte("GKA1")
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// kdf параметры Argon2id, как у файла хранилища
+type kdf struct {
+	salt    []byte
+	time    uint32
+	memory  uint32 // KiB
+	threads uint8
+}
+
+func (p kdf) key(password string) []byte {
+	return argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, keySize)
+}
+
+// Seal упаковывает секреты в архив, зашифрованный паролем
+func Seal(v domain.Vault, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrEmptyPassword
+	}
+	p := kdf{salt: make([]byte, saltSize), time: 3, memory: 64 * 1024, threads: 4}
+	if _, err := io.ReadFull(rand.Reader, p.salt); err != nil {
+		return nil, err
+	}
+	doc, err := encodeJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err = zw.Write(doc); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	header.Write(archiveMagic)
+	header.WriteByte(byte(len(p.salt)))
+	header.Write(p.salt)
+	_ = binary.Write(&header, binary.BigEndian, p.time)
+	_ = binary.Write(&header, binary.BigEndian, p.memory)
+	header.WriteByte(p.threads)
+
+	gcm, err := newGCM(p.key(password))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	hdr := header.Bytes()
+	out := append(append([]byte(nil), hdr...), nonce...)
+	return gcm.Seal(out, nonce, compressed.Bytes(), hdr), nil
+}
+
+// Open расшифровывает архив, созданный Seal
+func Open(data []byte, password string) (domain.Vault, error) {
+	if !bytes.HasPrefix(data, archiveMagic) {
+		return domain.Vault{}, ErrNotArchive
+	}
+	n := len(archiveMagic)
+	if len(data) < n+1 || len(data) < n+1+int(data[n])+9 {
+		return domain.Vault{}, ErrNotArchive
+	}
+	saltLen := int(data[n])
+	p := kdf{salt: data[n+1 : n+1+saltLen]}
+	n += 1 + saltLen
+	p.time = binary.BigEndian.Uint32(data[n:])
+	p.memory = binary.BigEndian.Uint32(data[n+4:])
+	p.threads = data[n+8]
+	n += 9
+	if p.time == 0 || p.memory == 0 || p.threads == 0 {
+		return domain.Vault{}, ErrNotArchive
+	}
+
+	gcm, err := newGCM(p.key(password))
+	if err != nil {
+		return domain.Vault{}, err
+	}
+	body := data[n:]
+	if len(body) < gcm.NonceSize() {
+		return domain.Vault{}, ErrNotArchive
+	}
+	compressed, err := gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], data[:n])
+	if err != nil {
+		return domain.Vault{}, ErrWrongPassword
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return domain.Vault{}, err
+	}
+	defer zr.Close()
+	doc, err := io.ReadAll(zr)
+	if err != nil {
+		return domain.Vault{}, err
+	}
+	return Decode(doc)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
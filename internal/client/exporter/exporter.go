@@ -0,0 +1,138 @@
+// Package exporter выгружает секреты хранилища в переносимые форматы: открытые JSON и CSV
+// и архив, зашифрованный отдельным паролем. JSON и архив загружаются обратно командой import
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"strings"
+	"time"
+)
+
+const (
+	JSON    = "json"
+	CSV     = "csv"
+	Archive = "archive"
+)
+
+// Formats поддерживаемые форматы
+var Formats = []string{JSON, CSV, Archive}
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrNotExport     = errors.New("not a yagophkeeper export")
+)
+
+const (
+	documentFormat  = "yagophkeeper"
+	documentVersion = 1
+)
+
+// document JSON экспорт. Key и ID записей при импорте назначаются заново
+type document struct {
+	Format     string       `json:"format"`
+	Version    int          `json:"version"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Vault      domain.Vault `json:"vault"`
+}
+
+// csvHeader колонки CSV, совпадают с заголовками, которые понимает импорт CSV
+var csvHeader = []string{"type", "name", "username", "password", "url", "notes", "folder", "favorite", "tags"}
+
+// Export выгружает секреты в открытом виде. Для CSV возвращает список того, что в формат не вошло
+func Export(format string, v domain.Vault) ([]byte, []string, error) {
+	switch format {
+	case JSON:
+		b, err := encodeJSON(v)
+		return b, nil, err
+	case CSV:
+		return encodeCSV(v)
+	}
+	return nil, nil, fmt.Errorf("%w %q, supported: %s", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+}
+
+func encodeJSON(v domain.Vault) ([]byte, error) {
+	return json.MarshalIndent(document{
+		Format:     documentFormat,
+		Version:    documentVersion,
+		ExportedAt: time.Now().UTC(),
+		Vault:      v,
+	}, "", "  ")
+}
+
+// Decode читает JSON экспорт
+func Decode(data []byte) (domain.Vault, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return domain.Vault{}, fmt.Errorf("%w: %v", ErrNotExport, err)
+	}
+	if doc.Format != documentFormat {
+		return domain.Vault{}, ErrNotExport
+	}
+	if doc.Version > documentVersion {
+		return domain.Vault{}, fmt.Errorf("export version %d is newer than supported %d, update client", doc.Version, documentVersion)
+	}
+	return doc.Vault, nil
+}
+
+// encodeCSV выгружает логины и тексты. Карты, файлы, TOTP и пользовательские поля в CSV не переносятся
+func encodeCSV(v domain.Vault) ([]byte, []string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, nil, err
+	}
+	var skipped []string
+	lost := func(kind, title string, m domain.Metadata) {
+		if len(m.Fields) > 0 {
+			skipped = append(skipped, fmt.Sprintf("%s %q: %d custom fields", kind, title, len(m.Fields)))
+		}
+		if len(m.URLs) > 1 {
+			skipped = append(skipped, fmt.Sprintf("%s %q: %d additional urls", kind, title, len(m.URLs)-1))
+		}
+	}
+	for _, lp := range v.Logins {
+		lost("login", lp.Title, lp.Metadata)
+		var url string
+		if len(lp.URLs) > 0 {
+			url = lp.URLs[0]
+		}
+		if err := w.Write(csvRow("login", lp.Login, lp.Password, url, lp.Meta, lp.Metadata)); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, td := range v.Texts {
+		lost("text", td.Title, td.Metadata)
+		if td.Meta != "" {
+			skipped = append(skipped, fmt.Sprintf("text %q: meta", td.Title))
+		}
+		if err := w.Write(csvRow("note", "", "", "", td.Text, td.Metadata)); err != nil {
+			return nil, nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, nil, err
+	}
+	for _, s := range []struct {
+		kind string
+		n    int
+	}{{"cards", len(v.Cards)}, {"binaries", len(v.Binaries)}, {"otp secrets", len(v.OTPs)}} {
+		if s.n > 0 {
+			skipped = append(skipped, fmt.Sprintf("%d %s: not supported by csv, use json or archive", s.n, s.kind))
+		}
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+func csvRow(kind, login, password, url, notes string, m domain.Metadata) []string {
+	favorite := ""
+	if m.Favorite {
+		favorite = "1"
+	}
+	return []string{kind, m.Title, login, password, url, notes, m.Folder, favorite, strings.Join(m.Tags, ",")}
+}
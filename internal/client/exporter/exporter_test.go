@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testVault() domain.Vault {
+	created := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	return domain.Vault{
+		Logins: []domain.LoginPassword{{Key: 1, ID: "a", Login: "alice", Password: "p,w\"1", Meta: "work", Metadata: domain.Metadata{
+			Title: "GitHub", URLs: []string{"https://github.com", "https://gist.github.com"}, Tags: []string{"dev", "ci"},
+			Folder: "work", Favorite: true, CreatedAt: created,
+			Fields: []domain.CustomField{{Name: "pin", Type: domain.FieldHidden, Value: "1234"}}}}},
+		Texts:    []domain.TextData{{Key: 1, Text: "line1\nline2", Metadata: domain.Metadata{Title: "Note"}}},
+		Cards:    []domain.CardData{{Key: 1, Number: "4111111111111111", CVC: "123"}},
+		Binaries: []domain.BinaryData{{Key: 1, BinaryData: []byte{0, 1, 2}}},
+		OTPs:     []domain.OTPSecret{{Key: 1, Issuer: "GitHub", Secret: "JBSWY3DPEHPK3PXP", Digits: 6, Period: 30, Algorithm: "SHA1"}},
+	}
+}
+
+func TestJSON(t *testing.T) {
+	b, skipped, err := Export(JSON, testVault())
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	v, err := Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, testVault(), v)
+
+	_, err = Decode([]byte(`{"format": "other"}`))
+	require.ErrorIs(t, err, ErrNotExport)
+	_, err = Decode([]byte(`{"format": "yagophkeeper", "version": 99}`))
+	require.Error(t, err)
+}
+
+func TestCSV(t *testing.T) {
+	b, skipped, err := Export(CSV, testVault())
+	require.NoError(t, err)
+	require.Equal(t, "type,name,username,password,url,notes,folder,favorite,tags\n"+
+		"login,GitHub,alice,\"p,w\"\"1\",https://github.com,work,work,1,\"dev,ci\"\n"+
+		"note,Note,,,,\"line1\nline2\",,,\n", string(b))
+	require.Equal(t, []string{
+		`login "GitHub": 1 custom fields`,
+		`login "GitHub": 1 additional urls`,
+		"1 cards: not supported by csv, use json or archive",
+		"1 binaries: not supported by csv, use json or archive",
+		"1 otp secrets: not supported by csv, use json or archive",
+	}, skipped)
+}
+
+func TestArchive(t *testing.T) {
+	b, err := Seal(testVault(), "archive pass")
+	require.NoError(t, err)
+
+	v, err := Open(b, "archive pass")
+	require.NoError(t, err)
+	require.Equal(t, testVault(), v)
+
+	_, err = Open(b, "wrong")
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	// заголовок защищен от изменения
+	tampered := append([]byte(nil), b...)
+	tampered[len(archiveMagic)+1] ^= 1
+	_, err = Open(tampered, "archive pass")
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	_, err = Open([]byte("plain text"), "archive pass")
+	require.ErrorIs(t, err, ErrNotArchive)
+
+	_, err = Seal(testVault(), "")
+	require.ErrorIs(t, err, ErrEmptyPassword)
+}
@@ -4,25 +4,33 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/Spear5030/yagophkeeper/internal/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
 type Client struct {
 	conn       *grpc.ClientConn
 	yagkclient pb.YaGophKeeperClient
-	token      string
+	mu         sync.Mutex
+	tokens     domain.Tokens
+	// onRefresh сохраняет новую пару токенов после автоматического обновления
+	onRefresh func(domain.Tokens) error
 }
 
-func New(addr string, cert string, token string) *Client {
+func New(addr string, cert string, tokens domain.Tokens, onRefresh func(domain.Tokens) error) *Client {
 	var c Client
 	tlsCredentials, err := loadTLSCredentials(cert)
 	if err != nil {
@@ -32,36 +40,115 @@ func New(addr string, cert string, token string) *Client {
 	conn, err := grpc.Dial(addr,
 		grpc.WithTransportCredentials(tlsCredentials),
 		grpc.WithUnaryInterceptor(c.AuthInterceptor()),
+		grpc.WithStreamInterceptor(c.StreamAuthInterceptor()),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 	c.conn = conn
 	c.yagkclient = pb.NewYaGophKeeperClient(conn)
-	c.token = token
+	c.tokens = tokens
+	c.onRefresh = onRefresh
 	return &c
 }
 
-func (c *Client) RegisterUser(user domain.User) (string, error) {
+func (c *Client) RegisterUser(user domain.User) (domain.Tokens, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	resp, err := c.yagkclient.RegisterUser(ctx, &pb.User{Email: user.Email, Password: user.Password})
 	if err != nil {
-		return "", err
+		return domain.Tokens{}, err
 	}
-	c.token = resp.Token
-	return resp.Token, nil
+	return c.setTokens(resp), nil
 }
 
-func (c *Client) LoginUser(user domain.User) (string, error) {
+func (c *Client) LoginUser(user domain.User) (domain.Tokens, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	resp, err := c.yagkclient.LoginUser(ctx, &pb.User{Email: user.Email, Password: user.Password})
 	if err != nil {
-		return "", err
+		return domain.Tokens{}, err
+	}
+	return c.setTokens(resp), nil
+}
+
+// ChangePassword меняет пароль аккаунта и заменяет токены новыми, выданными сервером
+func (c *Client) ChangePassword(password string, newPassword string) (domain.Tokens, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.ChangePassword(ctx, &pb.ChangePasswordRequest{Password: password, NewPassword: newPassword})
+	if err != nil {
+		return domain.Tokens{}, err
 	}
-	c.token = resp.Token
-	return resp.Token, nil
+	return c.setTokens(resp), nil
+}
+
+// DeleteAccount удаляет аккаунт и все данные пользователя на сервере
+func (c *Client) DeleteAccount(password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_, err := c.yagkclient.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: password})
+	if err != nil {
+		return err
+	}
+	c.setTokens(&pb.AuthResponse{})
+	return nil
+}
+
+// RegisterDevice регистрирует устройство на сервере и заменяет токены входа токенами устройства
+func (c *Client) RegisterDevice(name string) (domain.Tokens, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c.mu.Lock()
+	refresh := c.tokens.Refresh
+	c.mu.Unlock()
+	resp, err := c.yagkclient.RegisterDevice(ctx, &pb.RegisterDeviceRequest{Name: name, RefreshToken: refresh})
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	return c.setTokens(&pb.AuthResponse{Token: resp.Token, RefreshToken: resp.RefreshToken}), nil
+}
+
+// ListDevices возвращает устройства пользователя
+func (c *Client) ListDevices() ([]domain.Device, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.ListDevices(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]domain.Device, 0, len(resp.Devices))
+	for _, d := range resp.Devices {
+		devices = append(devices, domain.Device{
+			ID:        d.Id,
+			Name:      d.Name,
+			CreatedAt: d.CreatedAt.AsTime(),
+			LastSeen:  d.LastSeen.AsTime(),
+			Revoked:   d.Revoked,
+			Current:   d.Current,
+		})
+	}
+	return devices, nil
+}
+
+// RevokeDevice отзывает устройство пользователя
+func (c *Client) RevokeDevice(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_, err := c.yagkclient.RevokeDevice(ctx, &pb.RevokeDeviceRequest{DeviceId: id})
+	return err
+}
+
+// Logout отзывает refresh token на сервере
+func (c *Client) Logout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c.mu.Lock()
+	refresh := c.tokens.Refresh
+	c.tokens = domain.Tokens{}
+	c.mu.Unlock()
+	_, err := c.yagkclient.Logout(ctx, &pb.RefreshTokenRequest{RefreshToken: refresh})
+	return err
 }
 
 func (c *Client) CheckSync(email string) (time.Time, error) {
@@ -96,6 +183,175 @@ func (c *Client) SendData(data []byte) error {
 	return nil
 }
 
+// PushRecords отправляет измененные записи на сервер
+func (c *Client) PushRecords(records []domain.Record) (accepted []domain.Record, conflicts []domain.Record, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.PushRecords(ctx, &pb.PushRecordsRequest{Records: recordsToPB(records)})
+	if err != nil {
+		return nil, nil, err
+	}
+	return recordsFromPB(resp.Accepted), recordsFromPB(resp.Conflicts), nil
+}
+
+// PullRecords получает записи, измененные на сервере после ревизии since
+func (c *Client) PullRecords(since int64) ([]domain.Record, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.PullRecords(ctx, &pb.PullRecordsRequest{SinceRevision: since})
+	if err != nil {
+		return nil, 0, err
+	}
+	return recordsFromPB(resp.Records), resp.Revision, nil
+}
+
+// ListVersions возвращает версии хранилища на сервере, новые первыми
+func (c *Client) ListVersions() ([]domain.VaultVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.ListVersions(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]domain.VaultVersion, 0, len(resp.Versions))
+	for _, v := range resp.Versions {
+		versions = append(versions, versionFromPB(v))
+	}
+	return versions, nil
+}
+
+// GetVersion возвращает версию хранилища и ее записи
+func (c *Client) GetVersion(number int64) (domain.VaultVersion, []domain.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.GetVersion(ctx, &pb.GetVersionRequest{Number: number})
+	if err != nil {
+		return domain.VaultVersion{}, nil, err
+	}
+	return versionFromPB(resp.Version), recordsFromPB(resp.Records), nil
+}
+
+// Rekey заменяет записи на сервере записями, зашифрованными новым мастер-паролем
+func (c *Client) Rekey(revision int64, records []domain.Record, params domain.RecordKeyParams) ([]domain.Record, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	resp, err := c.yagkclient.Rekey(ctx, &pb.RekeyRequest{Revision: revision, Records: recordsToPB(records),
+		KeyCheck: params.KeyCheck, Salt: params.Salt})
+	if err != nil {
+		return nil, 0, err
+	}
+	return recordsFromPB(resp.Accepted), resp.Revision, nil
+}
+
+// GetKeyCheck возвращает соль ключа записей аккаунта и значение для его проверки
+func (c *Client) GetKeyCheck() (domain.RecordKeyParams, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := c.yagkclient.GetKeyCheck(ctx, &emptypb.Empty{})
+	if err != nil {
+		return domain.RecordKeyParams{}, err
+	}
+	return domain.RecordKeyParams{Salt: resp.Salt, KeyCheck: resp.KeyCheck}, nil
+}
+
+// missingChunksBatch сколько id чанков отправляется в одном запросе MissingChunks
+const missingChunksBatch = 10000
+
+// MissingChunks возвращает чанки, которых нет на сервере
+func (c *Client) MissingChunks(ids []string) ([]string, error) {
+	var missing []string
+	for start := 0; start < len(ids); start += missingChunksBatch {
+		end := start + missingChunksBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		resp, err := c.yagkclient.MissingChunks(ctx, &pb.ChunkIDs{Ids: ids[start:end]})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, resp.Ids...)
+	}
+	return missing, nil
+}
+
+// UploadChunks отправляет чанки одним потоком. Данные чанка читаются через read перед отправкой,
+// поэтому в памяти одновременно находится один чанк
+func (c *Client) UploadChunks(ids []string, read func(id string) ([]byte, error)) error {
+	return c.withStreamAuth(func(ctx context.Context) error {
+		stream, err := c.yagkclient.UploadChunks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			data, err := read(id)
+			if err != nil {
+				return err
+			}
+			if err = stream.Send(&pb.Chunk{Id: id, Data: data}); err != nil {
+				// причину обрыва потока возвращает CloseAndRecv
+				break
+			}
+		}
+		_, err = stream.CloseAndRecv()
+		return err
+	})
+}
+
+// DownloadChunks получает чанки потоком и передает каждый в write сразу после получения
+func (c *Client) DownloadChunks(ids []string, write func(id string, data []byte) error) error {
+	return c.withStreamAuth(func(ctx context.Context) error {
+		stream, err := c.yagkclient.DownloadChunks(ctx, &pb.ChunkIDs{Ids: ids})
+		if err != nil {
+			return err
+		}
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err = write(chunk.Id, chunk.Data); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// withStreamAuth выполняет потоковый вызов. Ошибка авторизации потока приходит при чтении,
+// а не при открытии, поэтому токен обновляется здесь и вызов повторяется один раз.
+// Чанки идемпотентны, повтор безопасен
+func (c *Client) withStreamAuth(call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	access := c.accessToken()
+	err := call(ctx)
+	if status.Code(err) != codes.Unauthenticated {
+		return err
+	}
+	if _, errRefresh := c.refresh(ctx, access); errRefresh != nil {
+		return fmt.Errorf("session expired, login again: %w", errRefresh)
+	}
+	return call(ctx)
+}
+
+// StreamAuthInterceptor добавляет JWT к потоковым вызовам
+func (c *Client) StreamAuthInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		return streamer(metadata.AppendToOutgoingContext(ctx, "Bearer", c.accessToken()), desc, cc, method, opts...)
+	}
+}
+
 func (c *Client) AuthInterceptor() grpc.UnaryClientInterceptor {
 	return func(
 		ctx context.Context,
@@ -105,9 +361,94 @@ func (c *Client) AuthInterceptor() grpc.UnaryClientInterceptor {
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
-		ctx = metadata.AppendToOutgoingContext(ctx, "Bearer", c.token)
-		return invoker(ctx, method, req, reply, cc, opts...)
+		if isAuthMethod(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		access := c.accessToken()
+		err := invoker(metadata.AppendToOutgoingContext(ctx, "Bearer", access), method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+		// токен истек или отозван: обновляем пару токенов и повторяем запрос один раз
+		access, errRefresh := c.refresh(ctx, access)
+		if errRefresh != nil {
+			return fmt.Errorf("session expired, login again: %w", errRefresh)
+		}
+		return invoker(metadata.AppendToOutgoingContext(ctx, "Bearer", access), method, req, reply, cc, opts...)
+	}
+}
+
+func (c *Client) accessToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens.Access
+}
+
+func (c *Client) setTokens(resp *pb.AuthResponse) domain.Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = domain.Tokens{Access: resp.Token, Refresh: resp.RefreshToken}
+	return c.tokens
+}
+
+// refresh получает новую пару токенов по refresh token и сохраняет ее через onRefresh.
+// Если пока ждали блокировку токен уже обновил другой запрос, возвращается новый токен
+func (c *Client) refresh(ctx context.Context, expired string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens.Access != expired {
+		return c.tokens.Access, nil
+	}
+	if c.tokens.Refresh == "" {
+		return "", errors.New("no refresh token")
+	}
+	resp, err := c.yagkclient.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: c.tokens.Refresh})
+	if err != nil {
+		return "", err
+	}
+	c.tokens = domain.Tokens{Access: resp.Token, Refresh: resp.RefreshToken}
+	if c.onRefresh != nil {
+		if err = c.onRefresh(c.tokens); err != nil {
+			return "", err
+		}
+	}
+	return c.tokens.Access, nil
+}
+
+// isAuthMethod методы, которые вызываются без JWT
+func isAuthMethod(method string) bool {
+	switch method {
+	case pb.YaGophKeeper_RegisterUser_FullMethodName, pb.YaGophKeeper_LoginUser_FullMethodName,
+		pb.YaGophKeeper_RefreshToken_FullMethodName, pb.YaGophKeeper_Logout_FullMethodName:
+		return true
+	}
+	return false
+}
+
+func recordsFromPB(pbRecords []*pb.Record) []domain.Record {
+	records := make([]domain.Record, 0, len(pbRecords))
+	for _, r := range pbRecords {
+		records = append(records, domain.Record{ID: r.Id, Revision: r.Revision, Data: r.Data, Deleted: r.Deleted, Chunks: r.Chunks})
+	}
+	return records
+}
+
+func versionFromPB(v *pb.VaultVersion) domain.VaultVersion {
+	return domain.VaultVersion{
+		Number:     v.GetNumber(),
+		CreatedAt:  v.GetCreatedAt().AsTime(),
+		DeviceID:   v.GetDeviceId(),
+		DeviceName: v.GetDeviceName(),
+		Records:    v.GetRecords(),
+	}
+}
+
+func recordsToPB(records []domain.Record) []*pb.Record {
+	pbRecords := make([]*pb.Record, 0, len(records))
+	for _, r := range records {
+		pbRecords = append(pbRecords, &pb.Record{Id: r.ID, Revision: r.Revision, Data: r.Data, Deleted: r.Deleted, Chunks: r.Chunks})
 	}
+	return pbRecords
 }
 
 func loadTLSCredentials(cert string) (credentials.TransportCredentials, error) {
@@ -0,0 +1,175 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"sort"
+	"strings"
+	"time"
+)
+
+// типы записей Bitwarden
+const (
+	bwLogin    = 1
+	bwNote     = 2
+	bwCard     = 3
+	bwIdentity = 4
+	bwSSHKey   = 5
+)
+
+// типы пользовательских полей Bitwarden
+const (
+	bwFieldText    = 0
+	bwFieldHidden  = 1
+	bwFieldBoolean = 2
+	bwFieldLinked  = 3
+)
+
+// bwExport незашифрованный JSON экспорт Bitwarden
+type bwExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bwItem `json:"items"`
+}
+
+type bwItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Favorite bool   `json:"favorite"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+	Login struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+		URIs     []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Fido2Credentials []json.RawMessage `json:"fido2Credentials"`
+	} `json:"login"`
+	Card struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	Identity map[string]interface{} `json:"identity"`
+	SSHKey   struct {
+		PrivateKey     string `json:"privateKey"`
+		PublicKey      string `json:"publicKey"`
+		KeyFingerprint string `json:"keyFingerprint"`
+	} `json:"sshKey"`
+	PasswordHistory []json.RawMessage `json:"passwordHistory"`
+	CreationDate    time.Time         `json:"creationDate"`
+}
+
+func parseBitwarden(data []byte) (Result, error) {
+	var export bwExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return Result{}, fmt.Errorf("bitwarden json: %w", err)
+	}
+	if export.Encrypted {
+		return Result{}, errors.New("encrypted bitwarden export is not supported, export as unencrypted json")
+	}
+	folders := make(map[string]string)
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+	var res Result
+	for _, item := range export.Items {
+		res.bwItem(item, folders[item.FolderID])
+	}
+	return res, nil
+}
+
+func (r *Result) bwItem(item bwItem, folder string) {
+	m := domain.Metadata{
+		Title:     item.Name,
+		Folder:    folder,
+		Favorite:  item.Favorite,
+		CreatedAt: item.CreationDate.UTC(),
+	}
+	for _, f := range item.Fields {
+		switch f.Type {
+		case bwFieldText:
+			m.Fields = field(m.Fields, f.Name, f.Value, domain.FieldText)
+		case bwFieldHidden:
+			m.Fields = field(m.Fields, f.Name, f.Value, domain.FieldHidden)
+		case bwFieldBoolean:
+			m.Fields = field(m.Fields, f.Name, f.Value, domain.FieldBoolean)
+		default:
+			r.drop(item.Name, "linked field %s", f.Name)
+		}
+	}
+	if len(item.PasswordHistory) > 0 {
+		r.drop(item.Name, "%d passwords in history", len(item.PasswordHistory))
+	}
+
+	switch item.Type {
+	case bwLogin:
+		for _, u := range item.Login.URIs {
+			m.URLs = append(m.URLs, u.URI)
+		}
+		if len(item.Login.Fido2Credentials) > 0 {
+			r.drop(item.Name, "passkey")
+		}
+		if item.Login.TOTP != "" {
+			r.addOTP(item.Name, item.Login.TOTP, domain.Metadata{Title: item.Name, Folder: folder})
+		}
+		r.Logins = append(r.Logins, domain.LoginPassword{
+			Login:    item.Login.Username,
+			Password: item.Login.Password,
+			Meta:     item.Notes,
+			Metadata: m,
+		})
+	case bwNote:
+		r.Texts = append(r.Texts, domain.TextData{Text: item.Notes, Metadata: m})
+	case bwCard:
+		m.Fields = field(m.Fields, "brand", item.Card.Brand, domain.FieldText)
+		if item.Card.ExpMonth != "" || item.Card.ExpYear != "" {
+			m.Fields = field(m.Fields, "expiry", item.Card.ExpMonth+"/"+item.Card.ExpYear, domain.FieldText)
+		}
+		r.Cards = append(r.Cards, domain.CardData{
+			Number:     item.Card.Number,
+			CardHolder: item.Card.CardholderName,
+			CVC:        item.Card.Code,
+			Meta:       item.Notes,
+			Metadata:   m,
+		})
+	case bwIdentity:
+		// для личных данных нет отдельного типа - сохраняем текстом "поле: значение"
+		keys := make([]string, 0, len(item.Identity))
+		for k, v := range item.Identity {
+			if v != nil && fmt.Sprint(v) != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var lines []string
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("%s: %v", k, item.Identity[k]))
+		}
+		if item.Notes != "" {
+			lines = append(lines, "", item.Notes)
+		}
+		r.Texts = append(r.Texts, domain.TextData{Text: strings.Join(lines, "\n"), Meta: "identity", Metadata: m})
+	case bwSSHKey:
+		m.Fields = field(m.Fields, "public key", item.SSHKey.PublicKey, domain.FieldText)
+		m.Fields = field(m.Fields, "fingerprint", item.SSHKey.KeyFingerprint, domain.FieldText)
+		r.Texts = append(r.Texts, domain.TextData{Text: item.SSHKey.PrivateKey, Meta: item.Notes, Metadata: m})
+	default:
+		r.drop(item.Name, "unknown item type %d", item.Type)
+	}
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"strings"
+)
+
+// csvColumns синонимы заголовков колонок в CSV экспортах разных менеджеров
+var csvColumns = map[string]string{
+	"name":           "title",
+	"title":          "title",
+	"username":       "login",
+	"login":          "login",
+	"login_username": "login",
+	"password":       "password",
+	"login_password": "password",
+	"url":            "url",
+	"uri":            "url",
+	"login_uri":      "url",
+	"website":        "url",
+	"notes":          "notes",
+	"note":           "notes",
+	"extra":          "notes",
+	"comments":       "notes",
+	"totp":           "totp",
+	"login_totp":     "totp",
+	"folder":         "folder",
+	"grouping":       "folder",
+	"group":          "folder",
+	"favorite":       "favorite",
+	"fav":            "favorite",
+	"tags":           "tags",
+	"type":           "type",
+}
+
+var errCSVHeader = errors.New("csv: header must contain title/name and username or password columns")
+
+func parseCSV(data []byte) (Result, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return Result{}, fmt.Errorf("csv: %w", err)
+	}
+	if len(records) == 0 {
+		return Result{}, errCSVHeader
+	}
+	header := records[0]
+	columns := make([]string, len(header))
+	known := make(map[string]bool)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if c, ok := csvColumns[h]; ok && !known[c] {
+			columns[i] = c
+			known[c] = true
+		}
+	}
+	if !known["title"] || !(known["login"] || known["password"]) {
+		return Result{}, errCSVHeader
+	}
+	var res Result
+	for n, rec := range records[1:] {
+		row := make(map[string]string)
+		m := domain.Metadata{}
+		for i, v := range rec {
+			v = strings.TrimSpace(v)
+			if i >= len(header) {
+				if v != "" {
+					res.Dropped = append(res.Dropped, fmt.Sprintf("row %d: extra column %d", n+2, i+1))
+				}
+				continue
+			}
+			if columns[i] == "" {
+				// неизвестные колонки сохраняются пользовательскими полями
+				m.Fields = field(m.Fields, strings.TrimSpace(header[i]), v, domain.FieldText)
+				continue
+			}
+			row[columns[i]] = v
+		}
+		m.Title = row["title"]
+		m.Folder = row["folder"]
+		m.Favorite = row["favorite"] == "1" || strings.EqualFold(row["favorite"], "true")
+		m.Tags = splitTags(row["tags"])
+		// LastPass помечает заметки адресом http://sn
+		if strings.EqualFold(row["type"], "note") || row["url"] == "http://sn" {
+			res.Texts = append(res.Texts, domain.TextData{Text: row["notes"], Metadata: m})
+			continue
+		}
+		if row["url"] != "" {
+			m.URLs = []string{row["url"]}
+		}
+		if row["totp"] != "" {
+			res.addOTP(m.Title, row["totp"], domain.Metadata{Title: m.Title, Tags: m.Tags, Folder: m.Folder})
+		}
+		res.Logins = append(res.Logins, domain.LoginPassword{
+			Login:    row["login"],
+			Password: row["password"],
+			Meta:     row["notes"],
+			Metadata: m,
+		})
+	}
+	return res, nil
+}
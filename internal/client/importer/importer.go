@@ -0,0 +1,87 @@
+// Package importer переносит записи из экспортов других менеджеров паролей в типы domain.
+// Поля, которым нет соответствия, по возможности сохраняются как пользовательские поля,
+// остальное перечисляется в Result.Dropped
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/client/exporter"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/pkg/totp"
+	"strings"
+)
+
+const (
+	KeePassXML    = "keepass-xml"
+	BitwardenJSON = "bitwarden-json"
+	OnePUX        = "1pux"
+	CSV           = "csv"
+	// JSON открытый экспорт yagophkeeper, см. пакет exporter
+	JSON = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// Formats поддерживаемые форматы
+var Formats = []string{KeePassXML, BitwardenJSON, OnePUX, CSV, JSON}
+
+// Result записи, полученные из экспорта
+type Result struct {
+	domain.Vault
+	// Dropped записи и поля, которые не перенесены, в читаемом виде
+	Dropped []string
+}
+
+// Parse разбирает экспорт в формате format
+func Parse(format string, data []byte) (Result, error) {
+	switch format {
+	case KeePassXML:
+		return parseKeePass(data)
+	case BitwardenJSON:
+		return parseBitwarden(data)
+	case OnePUX:
+		return parse1PUX(data)
+	case CSV:
+		return parseCSV(data)
+	case JSON:
+		v, err := exporter.Decode(data)
+		return Result{Vault: v}, err
+	}
+	return Result{}, fmt.Errorf("%w %q, supported: %s", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+}
+
+func (r *Result) drop(title, format string, args ...interface{}) {
+	r.Dropped = append(r.Dropped, fmt.Sprintf("%q: ", title)+fmt.Sprintf(format, args...))
+}
+
+// addOTP добавляет секрет TOTP из otpauth:// URI или base32 секрета записи title
+func (r *Result) addOTP(title, value string, m domain.Metadata) {
+	otp := domain.OTPSecret{Metadata: m}
+	if strings.HasPrefix(value, "otpauth://") {
+		k, err := totp.ParseURI(value)
+		if err != nil {
+			r.drop(title, "totp: %v", err)
+			return
+		}
+		otp.Issuer, otp.Account, otp.Secret = k.Issuer, k.Account, k.Secret
+		otp.Algorithm, otp.Digits, otp.Period = k.Algorithm, k.Digits, k.Period
+	} else {
+		otp.Secret = strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+		otp.Issuer = title
+	}
+	r.OTPs = append(r.OTPs, otp)
+}
+
+// field пользовательское поле, пустые значения не переносятся
+func field(fields []domain.CustomField, name, value string, t domain.FieldType) []domain.CustomField {
+	if value == "" {
+		return fields
+	}
+	return append(fields, domain.CustomField{Name: name, Type: t, Value: value})
+}
+
+// splitTags разбирает теги, разделенные запятыми или точками с запятой
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' })
+}
@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const keepassXML = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<RecycleBinUUID>cmVjeWNsZQ==</RecycleBinUUID>
+		<Binaries>
+			<Binary ID="0" Compressed="False">aGVsbG8=</Binary>
+		</Binaries>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdA==</UUID>
+			<Name>Database</Name>
+			<Group>
+				<UUID>d29yaw==</UUID>
+				<Name>Work</Name>
+				<Entry>
+					<Tags>dev;ci</Tags>
+					<Times><CreationTime>2021-03-04T05:06:07Z</CreationTime></Times>
+					<String><Key>Title</Key><Value>GitHub</Value></String>
+					<String><Key>UserName</Key><Value>alice</Value></String>
+					<String><Key>Password</Key><Value ProtectInMemory="True">hunter2</Value></String>
+					<String><Key>URL</Key><Value>https://github.com</Value></String>
+					<String><Key>Notes</Key><Value>work account</Value></String>
+					<String><Key>otp</Key><Value>otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&amp;issuer=GitHub</Value></String>
+					<String><Key>Recovery</Key><Value ProtectInMemory="True">abcd-efgh</Value></String>
+					<Binary><Key>key.txt</Key><Value Ref="0"/></Binary>
+					<History><Entry/></History>
+				</Entry>
+				<Entry>
+					<String><Key>Title</Key><Value>Wifi</Value></String>
+					<String><Key>Notes</Key><Value>password is 12345</Value></String>
+				</Entry>
+			</Group>
+			<Group>
+				<UUID>cmVjeWNsZQ==</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry><String><Key>Title</Key><Value>Old</Value></String></Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`
+
+func TestParseKeePass(t *testing.T) {
+	res, err := Parse(KeePassXML, []byte(keepassXML))
+	require.NoError(t, err)
+
+	require.Len(t, res.Logins, 1)
+	l := res.Logins[0]
+	require.Equal(t, "alice", l.Login)
+	require.Equal(t, "hunter2", l.Password)
+	require.Equal(t, "work account", l.Meta)
+	require.Equal(t, "GitHub", l.Title)
+	require.Equal(t, "Work", l.Folder)
+	require.Equal(t, []string{"dev", "ci"}, l.Tags)
+	require.Equal(t, []string{"https://github.com"}, l.URLs)
+	require.Equal(t, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), l.CreatedAt)
+	require.Equal(t, []domain.CustomField{{Name: "Recovery", Type: domain.FieldHidden, Value: "abcd-efgh"}}, l.Fields)
+
+	require.Len(t, res.Texts, 1)
+	require.Equal(t, "password is 12345", res.Texts[0].Text)
+
+	require.Len(t, res.OTPs, 1)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", res.OTPs[0].Secret)
+	require.Equal(t, "alice", res.OTPs[0].Account)
+
+	require.Len(t, res.Binaries, 1)
+	require.Equal(t, []byte("hello"), res.Binaries[0].BinaryData)
+	require.Equal(t, "key.txt", res.Binaries[0].Title)
+
+	require.Len(t, res.Dropped, 2)
+	require.Contains(t, res.Dropped[0], "history")
+	require.Contains(t, res.Dropped[1], "Recycle Bin")
+}
+
+func TestKpTime(t *testing.T) {
+	// KDBX 4: base64 little endian секунд от 0001-01-01
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), kpTime("ANid1Q4AAAA="))
+	require.True(t, kpTime("").IsZero())
+}
+
+const bitwardenJSON = `{
+	"encrypted": false,
+	"folders": [{"id": "f1", "name": "Personal"}],
+	"items": [
+		{"type": 1, "name": "Mail", "folderId": "f1", "favorite": true, "notes": "main",
+			"login": {"username": "bob", "password": "secret", "totp": "jbsw y3dp ehpk 3pxp",
+				"uris": [{"uri": "https://mail.example.com"}], "fido2Credentials": [{}]},
+			"fields": [{"name": "pin", "value": "1234", "type": 1}, {"name": "linked", "value": null, "type": 3}],
+			"passwordHistory": [{"password": "old"}]},
+		{"type": 2, "name": "Note", "notes": "some text"},
+		{"type": 3, "name": "Visa", "card": {"cardholderName": "BOB", "brand": "Visa", "number": "4111111111111111",
+			"expMonth": "12", "expYear": "2030", "code": "123"}},
+		{"type": 4, "name": "Me", "identity": {"firstName": "Bob", "lastName": "Smith", "email": null}}
+	]
+}`
+
+func TestParseBitwarden(t *testing.T) {
+	res, err := Parse(BitwardenJSON, []byte(bitwardenJSON))
+	require.NoError(t, err)
+
+	require.Len(t, res.Logins, 1)
+	l := res.Logins[0]
+	require.Equal(t, "bob", l.Login)
+	require.Equal(t, "Personal", l.Folder)
+	require.True(t, l.Favorite)
+	require.Equal(t, []string{"https://mail.example.com"}, l.URLs)
+	require.Equal(t, []domain.CustomField{{Name: "pin", Type: domain.FieldHidden, Value: "1234"}}, l.Fields)
+
+	require.Len(t, res.OTPs, 1)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", res.OTPs[0].Secret)
+	require.Equal(t, "Mail", res.OTPs[0].Issuer)
+
+	require.Len(t, res.Cards, 1)
+	require.Equal(t, "123", res.Cards[0].CVC)
+	require.Contains(t, res.Cards[0].Fields, domain.CustomField{Name: "expiry", Type: domain.FieldText, Value: "12/2030"})
+
+	require.Len(t, res.Texts, 2)
+	require.Equal(t, "some text", res.Texts[0].Text)
+	require.Equal(t, "firstName: Bob\nlastName: Smith", res.Texts[1].Text)
+
+	require.Len(t, res.Dropped, 3)
+
+	_, err = Parse(BitwardenJSON, []byte(`{"encrypted": true}`))
+	require.Error(t, err)
+}
+
+const onePUXData = `{"accounts": [{"vaults": [{"attrs": {"name": "Private"}, "items": [
+	{"favIndex": 1, "createdAt": 1600000000, "state": "active", "categoryUuid": "001",
+		"details": {"loginFields": [{"value": "carol", "designation": "username"}, {"value": "pa$$", "designation": "password"}],
+			"notesPlain": "notes", "sections": [{"fields": [
+				{"title": "one-time password", "id": "otp", "value": {"totp": "otpauth://totp/Site:carol?secret=JBSWY3DPEHPK3PXP"}},
+				{"title": "security answer", "id": "q", "value": {"concealed": "blue"}}]}]},
+		"overview": {"title": "Site", "urls": [{"url": "https://site.example"}], "tags": ["web"]}},
+	{"state": "active", "categoryUuid": "002",
+		"details": {"sections": [{"fields": [
+			{"id": "ccnum", "value": {"creditCardNumber": "5555555555554444"}},
+			{"id": "cvv", "value": {"concealed": "321"}},
+			{"id": "cardholder", "value": {"string": "CAROL"}},
+			{"title": "expiry date", "id": "expiry", "value": {"monthYear": 202712}}]}]},
+		"overview": {"title": "Mastercard"}},
+	{"state": "active", "categoryUuid": "006",
+		"details": {"documentAttributes": {"fileName": "scan.pdf", "documentId": "doc1"}},
+		"overview": {"title": "Passport"}},
+	{"state": "archived", "categoryUuid": "003", "overview": {"title": "Old note"}}
+]}]}]}`
+
+func TestParse1PUX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("export.data")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(onePUXData))
+	require.NoError(t, err)
+	w, err = zw.Create("files/doc1__scan.pdf")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("%PDF"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	res, err := Parse(OnePUX, buf.Bytes())
+	require.NoError(t, err)
+
+	require.Len(t, res.Logins, 1)
+	l := res.Logins[0]
+	require.Equal(t, "carol", l.Login)
+	require.Equal(t, "pa$$", l.Password)
+	require.Equal(t, "Private", l.Folder)
+	require.True(t, l.Favorite)
+	require.Equal(t, time.Unix(1600000000, 0).UTC(), l.CreatedAt)
+	require.Equal(t, []domain.CustomField{{Name: "security answer", Type: domain.FieldHidden, Value: "blue"}}, l.Fields)
+
+	require.Len(t, res.OTPs, 1)
+	require.Equal(t, "carol", res.OTPs[0].Account)
+
+	require.Len(t, res.Cards, 1)
+	c := res.Cards[0]
+	require.Equal(t, "5555555555554444", c.Number)
+	require.Equal(t, "321", c.CVC)
+	require.Equal(t, "CAROL", c.CardHolder)
+	require.Equal(t, []domain.CustomField{{Name: "expiry date", Type: domain.FieldText, Value: "12/2027"}}, c.Fields)
+
+	require.Len(t, res.Binaries, 1)
+	require.Equal(t, []byte("%PDF"), res.Binaries[0].BinaryData)
+	require.Equal(t, "scan.pdf", res.Binaries[0].Title)
+
+	require.Equal(t, []string{`"Old note": archived item`}, res.Dropped)
+}
+
+func TestParseCSV(t *testing.T) {
+	data := "\xef\xbb\xbfurl,username,password,totp,extra,name,grouping,fav,Security Question\n" +
+		"https://a.example,dave,pw1,,note a,A,Home,1,pet\n" +
+		"http://sn,,,,secure note,B,,0,\n"
+	res, err := Parse(CSV, []byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, res.Logins, 1)
+	l := res.Logins[0]
+	require.Equal(t, "dave", l.Login)
+	require.Equal(t, "pw1", l.Password)
+	require.Equal(t, "note a", l.Meta)
+	require.Equal(t, "A", l.Title)
+	require.Equal(t, "Home", l.Folder)
+	require.True(t, l.Favorite)
+	require.Equal(t, []domain.CustomField{{Name: "Security Question", Type: domain.FieldText, Value: "pet"}}, l.Fields)
+
+	require.Len(t, res.Texts, 1)
+	require.Equal(t, "secure note", res.Texts[0].Text)
+
+	_, err = Parse(CSV, []byte("foo,bar\n1,2\n"))
+	require.Error(t, err)
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse("lastpass", nil)
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"io"
+	"strings"
+	"time"
+)
+
+// kpFile незашифрованный XML экспорт KeePass 2.x / KeePassXC
+type kpFile struct {
+	Meta struct {
+		RecycleBinUUID string     `xml:"RecycleBinUUID"`
+		Binaries       []kpBinary `xml:"Binaries>Binary"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []kpGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type kpBinary struct {
+	ID         string `xml:"ID,attr"`
+	Compressed bool   `xml:"Compressed,attr"`
+	Data       string `xml:",chardata"`
+}
+
+type kpGroup struct {
+	UUID    string    `xml:"UUID"`
+	Name    string    `xml:"Name"`
+	Groups  []kpGroup `xml:"Group"`
+	Entries []kpEntry `xml:"Entry"`
+}
+
+type kpEntry struct {
+	Tags  string `xml:"Tags"`
+	Times struct {
+		CreationTime         string `xml:"CreationTime"`
+		LastModificationTime string `xml:"LastModificationTime"`
+	} `xml:"Times"`
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value struct {
+			// ProtectInMemory значение открыто, Protected - зашифровано потоковым шифром базы
+			ProtectInMemory bool   `xml:"ProtectInMemory,attr"`
+			Protected       bool   `xml:"Protected,attr"`
+			Text            string `xml:",chardata"`
+		} `xml:"Value"`
+	} `xml:"String"`
+	Binaries []struct {
+		Key   string `xml:"Key"`
+		Value struct {
+			Ref string `xml:"Ref,attr"`
+		} `xml:"Value"`
+	} `xml:"Binary"`
+	History []kpEntry `xml:"History>Entry"`
+}
+
+func parseKeePass(data []byte) (Result, error) {
+	var f kpFile
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return Result{}, fmt.Errorf("keepass xml: %w", err)
+	}
+	binaries := make(map[string][]byte)
+	var res Result
+	for _, b := range f.Meta.Binaries {
+		content, err := kpBinaryData(b)
+		if err != nil {
+			res.Dropped = append(res.Dropped, fmt.Sprintf("attachment %s: %v", b.ID, err))
+			continue
+		}
+		binaries[b.ID] = content
+	}
+	// корневая группа - сама база, ее имя в путь папки не входит
+	for _, root := range f.Root.Groups {
+		for _, e := range root.Entries {
+			res.kpEntry(e, "", binaries)
+		}
+		for _, g := range root.Groups {
+			res.kpGroup(g, "", f.Meta.RecycleBinUUID, binaries)
+		}
+	}
+	return res, nil
+}
+
+func (r *Result) kpGroup(g kpGroup, parent, recycleBin string, binaries map[string][]byte) {
+	if recycleBin != "" && g.UUID == recycleBin {
+		r.Dropped = append(r.Dropped, fmt.Sprintf("recycle bin %q: %d entries", g.Name, countEntries(g)))
+		return
+	}
+	folder := strings.TrimPrefix(parent+"/"+g.Name, "/")
+	for _, e := range g.Entries {
+		r.kpEntry(e, folder, binaries)
+	}
+	for _, sub := range g.Groups {
+		r.kpGroup(sub, folder, recycleBin, binaries)
+	}
+}
+
+func countEntries(g kpGroup) int {
+	n := len(g.Entries)
+	for _, sub := range g.Groups {
+		n += countEntries(sub)
+	}
+	return n
+}
+
+func (r *Result) kpEntry(e kpEntry, folder string, binaries map[string][]byte) {
+	strs := make(map[string]string)
+	var other []string
+	hidden := make(map[string]bool)
+	for _, s := range e.Strings {
+		if s.Value.Protected {
+			r.drop(strs["Title"], "field %s is encrypted, export database to XML from KeePass", s.Key)
+			continue
+		}
+		if _, ok := strs[s.Key]; !ok {
+			other = append(other, s.Key)
+		}
+		strs[s.Key] = s.Value.Text
+		hidden[s.Key] = s.Value.ProtectInMemory
+	}
+	title := strs["Title"]
+	m := domain.Metadata{
+		Title:     title,
+		Tags:      splitTags(e.Tags),
+		Folder:    folder,
+		CreatedAt: kpTime(e.Times.CreationTime),
+	}
+	if url := strs["URL"]; url != "" {
+		m.URLs = []string{url}
+	}
+	for _, key := range other {
+		switch key {
+		case "Title", "UserName", "Password", "URL", "Notes":
+		case "otp", "TimeOtp-Secret-Base32":
+			r.addOTP(title, strs[key], domain.Metadata{Title: title, Tags: m.Tags, Folder: folder})
+		default:
+			t := domain.FieldText
+			if hidden[key] {
+				t = domain.FieldHidden
+			}
+			m.Fields = field(m.Fields, key, strs[key], t)
+		}
+	}
+	if len(e.History) > 0 {
+		r.drop(title, "%d history versions", len(e.History))
+	}
+	if strs["UserName"] == "" && strs["Password"] == "" && strs["Notes"] != "" {
+		r.Texts = append(r.Texts, domain.TextData{Text: strs["Notes"], Metadata: m})
+	} else {
+		r.Logins = append(r.Logins, domain.LoginPassword{
+			Login:    strs["UserName"],
+			Password: strs["Password"],
+			Meta:     strs["Notes"],
+			Metadata: m,
+		})
+	}
+	for _, b := range e.Binaries {
+		content, ok := binaries[b.Value.Ref]
+		if !ok {
+			r.drop(title, "attachment %s not found", b.Key)
+			continue
+		}
+		r.Binaries = append(r.Binaries, domain.BinaryData{
+			BinaryData: content,
+			Meta:       "attachment of " + title,
+			Metadata:   domain.Metadata{Title: b.Key, Tags: m.Tags, Folder: folder},
+		})
+	}
+}
+
+func kpBinaryData(b kpBinary) ([]byte, error) {
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b.Data))
+	if err != nil {
+		return nil, err
+	}
+	if !b.Compressed {
+		return content, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// kpTime разбирает время KeePass: RFC 3339 в KDBX 3 или base64 секунд от 0001-01-01 в KDBX 4
+func kpTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC()
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != 8 {
+		return time.Time{}
+	}
+	secs := int64(binary.LittleEndian.Uint64(b))
+	// секунды от 0001-01-01 до 1970-01-01
+	const epoch = 62135596800
+	return time.Unix(secs-epoch, 0).UTC()
+}
@@ -0,0 +1,292 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"io"
+	"strings"
+	"time"
+)
+
+// категории записей 1Password
+const (
+	opLogin    = "001"
+	opCard     = "002"
+	opNote     = "003"
+	opPassword = "005"
+	opDocument = "006"
+)
+
+// opExport файл export.data из архива 1PUX
+type opExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []opItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type opItem struct {
+	FavIndex     int    `json:"favIndex"`
+	CreatedAt    int64  `json:"createdAt"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Details      struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Title  string    `json:"title"`
+			Fields []opField `json:"fields"`
+		} `json:"sections"`
+		PasswordHistory    []json.RawMessage `json:"passwordHistory"`
+		DocumentAttributes *opFile           `json:"documentAttributes"`
+	} `json:"details"`
+	Overview struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+		URLs  []struct {
+			URL string `json:"url"`
+		} `json:"urls"`
+		Tags []string `json:"tags"`
+	} `json:"overview"`
+}
+
+type opField struct {
+	Title string `json:"title"`
+	ID    string `json:"id"`
+	// Value объект из одного ключа - типа значения: {"concealed": "..."}, {"monthYear": 202401} ...
+	Value map[string]json.RawMessage `json:"value"`
+}
+
+type opFile struct {
+	FileName   string `json:"fileName"`
+	DocumentID string `json:"documentId"`
+}
+
+func parse1PUX(data []byte) (Result, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Result{}, fmt.Errorf("1pux: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	var exportFile *zip.File
+	for _, f := range zr.File {
+		if f.Name == "export.data" {
+			exportFile = f
+		}
+		if name, ok := strings.CutPrefix(f.Name, "files/"); ok {
+			// вложения лежат как files/<documentId>__<fileName>
+			id, _, _ := strings.Cut(name, "__")
+			files[id] = f
+		}
+	}
+	if exportFile == nil {
+		return Result{}, fmt.Errorf("1pux: export.data not found")
+	}
+	b, err := readZip(exportFile)
+	if err != nil {
+		return Result{}, fmt.Errorf("1pux: %w", err)
+	}
+	var export opExport
+	if err = json.Unmarshal(b, &export); err != nil {
+		return Result{}, fmt.Errorf("1pux: %w", err)
+	}
+	var res Result
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				res.opItem(item, vault.Attrs.Name, files)
+			}
+		}
+	}
+	return res, nil
+}
+
+func readZip(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (r *Result) opItem(item opItem, vault string, files map[string]*zip.File) {
+	title := item.Overview.Title
+	if item.State == "archived" {
+		r.drop(title, "archived item")
+		return
+	}
+	m := domain.Metadata{
+		Title:    title,
+		Tags:     item.Overview.Tags,
+		Folder:   vault,
+		Favorite: item.FavIndex > 0,
+	}
+	if item.CreatedAt > 0 {
+		m.CreatedAt = time.Unix(item.CreatedAt, 0).UTC()
+	}
+	for _, u := range item.Overview.URLs {
+		m.URLs = append(m.URLs, u.URL)
+	}
+	if len(m.URLs) == 0 && item.Overview.URL != "" {
+		m.URLs = []string{item.Overview.URL}
+	}
+	if len(item.Details.PasswordHistory) > 0 {
+		r.drop(title, "%d passwords in history", len(item.Details.PasswordHistory))
+	}
+
+	var login, password string
+	for _, f := range item.Details.LoginFields {
+		switch f.Designation {
+		case "username":
+			login = f.Value
+		case "password":
+			password = f.Value
+		default:
+			m.Fields = field(m.Fields, f.Name, f.Value, domain.FieldText)
+		}
+	}
+	if password == "" {
+		password = item.Details.Password
+	}
+
+	// поля карты лежат в секциях с известными id
+	card := domain.CardData{Meta: item.Details.NotesPlain}
+	for _, section := range item.Details.Sections {
+		for _, f := range section.Fields {
+			name := f.Title
+			if name == "" {
+				name = f.ID
+			}
+			kind, value, ok := opValue(f.Value)
+			if !ok {
+				r.drop(title, "field %s of type %s", name, kind)
+				continue
+			}
+			switch {
+			case value == "":
+			case item.CategoryUUID == opCard && f.ID == "ccnum":
+				card.Number = value
+			case item.CategoryUUID == opCard && f.ID == "cvv":
+				card.CVC = value
+			case item.CategoryUUID == opCard && f.ID == "cardholder":
+				card.CardHolder = value
+			case kind == "totp":
+				r.addOTP(title, value, domain.Metadata{Title: title, Tags: m.Tags, Folder: vault})
+			case kind == "file":
+				r.opAttachment(title, f.Value, m, files)
+			case kind == "concealed":
+				m.Fields = field(m.Fields, name, value, domain.FieldHidden)
+			default:
+				m.Fields = field(m.Fields, name, value, domain.FieldText)
+			}
+		}
+	}
+
+	switch item.CategoryUUID {
+	case opLogin, opPassword:
+		r.Logins = append(r.Logins, domain.LoginPassword{
+			Login:    login,
+			Password: password,
+			Meta:     item.Details.NotesPlain,
+			Metadata: m,
+		})
+	case opCard:
+		card.Metadata = m
+		r.Cards = append(r.Cards, card)
+	case opDocument:
+		if item.Details.DocumentAttributes == nil {
+			r.drop(title, "document without file")
+			return
+		}
+		raw, _ := json.Marshal(item.Details.DocumentAttributes)
+		r.opAttachment(title, map[string]json.RawMessage{"file": raw}, m, files)
+	default:
+		// заметки и категории без отдельного типа (личные данные, сервера, лицензии) сохраняем текстом
+		if item.CategoryUUID != opNote {
+			m.Fields = field(m.Fields, "username", login, domain.FieldText)
+			m.Fields = field(m.Fields, "password", password, domain.FieldHidden)
+		}
+		r.Texts = append(r.Texts, domain.TextData{Text: item.Details.NotesPlain, Metadata: m})
+	}
+}
+
+// opAttachment добавляет вложение из архива
+func (r *Result) opAttachment(title string, value map[string]json.RawMessage, m domain.Metadata, files map[string]*zip.File) {
+	var file opFile
+	if err := json.Unmarshal(value["file"], &file); err != nil {
+		r.drop(title, "attachment: %v", err)
+		return
+	}
+	zf, ok := files[file.DocumentID]
+	if !ok {
+		r.drop(title, "attachment %s not found in archive", file.FileName)
+		return
+	}
+	content, err := readZip(zf)
+	if err != nil {
+		r.drop(title, "attachment %s: %v", file.FileName, err)
+		return
+	}
+	r.Binaries = append(r.Binaries, domain.BinaryData{
+		BinaryData: content,
+		Meta:       "attachment of " + title,
+		Metadata:   domain.Metadata{Title: file.FileName, Tags: m.Tags, Folder: m.Folder},
+	})
+}
+
+// opValue возвращает тип и строковое значение поля секции. ok=false - значение не переносится
+func opValue(value map[string]json.RawMessage) (string, string, bool) {
+	for kind, raw := range value {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return kind, s, true
+		}
+		switch kind {
+		case "monthYear":
+			var n int
+			if json.Unmarshal(raw, &n) == nil && n > 0 {
+				return kind, fmt.Sprintf("%02d/%d", n%100, n/100), true
+			}
+		case "date":
+			var n int64
+			if json.Unmarshal(raw, &n) == nil {
+				return kind, time.Unix(n, 0).UTC().Format(time.DateOnly), true
+			}
+		case "email":
+			var email struct {
+				Address string `json:"email_address"`
+			}
+			if json.Unmarshal(raw, &email) == nil {
+				return kind, email.Address, true
+			}
+		case "address":
+			var addr map[string]string
+			if json.Unmarshal(raw, &addr) == nil {
+				var parts []string
+				for _, k := range []string{"street", "city", "state", "zip", "country"} {
+					if addr[k] != "" {
+						parts = append(parts, addr[k])
+					}
+				}
+				return kind, strings.Join(parts, ", "), true
+			}
+		case "file":
+			return kind, "file", true
+		}
+		return kind, "", false
+	}
+	return "", "", true
+}
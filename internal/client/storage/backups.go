@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultBackups количество резервных копий файла хранилища по умолчанию
+const DefaultBackups = 10
+
+const backupLayout = "20060102T150405.000000000Z"
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Перед каждой записью файла хранилища его текущее содержимое копируется в каталог <файл>.backups
+// под именем - временем копии в UTC. Копии зашифрованы тем же ключом, что и файл, хранятся последние keepBackups.
+// Содержимое binary в копии не входит: недостающие чанки загружаются с сервера при синхронизации
+
+func (s *storage) backupDir() string {
+	return s.filename + ".backups"
+}
+
+// SetKeepBackups задает количество хранимых резервных копий, 0 отключает копирование
+func (s *storage) SetKeepBackups(n int) {
+	s.keepBackups = n
+}
+
+// replaceFile атомарно заменяет файл хранилища на data, предварительно сохранив резервную копию.
+// Ошибка копирования не мешает записи - потерять изменение хуже, чем пропустить копию
+func (s *storage) replaceFile(data []byte) error {
+	if err := s.backupFile(); err != nil {
+		s.logger.Error("backup vault file error", zap.Error(err))
+	}
+	return writeFileAtomic(s.filename, data, 0600)
+}
+
+// backupFile копирует текущий файл хранилища в каталог копий и удаляет самые старые копии
+func (s *storage) backupFile() error {
+	if s.keepBackups <= 0 {
+		return nil
+	}
+	raw, err := afero.ReadFile(appFs, s.filename)
+	if errors.Is(err, os.ErrNotExist) || len(raw) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = appFs.MkdirAll(s.backupDir(), 0700); err != nil {
+		return err
+	}
+	// имена должны быть уникальны и при записи чаще разрешения часов
+	t := time.Now().UTC()
+	path := filepath.Join(s.backupDir(), t.Format(backupLayout))
+	for _, errStat := appFs.Stat(path); errStat == nil; _, errStat = appFs.Stat(path) {
+		t = t.Add(time.Nanosecond)
+		path = filepath.Join(s.backupDir(), t.Format(backupLayout))
+	}
+	if err = writeFileAtomic(path, raw, 0600); err != nil {
+		return err
+	}
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) <= s.keepBackups {
+		return err
+	}
+	for _, b := range backups[s.keepBackups:] {
+		if err = appFs.Remove(filepath.Join(s.backupDir(), b.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBackups возвращает резервные копии файла хранилища, новые первыми
+func (s *storage) ListBackups() ([]domain.Backup, error) {
+	files, err := afero.ReadDir(appFs, s.backupDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []domain.Backup
+	for _, f := range files {
+		t, errParse := time.Parse(backupLayout, f.Name())
+		if errParse != nil || f.IsDir() {
+			continue
+		}
+		backups = append(backups, domain.Backup{Name: f.Name(), CreatedAt: t, Size: f.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// RestoreBackup заменяет хранилище резервной копией name. Копия сначала расшифровывается,
+// поэтому поврежденная или зашифрованная другим паролем копия не заменит файл.
+// Текущий файл сам сохраняется в копию, так что восстановление можно отменить
+func (s *storage) RestoreBackup(name string) error {
+	if _, err := time.Parse(backupLayout, name); err != nil {
+		return ErrBackupNotFound
+	}
+	raw, err := afero.ReadFile(appFs, filepath.Join(s.backupDir(), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBackupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	restored := newStorage(s.filename, s.masterPass, s.keyring, s.logger)
+	restored.keepBackups = s.keepBackups
+	if _, err = restored.decodeFile(raw); err != nil {
+		return err
+	}
+	if err = restored.writeFile(); err != nil {
+		return err
+	}
+	*s = *restored
+	return nil
+}
+
+// writeFileAtomic записывает data во временный файл рядом с path, сбрасывает его на диск и переименовывает в path,
+// поэтому при сбое на диске остается либо прежний, либо новый файл целиком
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	f, err := appFs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		appFs.Remove(tmp)
+		return err
+	}
+	if err = appFs.Rename(tmp, path); err != nil {
+		return err
+	}
+	// переименование надежно только после сброса каталога
+	dir, err := appFs.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
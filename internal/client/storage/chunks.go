@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ChunkSize размер открытого содержимого одного чанка
+const ChunkSize = 1 << 20
+
+var (
+	ErrChunkNotFound  = errors.New("binary content is not downloaded: sync and try again")
+	ErrChunkCorrupted = errors.New("binary chunk is corrupted")
+)
+
+// Содержимое binary хранится вне файла хранилища, в каталоге <файл>.chunks. Каждая запись шифруется
+// своим случайным ключом ChunkKey по ChunkSize байт, чанк - nonce и шифротекст AES-GCM.
+// Имя чанка - sha256 шифротекста, поэтому сервер проверяет целостность, не зная ключа,
+// а порядок и состав чанков защищены тем, что их список хранится в зашифрованной записи
+
+func (s *storage) chunkDir() string {
+	return s.filename + ".chunks"
+}
+
+func (s *storage) chunkPath(id string) string {
+	return filepath.Join(s.chunkDir(), id)
+}
+
+// chunkID возвращает id чанка по зашифрованным данным
+func chunkID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteBinaryContent шифрует содержимое r новым ключом и сохраняет его чанками.
+// Возвращает id чанков, ключ и размер содержимого для записи domain.BinaryData
+func (s *storage) WriteBinaryContent(r io.Reader) (chunks []string, key []byte, size int64, err error) {
+	key = make([]byte, keySize)
+	if _, err = io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, 0, err
+	}
+	buf := make([]byte, ChunkSize)
+	for {
+		n, errRead := io.ReadFull(r, buf)
+		if n > 0 {
+			sealed, err := seal(key, buf[:n], nil)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			id := chunkID(sealed)
+			if err = s.PutChunk(id, sealed); err != nil {
+				return nil, nil, 0, err
+			}
+			chunks = append(chunks, id)
+			size += int64(n)
+		}
+		if errRead == io.EOF || errRead == io.ErrUnexpectedEOF {
+			return chunks, key, size, nil
+		}
+		if errRead != nil {
+			return nil, nil, 0, errRead
+		}
+	}
+}
+
+// ReadBinaryContent расшифровывает содержимое binary в w. Записи старого формата хранят содержимое в BinaryData
+func (s *storage) ReadBinaryContent(bd domain.BinaryData, w io.Writer) error {
+	if len(bd.ChunkKey) == 0 {
+		_, err := w.Write(bd.BinaryData)
+		return err
+	}
+	for _, id := range bd.Chunks {
+		data, err := s.GetChunk(id)
+		if err != nil {
+			return err
+		}
+		plain, err := open(bd.ChunkKey, data, nil)
+		if err != nil {
+			return ErrChunkCorrupted
+		}
+		if _, err = w.Write(plain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChunk возвращает зашифрованный чанк
+func (s *storage) GetChunk(id string) ([]byte, error) {
+	data, err := afero.ReadFile(appFs, s.chunkPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrChunkNotFound
+	}
+	return data, err
+}
+
+// PutChunk проверяет и сохраняет зашифрованный чанк. Чанк пишется во временный файл и переименовывается,
+// поэтому после обрыва загрузки в каталоге остаются только целые чанки
+func (s *storage) PutChunk(id string, data []byte) error {
+	if chunkID(data) != id {
+		return ErrChunkCorrupted
+	}
+	path := s.chunkPath(id)
+	if _, err := appFs.Stat(path); err == nil {
+		return nil
+	}
+	if err := appFs.MkdirAll(s.chunkDir(), 0700); err != nil {
+		return err
+	}
+	if err := afero.WriteFile(appFs, path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return appFs.Rename(path+".tmp", path)
+}
+
+// MissingChunks возвращает чанки из ids, которых нет локально
+func (s *storage) MissingChunks(ids []string) []string {
+	var missing []string
+	for _, id := range ids {
+		if _, err := appFs.Stat(s.chunkPath(id)); err != nil {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// ChunkRefs возвращает чанки всех binary
+func (s *storage) ChunkRefs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, bd := range s.bds {
+		for _, id := range bd.Chunks {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// removeUnusedChunks удаляет чанки, на которые не ссылается ни одна запись.
+// Удаленные так чанки синхронизированных записей остаются на сервере
+func (s *storage) removeUnusedChunks() {
+	files, err := afero.ReadDir(appFs, s.chunkDir())
+	if err != nil {
+		return
+	}
+	used := make(map[string]bool)
+	for _, id := range s.ChunkRefs() {
+		used[id] = true
+	}
+	for _, f := range files {
+		// временные файлы могут принадлежать загрузке в другом процессе
+		if used[f.Name()] || f.IsDir() || len(f.Name()) != sha256.Size*2 {
+			continue
+		}
+		if err = appFs.Remove(filepath.Join(s.chunkDir(), f.Name())); err != nil {
+			s.logger.Debug("remove chunk", zap.String("name", f.Name()), zap.Error(err))
+		}
+	}
+}
+
+// recordChunks id чанков binary для отправки вместе с записью: по ним сервер знает, какие чанки еще нужны
+func recordChunks(value interface{}) []string {
+	if bd, ok := value.(domain.BinaryData); ok {
+		return bd.Chunks
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/argon2"
+	"io"
+	"strings"
+)
+
+var (
+	ErrWrongMasterPass = errors.New("wrong master password")
+	ErrNoEmail         = errors.New("login required: records are encrypted with a key bound to account email")
+)
+
+// kdfMagic отмечает файл версии 1, см. format.go
+var kdfMagic = []byte("GKK1")
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// kdfParams параметры Argon2id. Хранятся в открытом виде в начале файла
+type kdfParams struct {
+	Salt    []byte
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// defaultKDFParams рекомендованные RFC 9106 параметры для сред с ограниченной памятью
+func defaultKDFParams() (kdfParams, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return kdfParams{}, err
+	}
+	return kdfParams{Salt: salt, Time: 3, Memory: 64 * 1024, Threads: 4}, nil
+}
+
+// recordKDFParams параметры ключа записей для синхронизации. Соль - случайная соль аккаунта с сервера,
+// общая для всех устройств пользователя. Аккаунты, созданные до ее появления, используют соль из email,
+// пока мастер-пароль не будет сменен
+func recordKDFParams(email string, salt []byte) kdfParams {
+	if len(salt) == 0 {
+		legacy := sha256.Sum256([]byte("yagophkeeper:" + strings.ToLower(email)))
+		salt = legacy[:saltSize]
+	}
+	return kdfParams{Salt: salt, Time: 3, Memory: 64 * 1024, Threads: 4}
+}
+
+// deriveKey получает ключ AES-256 из мастер-пароля любой длины
+func deriveKey(masterPass string, p kdfParams) []byte {
+	return argon2.IDKey([]byte(masterPass), p.Salt, p.Time, p.Memory, p.Threads, keySize)
+}
+
+// marshal кодирует параметры: длина соли (1 байт), соль, time (4 байта), memory (4 байта), threads (1 байт)
+func (p kdfParams) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(len(p.Salt)))
+	buf.Write(p.Salt)
+	_ = binary.Write(&buf, binary.BigEndian, p.Time)
+	_ = binary.Write(&buf, binary.BigEndian, p.Memory)
+	buf.WriteByte(p.Threads)
+	return buf.Bytes()
+}
+
+// unmarshalKDFParams раскодирует параметры и возвращает число прочитанных байт
+func unmarshalKDFParams(b []byte) (kdfParams, int, error) {
+	if len(b) < 1 || len(b) < 1+int(b[0])+9 {
+		return kdfParams{}, 0, errors.New("kdf params too short")
+	}
+	n := 1 + int(b[0])
+	p := kdfParams{
+		Salt:    append([]byte(nil), b[1:n]...),
+		Time:    binary.BigEndian.Uint32(b[n:]),
+		Memory:  binary.BigEndian.Uint32(b[n+4:]),
+		Threads: b[n+8],
+	}
+	if p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return kdfParams{}, 0, errors.New("invalid kdf params")
+	}
+	return p, n + 9, nil
+}
+
+// getRecordKey возвращает ключ шифрования записей для синхронизации
+func (s *storage) getRecordKey() ([]byte, error) {
+	if s.Email == "" {
+		return nil, ErrNoEmail
+	}
+	if s.masterPass == "" {
+		return nil, ErrLocked
+	}
+	if s.recordKey == nil || s.recordKeyEmail != s.Email {
+		s.recordKey = deriveKey(s.masterPass, recordKDFParams(s.Email, s.RecordSalt))
+		s.recordKeyEmail = s.Email
+	}
+	return s.recordKey, nil
+}
+
+// encryptRecord шифрует запись для отправки на сервер
+func (s *storage) encryptRecord(b []byte) ([]byte, error) {
+	if s.Email != "" && s.masterPass == "" && s.keyring != nil {
+		return s.keyring.Seal(s.recordKeyID(), b, nil)
+	}
+	key, err := s.getRecordKey()
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, b, nil)
+}
+
+// decryptRecord расшифровывает запись, полученную с сервера
+func (s *storage) decryptRecord(b []byte) ([]byte, error) {
+	if s.Email != "" && s.masterPass == "" && s.keyring != nil {
+		return s.keyring.Open(s.recordKeyID(), b, nil)
+	}
+	key, err := s.getRecordKey()
+	if err != nil {
+		return nil, err
+	}
+	return open(key, b, nil)
+}
+
+// seal шифрует AES-GCM, случайный nonce записывается перед шифротекстом.
+// additionalData не шифруется, но защищается от изменения
+func seal(key []byte, b []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aesGCM.Seal(nonce, nonce, b, additionalData), nil
+}
+
+// open расшифровывает данные, зашифрованные seal
+func open(key []byte, b []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aesGCM.NonceSize()
+	if len(b) < nonceSize {
+		return nil, ErrWrongMasterPass
+	}
+	decrypted, err := aesGCM.Open(nil, b[:nonceSize], b[nonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrWrongMasterPass
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+)
+
+// Формат файла хранилища.
+//
+// Файл состоит из открытого заголовка контейнера и зашифрованного содержимого.
+// Целые числа записываются в big endian.
+//
+//	magic       4 байта  "GKVF"
+//	version     uint16   версия формата, formatVersion
+//	kdf         uint8    алгоритм получения ключа из мастер-пароля: 1 - Argon2id
+//	cipher      uint8    алгоритм шифрования: 1 - AES-256-GCM
+//	kdf length  uint16   длина параметров KDF
+//	kdf params  ...      для Argon2id: длина соли (uint8), соль, time (uint32), memory в KiB (uint32), threads (uint8)
+//	payload     ...      nonce (12 байт) и шифротекст AES-GCM.
+//	                     Все байты заголовка до payload аутентифицируются как additional data.
+//
+// Расшифрованное содержимое - последовательность фреймов:
+//
+//	type    uint8   frameHeaders (0) - служебные поля fileHeaders, иначе тип секрета (TypeLoginPassword и т.д.)
+//	length  uint32  длина данных
+//	data    ...     gob структуры
+//
+// Первый фрейм всегда frameHeaders. Фреймы неизвестных типов сохраняются как есть
+// и записываются обратно, чтобы файл, открытый старым клиентом, не терял новые типы записей.
+//
+// Предыдущие версии читаются и при первом открытии перезаписываются в текущей:
+//   - версия 0: весь файл зашифрован AES-GCM с мастер-паролем в качестве ключа,
+//     за gob служебных полей и каждой записи следует разделитель - байт 30;
+//   - версия 1: перед шифротекстом версии 0 записаны "GKK1" и параметры Argon2id.
+const (
+	formatVersion   uint16 = 2
+	kdfArgon2id     uint8  = 1
+	cipherAES256GCM uint8  = 1
+
+	frameHeaders    byte = 0
+	frameHeaderSize      = 5
+)
+
+var formatMagic = []byte("GKVF")
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported vault file format")
+	ErrCorruptedFile     = errors.New("corrupted vault file")
+)
+
+// containerHeader возвращает открытый заголовок контейнера
+func (s *storage) containerHeader() []byte {
+	params := s.kdf.marshal()
+	var buf bytes.Buffer
+	buf.Write(formatMagic)
+	_ = binary.Write(&buf, binary.BigEndian, formatVersion)
+	buf.WriteByte(kdfArgon2id)
+	buf.WriteByte(cipherAES256GCM)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(params)))
+	buf.Write(params)
+	return buf.Bytes()
+}
+
+// encodeContainer возвращает содержимое файла в текущем формате
+func (s *storage) encodeContainer() ([]byte, error) {
+	payload, err := s.makePayload()
+	if err != nil {
+		return nil, err
+	}
+	header := s.containerHeader()
+	sealed, err := s.sealFile(payload, header)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// decodeContainer проверяет заголовок контейнера, получает ключ из мастер-пароля
+// и возвращает расшифрованное содержимое
+func (s *storage) decodeContainer(raw []byte) ([]byte, error) {
+	pos := len(formatMagic)
+	if len(raw) < pos+6 {
+		return nil, ErrCorruptedFile
+	}
+	version := binary.BigEndian.Uint16(raw[pos:])
+	kdfID, cipherID := raw[pos+2], raw[pos+3]
+	paramsLen := int(binary.BigEndian.Uint16(raw[pos+4:]))
+	pos += 6
+	if version != formatVersion || kdfID != kdfArgon2id || cipherID != cipherAES256GCM {
+		return nil, fmt.Errorf("%w: version %d, kdf %d, cipher %d", ErrUnsupportedFormat, version, kdfID, cipherID)
+	}
+	if len(raw) < pos+paramsLen {
+		return nil, ErrCorruptedFile
+	}
+	p, _, err := unmarshalKDFParams(raw[pos : pos+paramsLen])
+	if err != nil {
+		return nil, err
+	}
+	pos += paramsLen
+	if err = s.setFileKey(p); err != nil {
+		return nil, err
+	}
+	return s.openFile(raw[pos:], raw[:pos])
+}
+
+// makePayload кодирует служебные поля и записи во фреймы
+func (s *storage) makePayload() ([]byte, error) {
+	s.Version = int32(formatVersion)
+	s.CryptoAlg = int32(cipherAES256GCM)
+	var headers bytes.Buffer
+	if err := gob.NewEncoder(&headers).Encode(s.fileHeaders); err != nil {
+		s.logger.Debug(err.Error())
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeFrame(&buf, frameHeaders, headers.Bytes())
+	for _, sc := range s.secrets() {
+		b, err := encodeSecret(sc.secretType, sc.value)
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return nil, err
+		}
+		writeFrame(&buf, b[0], b[1:])
+	}
+	for _, f := range s.unknownFrames {
+		buf.Write(f)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFrame(buf *bytes.Buffer, frameType byte, data []byte) {
+	buf.WriteByte(frameType)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+// readFrames читает служебные поля и записи из расшифрованного содержимого текущего формата
+func (s *storage) readFrames(b []byte) error {
+	first := true
+	for len(b) > 0 {
+		if len(b) < frameHeaderSize {
+			return ErrCorruptedFile
+		}
+		frameType := b[0]
+		n := int(binary.BigEndian.Uint32(b[1:frameHeaderSize]))
+		if len(b) < frameHeaderSize+n {
+			return ErrCorruptedFile
+		}
+		frame, data := b[:frameHeaderSize+n], b[frameHeaderSize:frameHeaderSize+n]
+		b = b[frameHeaderSize+n:]
+		if first {
+			if frameType != frameHeaders {
+				return ErrCorruptedFile
+			}
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s.fileHeaders); err != nil {
+				s.logger.Debug(err.Error())
+				return err
+			}
+			if s.CryptoAlg != int32(cipherAES256GCM) {
+				return fmt.Errorf("%w: cipher %d", ErrUnsupportedFormat, s.CryptoAlg)
+			}
+			first = false
+			continue
+		}
+		if !isSecretType(frameType) {
+			s.logger.Debug("unknown frame type", zap.Uint8("type", frameType))
+			s.unknownFrames = append(s.unknownFrames, append([]byte(nil), frame...))
+			continue
+		}
+		value, err := decodeValue(frameType, gob.NewDecoder(bytes.NewReader(data)))
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return err
+		}
+		if err = s.putSecret(frameType, value, true); err != nil {
+			return err
+		}
+	}
+	if first {
+		return ErrCorruptedFile
+	}
+	return nil
+}
+
+// readLegacyBody читает содержимое версий 0 и 1: gob служебных полей и записей с разделителем 30
+func (s *storage) readLegacyBody(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	err := s.readHeaders(buf)
+	if err != nil {
+		return err
+	}
+	for buf.Len() > 0 {
+		secretType, err := buf.ReadByte()
+		if err != nil {
+			return err
+		}
+		value, err := decodeValue(secretType, gob.NewDecoder(buf))
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return err
+		}
+		_, err = buf.ReadByte() // record separator
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err = s.putSecret(secretType, value, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isSecretType(t byte) bool {
+	switch t {
+	case TypeLoginPassword, TypeText, TypeBinary, TypeCard, TypeOTP:
+		return true
+	}
+	return false
+}
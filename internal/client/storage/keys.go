@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var ErrLocked = errors.New("vault is locked: set GK_MASTER or restart client agent")
+
+// Keys ключи разблокированного хранилища. Агент держит их в памяти и шифрует ими данные
+// по запросам клиентов, чтобы не вводить мастер-пароль и не выполнять Argon2id при каждом запуске.
+// Сами ключи из агента не выдаются
+type Keys struct {
+	// KDF параметры Argon2id, с которыми получен Key
+	KDF []byte
+	Key []byte
+	// RecordKey ключ записей для Email и RecordSalt, пустой до входа в аккаунт
+	Email      string
+	RecordSalt []byte
+	RecordKey  []byte
+}
+
+// KeyID определяет ключ для операции: ключ файла по параметрам KDF
+// или, если KDF пуст, ключ записей по Email и RecordSalt
+type KeyID struct {
+	KDF        []byte
+	Email      string
+	RecordSalt []byte
+}
+
+// Keyring шифрует и расшифровывает данные ключами разблокированного хранилища, не отдавая сами ключи
+type Keyring interface {
+	Seal(id KeyID, b []byte, additionalData []byte) ([]byte, error)
+	Open(id KeyID, b []byte, additionalData []byte) ([]byte, error)
+}
+
+// key возвращает ключ для id или ErrLocked, если такого ключа нет
+func (k Keys) key(id KeyID) ([]byte, error) {
+	if len(id.KDF) > 0 {
+		if k.Key == nil || !bytes.Equal(k.KDF, id.KDF) {
+			return nil, ErrLocked
+		}
+		return k.Key, nil
+	}
+	if k.RecordKey == nil || !strings.EqualFold(k.Email, id.Email) || !bytes.Equal(k.RecordSalt, id.RecordSalt) {
+		return nil, ErrLocked
+	}
+	return k.RecordKey, nil
+}
+
+// Seal шифрует b ключом id
+func (k Keys) Seal(id KeyID, b []byte, additionalData []byte) ([]byte, error) {
+	key, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, b, additionalData)
+}
+
+// Open расшифровывает b ключом id
+func (k Keys) Open(id KeyID, b []byte, additionalData []byte) ([]byte, error) {
+	key, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+	return open(key, b, additionalData)
+}
+
+// ExportKeys возвращает ключи хранилища, открытого мастер-паролем
+func (s *storage) ExportKeys() (Keys, error) {
+	if s.key == nil {
+		return Keys{}, ErrLocked
+	}
+	keys := Keys{
+		KDF:        s.kdf.marshal(),
+		Key:        append([]byte(nil), s.key...),
+		Email:      s.Email,
+		RecordSalt: append([]byte(nil), s.RecordSalt...),
+	}
+	if s.Email != "" {
+		recordKey, err := s.getRecordKey()
+		if err != nil {
+			return Keys{}, err
+		}
+		keys.RecordKey = append([]byte(nil), recordKey...)
+	}
+	return keys, nil
+}
+
+// setFileKey получает ключ файла для параметров p из мастер-пароля.
+// Без мастер-пароля ключ остается в агенте, и файл шифруется через keyring
+func (s *storage) setFileKey(p kdfParams) error {
+	s.kdf = p
+	s.key = nil
+	if s.masterPass != "" {
+		s.key = deriveKey(s.masterPass, p)
+		return nil
+	}
+	if s.keyring == nil {
+		return ErrLocked
+	}
+	return nil
+}
+
+// sealFile шифрует ключом файла
+func (s *storage) sealFile(b []byte, additionalData []byte) ([]byte, error) {
+	if s.key == nil {
+		if s.keyring == nil {
+			return nil, ErrLocked
+		}
+		return s.keyring.Seal(KeyID{KDF: s.kdf.marshal()}, b, additionalData)
+	}
+	return seal(s.key, b, additionalData)
+}
+
+// openFile расшифровывает ключом файла
+func (s *storage) openFile(b []byte, additionalData []byte) ([]byte, error) {
+	if s.key == nil {
+		if s.keyring == nil {
+			return nil, ErrLocked
+		}
+		return s.keyring.Open(KeyID{KDF: s.kdf.marshal()}, b, additionalData)
+	}
+	return open(s.key, b, additionalData)
+}
+
+// recordKeyID идентификатор ключа записей текущего аккаунта в агенте
+func (s *storage) recordKeyID() KeyID {
+	return KeyID{Email: s.Email, RecordSalt: s.RecordSalt}
+}
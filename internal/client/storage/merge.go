@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var ErrConflictNotFound = errors.New("conflict not found")
+
+// mergeRecord объединяет локальную и серверную версии измененной с обеих сторон записи.
+// Если слияние невозможно, серверная версия сохраняется в Conflicts до решения пользователя.
+func (s *storage) mergeRecord(r domain.Record, local secret, remote []byte) error {
+	if r.Deleted {
+		s.Conflicts[r.ID] = r
+		return nil
+	}
+	_, remoteValue, err := decodeSecret(remote)
+	if err != nil {
+		s.logger.Debug(err.Error())
+		return err
+	}
+	var baseValue interface{}
+	if base, ok := s.Ancestors[r.ID]; ok {
+		_, baseValue, err = decodeSecret(base)
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return err
+		}
+	}
+	merged, ok := mergeFields(baseValue, local.value, remoteValue)
+	if !ok {
+		s.Conflicts[r.ID] = r
+		return nil
+	}
+	if err := s.putSecret(local.secretType, merged, false); err != nil {
+		return err
+	}
+	s.Revisions[r.ID] = r.Revision
+	s.Ancestors[r.ID] = remote
+	delete(s.Conflicts, r.ID)
+	if equalFields(merged, remoteValue) {
+		delete(s.Dirty, r.ID)
+	}
+	return nil
+}
+
+// mergeDeleted обрабатывает серверную версию записи, удаленной локально.
+// Если запись удалена и на сервере - удаление завершено. Если на сервере та же ревизия,
+// от которой запись удалена, удаление еще не отправлено, иначе это конфликт.
+func (s *storage) mergeDeleted(r domain.Record) {
+	if !r.Deleted && r.Revision <= s.Revisions[r.ID] {
+		return
+	}
+	if r.Deleted {
+		delete(s.Tombstones, r.ID)
+		delete(s.Revisions, r.ID)
+		delete(s.Ancestors, r.ID)
+		delete(s.Conflicts, r.ID)
+		return
+	}
+	s.Conflicts[r.ID] = r
+}
+
+// mergeFields выполняет трехстороннее слияние структур по полям.
+// Поле берется из той версии, в которой оно изменилось относительно base.
+// Если поле изменено в обеих версиях по-разному, возвращается false.
+// Поле Key локальное для каждого устройства и не сравнивается.
+func mergeFields(base, local, remote interface{}) (interface{}, bool) {
+	lv := reflect.ValueOf(local)
+	rv := reflect.ValueOf(remote)
+	if lv.Type() != rv.Type() {
+		return nil, false
+	}
+	if equalFields(local, remote) {
+		return local, true
+	}
+	if base == nil || reflect.TypeOf(base) != lv.Type() {
+		return nil, false
+	}
+	bv := reflect.ValueOf(base)
+	merged := reflect.New(lv.Type()).Elem()
+	merged.Set(lv)
+	for i := 0; i < lv.NumField(); i++ {
+		if lv.Type().Field(i).Name == "Key" {
+			continue
+		}
+		b, l, r := bv.Field(i).Interface(), lv.Field(i).Interface(), rv.Field(i).Interface()
+		if lv.Type().Field(i).Name == "UpdatedAt" {
+			// время изменения меняется при любой правке, берем более позднее
+			if rt, lt := r.(time.Time), l.(time.Time); rt.After(lt) {
+				merged.Field(i).Set(rv.Field(i))
+			}
+			continue
+		}
+		if lv.Field(i).Kind() == reflect.Struct && lv.Type().Field(i).Type != reflect.TypeOf(time.Time{}) {
+			// вложенные структуры (Metadata) сливаются по своим полям
+			m, ok := mergeFields(b, l, r)
+			if !ok {
+				return nil, false
+			}
+			merged.Field(i).Set(reflect.ValueOf(m))
+			continue
+		}
+		switch {
+		case reflect.DeepEqual(l, r), reflect.DeepEqual(b, r):
+		case reflect.DeepEqual(b, l):
+			merged.Field(i).Set(rv.Field(i))
+		default:
+			return nil, false
+		}
+	}
+	return merged.Interface(), true
+}
+
+// equalFields сравнивает записи без учета поля Key
+func equalFields(a, b interface{}) bool {
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	if av.Type() != bv.Type() {
+		return false
+	}
+	for i := 0; i < av.NumField(); i++ {
+		if av.Type().Field(i).Name == "Key" {
+			continue
+		}
+		if !reflect.DeepEqual(av.Field(i).Interface(), bv.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetConflicts возвращает неразрешенные конфликты в читаемом виде
+func (s *storage) GetConflicts() ([]domain.Conflict, error) {
+	var conflicts []domain.Conflict
+	for id, r := range s.Conflicts {
+		c := domain.Conflict{ID: id, Local: "deleted", Remote: "deleted"}
+		if local, ok := s.findByID(id); ok {
+			c.Type = typeName(local.secretType)
+			c.Local = describeSecret(local.value)
+		}
+		if !r.Deleted {
+			b, err := s.decryptRecord(r.Data)
+			if err != nil {
+				return nil, err
+			}
+			secretType, value, err := decodeSecret(b)
+			if err != nil {
+				return nil, err
+			}
+			c.Type = typeName(secretType)
+			c.Remote = describeSecret(value)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict разрешает конфликт записи:
+// KeepLocal - локальная версия будет отправлена на сервер поверх серверной,
+// KeepRemote - локальная версия заменяется серверной,
+// KeepBoth - локальная версия сохраняется как новая запись, серверная - под исходным ID.
+func (s *storage) ResolveConflict(id string, resolution domain.Resolution) error {
+	r, ok := s.Conflicts[id]
+	if !ok {
+		return ErrConflictNotFound
+	}
+	var remote []byte
+	if !r.Deleted {
+		var err error
+		remote, err = s.decryptRecord(r.Data)
+		if err != nil {
+			return err
+		}
+	}
+	switch resolution {
+	case domain.KeepLocal:
+		s.Revisions[id] = r.Revision
+		if _, ok := s.findByID(id); ok {
+			s.Dirty[id] = true
+		}
+		if remote != nil {
+			s.Ancestors[id] = remote
+		}
+		delete(s.Conflicts, id)
+	case domain.KeepRemote:
+		if err := s.applyRemote(r, remote); err != nil {
+			return err
+		}
+	case domain.KeepBoth:
+		// если запись удалена локально, сохранять нечего - остается серверная версия
+		if local, ok := s.findByID(id); ok {
+			s.removeByID(id)
+			copyID, err := newID()
+			if err != nil {
+				return err
+			}
+			if err = s.putSecret(local.secretType, withID(local.value, copyID), false); err != nil {
+				return err
+			}
+			s.Dirty[copyID] = true
+		}
+		if err := s.applyRemote(r, remote); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown resolution %q", resolution)
+	}
+	return s.writeFile()
+}
+
+// withID возвращает копию записи с новым ID и без локального ключа
+func withID(value interface{}, id string) interface{} {
+	v := reflect.New(reflect.TypeOf(value)).Elem()
+	v.Set(reflect.ValueOf(value))
+	v.FieldByName("ID").SetString(id)
+	v.FieldByName("Key").SetInt(0)
+	return v.Interface()
+}
+
+func typeName(secretType byte) string {
+	switch secretType {
+	case TypeLoginPassword:
+		return "login"
+	case TypeText:
+		return "text"
+	case TypeBinary:
+		return "binary"
+	case TypeCard:
+		return "card"
+	case TypeOTP:
+		return "otp"
+	}
+	return "unknown"
+}
+
+// describeSecret возвращает поля записи для показа пользователю
+func describeSecret(value interface{}) string {
+	v := reflect.ValueOf(value)
+	var fields []string
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if name == "Key" || name == "ID" {
+			continue
+		}
+		if b, ok := v.Field(i).Interface().([]byte); ok {
+			fields = append(fields, fmt.Sprintf("%s:%d bytes", name, len(b)))
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s:%v", name, v.Field(i).Interface()))
+	}
+	return strings.Join(fields, ", ")
+}
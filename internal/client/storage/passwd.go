@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"io"
+)
+
+// keyCheckPlain открытое значение key check. Сервер хранит его зашифрованным ключом записей после смены
+// мастер-пароля, и устройство, которое не может его расшифровать, знает, что пароль сменился
+var keyCheckPlain = []byte("yagophkeeper key check")
+
+// При смене мастер-пароля меняются ключ файла, ключ записей и ключи содержимого всех binary:
+// копии, зашифрованные старым паролем, не должны открывать новые данные
+
+// CheckMasterPass проверяет, что pass - текущий мастер-пароль хранилища
+// Если ключ файла в агенте, агент расшифровывает проверочное значение, зашифрованное ключом из pass
+func (s *storage) CheckMasterPass(pass string) error {
+	key := deriveKey(pass, s.kdf)
+	if s.key != nil {
+		if subtle.ConstantTimeCompare(key, s.key) != 1 {
+			return ErrWrongMasterPass
+		}
+		return nil
+	}
+	probe, err := seal(key, keyCheckPlain, nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.openFile(probe, nil)
+	return err
+}
+
+// CheckRecordKey проверяет, что keyCheck с сервера расшифровывается текущим ключом записей
+func (s *storage) CheckRecordKey(keyCheck []byte) (bool, error) {
+	_, err := s.decryptRecord(keyCheck)
+	if errors.Is(err, ErrWrongMasterPass) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RekeyRecords шифрует все записи ключом записей нового мастер-пароля с новой случайной солью,
+// содержимое binary - новыми ключами. Хранилище не меняется: новые чанки пишутся рядом со старыми,
+// записи применяются CommitRekey после того, как их примет сервер.
+// Возвращает записи, новые чанки для отправки на сервер и параметры нового ключа записей
+func (s *storage) RekeyRecords(newPass string) (records []domain.Record, chunks []string, params domain.RecordKeyParams, err error) {
+	if s.Email == "" {
+		return nil, nil, params, ErrNoEmail
+	}
+	params.Salt = make([]byte, saltSize)
+	if _, err = io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return nil, nil, params, err
+	}
+	recordKey := deriveKey(newPass, recordKDFParams(s.Email, params.Salt))
+	for _, sc := range s.secrets() {
+		if bd, ok := sc.value.(domain.BinaryData); ok {
+			if sc.value, err = s.rekeyBinary(bd); err != nil {
+				return nil, nil, params, err
+			}
+			chunks = append(chunks, sc.value.(domain.BinaryData).Chunks...)
+		}
+		b, err := encodeSecret(sc.secretType, sc.value)
+		if err != nil {
+			return nil, nil, params, err
+		}
+		encrypted, err := seal(recordKey, b, nil)
+		if err != nil {
+			return nil, nil, params, err
+		}
+		records = append(records, domain.Record{ID: sc.id, Data: encrypted, Chunks: recordChunks(sc.value)})
+	}
+	params.KeyCheck, err = seal(recordKey, keyCheckPlain, nil)
+	return records, chunks, params, err
+}
+
+// CommitRekey переводит хранилище на новый мастер-пароль и соль ключа записей и заменяет записи принятыми сервером
+// записями из RekeyRecords с их ревизиями. Локальные резервные копии, зашифрованные старым паролем, удаляются
+func (s *storage) CommitRekey(newPass string, salt []byte, records []domain.Record, revision int64) error {
+	if err := s.setMasterPass(newPass); err != nil {
+		return err
+	}
+	s.RecordSalt = salt
+	for _, r := range records {
+		plain, err := s.decryptRecord(r.Data)
+		if err != nil {
+			return err
+		}
+		secretType, value, err := decodeSecret(plain)
+		if err != nil {
+			return err
+		}
+		if err = s.putSecret(secretType, value, false); err != nil {
+			return err
+		}
+		s.Revisions[r.ID] = r.Revision
+		s.Ancestors[r.ID] = plain
+		delete(s.Dirty, r.ID)
+	}
+	s.SyncRevision = revision
+	return s.finishRekey()
+}
+
+// ChangeMasterPass меняет мастер-пароль хранилища, не подключенного к серверу
+func (s *storage) ChangeMasterPass(newPass string) error {
+	rekeyed := make(map[int]domain.BinaryData, len(s.bds))
+	for k, bd := range s.bds {
+		var err error
+		if rekeyed[k], err = s.rekeyBinary(bd); err != nil {
+			return err
+		}
+	}
+	s.bds = rekeyed
+	if err := s.setMasterPass(newPass); err != nil {
+		return err
+	}
+	return s.finishRekey()
+}
+
+// AdoptMasterPass переходит на мастер-пароль, смененный на другом устройстве. newPass проверяется по keyCheck
+// с солью из params, записи с новым ключом придут при синхронизации
+func (s *storage) AdoptMasterPass(newPass string, params domain.RecordKeyParams) error {
+	if s.Email == "" {
+		return ErrNoEmail
+	}
+	if _, err := open(deriveKey(newPass, recordKDFParams(s.Email, params.Salt)), params.KeyCheck, nil); err != nil {
+		return ErrWrongMasterPass
+	}
+	if err := s.setMasterPass(newPass); err != nil {
+		return err
+	}
+	s.RecordSalt = params.Salt
+	return s.finishRekey()
+}
+
+// setMasterPass получает ключ файла из нового мастер-пароля с новой солью. Ключи агента больше не подходят
+func (s *storage) setMasterPass(newPass string) error {
+	s.masterPass = newPass
+	s.keyring = nil
+	s.recordKey = nil
+	return s.newKey()
+}
+
+// finishRekey записывает хранилище с новым ключом и удаляет то, что зашифровано старым
+func (s *storage) finishRekey() error {
+	if err := s.writeFile(); err != nil {
+		return err
+	}
+	s.removeUnusedChunks()
+	if err := appFs.RemoveAll(s.backupDir()); err != nil {
+		s.logger.Error("remove backups", zap.Error(err))
+	}
+	return nil
+}
+
+// rekeyBinary перешифровывает содержимое binary новым ключом в новые чанки. Записи старого формата
+// хранят содержимое внутри записи и не меняются
+func (s *storage) rekeyBinary(bd domain.BinaryData) (domain.BinaryData, error) {
+	if len(bd.ChunkKey) == 0 {
+		return bd, nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.ReadBinaryContent(bd, pw))
+	}()
+	chunks, key, _, err := s.WriteBinaryContent(pr)
+	pr.Close()
+	if err != nil {
+		return domain.BinaryData{}, err
+	}
+	bd.Chunks, bd.ChunkKey = chunks, key
+	return bd, nil
+}
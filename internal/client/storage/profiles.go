@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+)
+
+var ErrProfileNotFound = errors.New("password profile not found")
+
+// GetPasswordProfiles возвращает сохраненные в заголовке профили генерации паролей
+func (s *storage) GetPasswordProfiles() map[string]domain.PasswordPolicy {
+	profiles := make(map[string]domain.PasswordPolicy, len(s.Profiles))
+	for name, p := range s.Profiles {
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// SavePasswordProfile сохраняет или заменяет профиль генерации паролей
+func (s *storage) SavePasswordProfile(name string, policy domain.PasswordPolicy) error {
+	s.Profiles[name] = policy
+	return s.writeFile()
+}
+
+// DeletePasswordProfile удаляет профиль генерации паролей
+func (s *storage) DeletePasswordProfile(name string) error {
+	if _, ok := s.Profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	delete(s.Profiles, name)
+	return s.writeFile()
+}
@@ -0,0 +1,473 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+)
+
+// secret запись любого типа вместе с ее ID
+type secret struct {
+	id         string
+	secretType byte
+	value      interface{}
+}
+
+// newID возвращает случайный идентификатор записи, общий для всех устройств
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// secrets возвращает все записи хранилища
+func (s *storage) secrets() []secret {
+	var result []secret
+	for _, lp := range s.lps {
+		result = append(result, secret{id: lp.ID, secretType: TypeLoginPassword, value: lp})
+	}
+	for _, bd := range s.bds {
+		result = append(result, secret{id: bd.ID, secretType: TypeBinary, value: bd})
+	}
+	for _, td := range s.tds {
+		result = append(result, secret{id: td.ID, secretType: TypeText, value: td})
+	}
+	for _, card := range s.cards {
+		result = append(result, secret{id: card.ID, secretType: TypeCard, value: card})
+	}
+	for _, otp := range s.otps {
+		result = append(result, secret{id: otp.ID, secretType: TypeOTP, value: otp})
+	}
+	return result
+}
+
+// encodeSecret кодирует запись: байт типа и gob структуры
+func encodeSecret(secretType byte, value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(secretType)
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSecret раскодирует запись, закодированную encodeSecret
+func decodeSecret(b []byte) (byte, interface{}, error) {
+	if len(b) < 2 {
+		return 0, nil, errors.New("record too short")
+	}
+	value, err := decodeValue(b[0], gob.NewDecoder(bytes.NewReader(b[1:])))
+	return b[0], value, err
+}
+
+// decodeValue читает из декодера структуру записи типа secretType
+func decodeValue(secretType byte, dec *gob.Decoder) (interface{}, error) {
+	switch secretType {
+	case TypeLoginPassword:
+		lp := domain.LoginPassword{}
+		err := dec.Decode(&lp)
+		return lp, err
+	case TypeText:
+		td := domain.TextData{}
+		err := dec.Decode(&td)
+		return td, err
+	case TypeBinary:
+		bd := domain.BinaryData{}
+		err := dec.Decode(&bd)
+		return bd, err
+	case TypeCard:
+		card := domain.CardData{}
+		err := dec.Decode(&card)
+		return card, err
+	case TypeOTP:
+		otp := domain.OTPSecret{}
+		err := dec.Decode(&otp)
+		return otp, err
+	}
+	return nil, fmt.Errorf("unknown secret type %d", secretType)
+}
+
+// putSecret добавляет запись или заменяет запись с тем же ID.
+// Если keepKey - сохраняется ключ из записи (чтение файла), иначе используется локальный ключ.
+// Записи без ID (старый формат файла) получают новый ID.
+func (s *storage) putSecret(secretType byte, value interface{}, keepKey bool) (err error) {
+	switch v := value.(type) {
+	case domain.LoginPassword:
+		if v.ID == "" {
+			if v.ID, err = newID(); err != nil {
+				return err
+			}
+		}
+		key := 0
+		for k, lp := range s.lps {
+			if lp.ID == v.ID {
+				key = k
+			}
+		}
+		if keepKey && v.Key != 0 {
+			key = v.Key
+		}
+		if key == 0 {
+			s.lpCount++
+			key = s.lpCount
+		}
+		if key > s.lpCount {
+			s.lpCount = key
+		}
+		v.Key = key
+		s.lps[key] = v
+	case domain.TextData:
+		if v.ID == "" {
+			if v.ID, err = newID(); err != nil {
+				return err
+			}
+		}
+		key := 0
+		for k, td := range s.tds {
+			if td.ID == v.ID {
+				key = k
+			}
+		}
+		if keepKey && v.Key != 0 {
+			key = v.Key
+		}
+		if key == 0 {
+			s.tdCount++
+			key = s.tdCount
+		}
+		if key > s.tdCount {
+			s.tdCount = key
+		}
+		v.Key = key
+		s.tds[key] = v
+	case domain.BinaryData:
+		if v.ID == "" {
+			if v.ID, err = newID(); err != nil {
+				return err
+			}
+		}
+		key := 0
+		for k, bd := range s.bds {
+			if bd.ID == v.ID {
+				key = k
+			}
+		}
+		if keepKey && v.Key != 0 {
+			key = v.Key
+		}
+		if key == 0 {
+			s.bdCount++
+			key = s.bdCount
+		}
+		if key > s.bdCount {
+			s.bdCount = key
+		}
+		v.Key = key
+		s.bds[key] = v
+	case domain.CardData:
+		if v.ID == "" {
+			if v.ID, err = newID(); err != nil {
+				return err
+			}
+		}
+		key := 0
+		for k, card := range s.cards {
+			if card.ID == v.ID {
+				key = k
+			}
+		}
+		if keepKey && v.Key != 0 {
+			key = v.Key
+		}
+		if key == 0 {
+			s.cardCount++
+			key = s.cardCount
+		}
+		if key > s.cardCount {
+			s.cardCount = key
+		}
+		v.Key = key
+		s.cards[key] = v
+	case domain.OTPSecret:
+		if v.ID == "" {
+			if v.ID, err = newID(); err != nil {
+				return err
+			}
+		}
+		key := 0
+		for k, otp := range s.otps {
+			if otp.ID == v.ID {
+				key = k
+			}
+		}
+		if keepKey && v.Key != 0 {
+			key = v.Key
+		}
+		if key == 0 {
+			s.otpCount++
+			key = s.otpCount
+		}
+		if key > s.otpCount {
+			s.otpCount = key
+		}
+		v.Key = key
+		s.otps[key] = v
+	default:
+		s.logger.Debug("unknown secret type", zap.Uint8("type", secretType))
+	}
+	return nil
+}
+
+// removeByID удаляет запись любого типа по ID
+func (s *storage) removeByID(id string) {
+	for k, lp := range s.lps {
+		if lp.ID == id {
+			delete(s.lps, k)
+		}
+	}
+	for k, td := range s.tds {
+		if td.ID == id {
+			delete(s.tds, k)
+		}
+	}
+	for k, bd := range s.bds {
+		if bd.ID == id {
+			delete(s.bds, k)
+		}
+	}
+	for k, card := range s.cards {
+		if card.ID == id {
+			delete(s.cards, k)
+		}
+	}
+	for k, otp := range s.otps {
+		if otp.ID == id {
+			delete(s.otps, k)
+		}
+	}
+}
+
+// findByID возвращает запись любого типа по ID
+func (s *storage) findByID(id string) (secret, bool) {
+	for _, sc := range s.secrets() {
+		if sc.id == id {
+			return sc, true
+		}
+	}
+	return secret{}, false
+}
+
+// isChanged возвращает true, если запись не отправлялась на сервер или изменилась после синхронизации
+func (s *storage) isChanged(id string) bool {
+	return s.Dirty[id] || s.Revisions[id] == 0
+}
+
+// GetChangedRecords возвращает зашифрованные записи, измененные после последней синхронизации.
+// Записи с неразрешенным конфликтом не отправляются.
+func (s *storage) GetChangedRecords() ([]domain.Record, error) {
+	var records []domain.Record
+	for _, sc := range s.secrets() {
+		if _, ok := s.Conflicts[sc.id]; ok || !s.isChanged(sc.id) {
+			continue
+		}
+		b, err := encodeSecret(sc.secretType, sc.value)
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return nil, err
+		}
+		encrypted, err := s.encryptRecord(b)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, domain.Record{ID: sc.id, Revision: s.Revisions[sc.id], Data: encrypted, Chunks: recordChunks(sc.value)})
+	}
+	for id := range s.Tombstones {
+		if _, ok := s.Conflicts[id]; ok {
+			continue
+		}
+		records = append(records, domain.Record{ID: id, Revision: s.Revisions[id], Deleted: true})
+	}
+	return records, nil
+}
+
+// ApplyRecords применяет записи, полученные с сервера.
+// Если запись изменена и локально, выполняется трехстороннее слияние с последней синхронизированной версией;
+// при изменении одних и тех же полей запись попадает в список конфликтов.
+func (s *storage) ApplyRecords(records []domain.Record) error {
+	for _, r := range records {
+		if !s.Dirty[r.ID] && !s.Tombstones[r.ID] && s.Revisions[r.ID] >= r.Revision {
+			continue
+		}
+		var remote []byte
+		if !r.Deleted {
+			var err error
+			remote, err = s.decryptRecord(r.Data)
+			if err != nil {
+				s.logger.Error("decrypt record error", zap.String("id", r.ID), zap.Error(err))
+				return err
+			}
+		}
+		if s.Tombstones[r.ID] {
+			s.mergeDeleted(r)
+			continue
+		}
+		local, exists := s.findByID(r.ID)
+		if exists && s.Dirty[r.ID] {
+			err := s.mergeRecord(r, local, remote)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := s.applyRemote(r, remote)
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.writeFile(); err != nil {
+		return err
+	}
+	s.removeUnusedChunks()
+	return nil
+}
+
+// RestoreRecords заменяет записи хранилища записями версии snapshot. current - все записи сервера,
+// в том числе удаленные: от их ревизий изменения уйдут при следующей синхронизации.
+// Записи, не изменившиеся на сервере после снимка, не отправляются заново. Возвращает число
+// восстановленных и удаленных записей, которые будут отправлены
+func (s *storage) RestoreRecords(snapshot []domain.Record, current []domain.Record) (changed int, err error) {
+	server := make(map[string]domain.Record, len(current))
+	for _, r := range current {
+		server[r.ID] = r
+	}
+	// ancestor возвращает открытую серверную версию записи - общий предок для слияния
+	ancestor := func(id string) ([]byte, bool) {
+		r, ok := server[id]
+		if !ok || r.Deleted {
+			return nil, false
+		}
+		plain, err := s.decryptRecord(r.Data)
+		return plain, err == nil
+	}
+	restored := make(map[string]bool, len(snapshot))
+	for _, r := range snapshot {
+		plain, err := s.decryptRecord(r.Data)
+		if err != nil {
+			s.logger.Error("decrypt record error", zap.String("id", r.ID), zap.Error(err))
+			return 0, err
+		}
+		secretType, value, err := decodeSecret(plain)
+		if err != nil {
+			return 0, err
+		}
+		restored[r.ID] = true
+		if err = s.putSecret(secretType, value, false); err != nil {
+			return 0, err
+		}
+		delete(s.Conflicts, r.ID)
+		delete(s.Tombstones, r.ID)
+		cur := server[r.ID]
+		s.Revisions[r.ID] = cur.Revision
+		if plain, ok := ancestor(r.ID); ok {
+			s.Ancestors[r.ID] = plain
+		} else {
+			delete(s.Ancestors, r.ID)
+		}
+		if !cur.Deleted && cur.Revision == r.Revision {
+			delete(s.Dirty, r.ID)
+			continue
+		}
+		s.Dirty[r.ID] = true
+		changed++
+	}
+	for _, sc := range s.secrets() {
+		if !restored[sc.id] {
+			s.removeByID(sc.id)
+			s.Tombstones[sc.id] = true
+			delete(s.Dirty, sc.id)
+			delete(s.Conflicts, sc.id)
+		}
+	}
+	// удаления отправляются только для записей, которые есть на сервере
+	for id := range s.Tombstones {
+		if r, ok := server[id]; ok && !r.Deleted {
+			s.Revisions[id] = r.Revision
+			changed++
+			continue
+		}
+		delete(s.Tombstones, id)
+		delete(s.Revisions, id)
+		delete(s.Ancestors, id)
+	}
+	if err = s.writeFile(); err != nil {
+		return 0, err
+	}
+	s.removeUnusedChunks()
+	return changed, nil
+}
+
+// applyRemote заменяет локальную запись серверной версией
+func (s *storage) applyRemote(r domain.Record, remote []byte) error {
+	if r.Deleted {
+		s.removeByID(r.ID)
+		delete(s.Ancestors, r.ID)
+		delete(s.Revisions, r.ID)
+	} else {
+		secretType, value, err := decodeSecret(remote)
+		if err != nil {
+			s.logger.Debug(err.Error())
+			return err
+		}
+		if err = s.putSecret(secretType, value, false); err != nil {
+			return err
+		}
+		s.Ancestors[r.ID] = remote
+		s.Revisions[r.ID] = r.Revision
+	}
+	delete(s.Dirty, r.ID)
+	delete(s.Conflicts, r.ID)
+	delete(s.Tombstones, r.ID)
+	return nil
+}
+
+// MarkRecordsSynced сохраняет ревизии записей, принятых сервером
+func (s *storage) MarkRecordsSynced(records []domain.Record) error {
+	for _, r := range records {
+		if r.Deleted {
+			delete(s.Tombstones, r.ID)
+			delete(s.Revisions, r.ID)
+			delete(s.Ancestors, r.ID)
+			continue
+		}
+		s.Revisions[r.ID] = r.Revision
+		delete(s.Dirty, r.ID)
+		if sc, ok := s.findByID(r.ID); ok {
+			b, err := encodeSecret(sc.secretType, sc.value)
+			if err != nil {
+				return err
+			}
+			s.Ancestors[r.ID] = b
+		} else {
+			delete(s.Ancestors, r.ID)
+		}
+	}
+	return s.writeFile()
+}
+
+// GetSyncRevision возвращает последнюю ревизию, полученную с сервера
+func (s *storage) GetSyncRevision() int64 {
+	return s.SyncRevision
+}
+
+// SetSyncRevision сохраняет последнюю ревизию, полученную с сервера
+func (s *storage) SetSyncRevision(revision int64) error {
+	s.SyncRevision = revision
+	return s.writeFile()
+}
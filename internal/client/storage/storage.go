@@ -2,37 +2,52 @@ package storage
 
 import (
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"encoding/gob"
 	"errors"
 	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/spf13/afero"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
-	"io"
 	"os"
+	"sort"
 	"time"
 )
 
+var ErrNotFound = errors.New("secret not found")
+
 const (
 	TypeLoginPassword byte = 0x1
 	TypeText          byte = 0x2
 	TypeBinary        byte = 0x3
 	TypeCard          byte = 0x4
+	TypeOTP           byte = 0x5
 )
 
 type storage struct {
 	filename   string
 	masterPass string
-	logger     *zap.Logger
-	//lps        []domain.LoginPassword // TODO maps for delete
-	lps     map[int]domain.LoginPassword
-	lpCount int
-	tds     []domain.TextData
-	bds     []domain.BinaryData
-	cards   []domain.CardData
+	// key ключ шифрования файла, полученный из мастер-пароля с параметрами kdf
+	key            []byte
+	kdf            kdfParams
+	recordKey      []byte
+	recordKeyEmail string
+	// keyring агент с ключами хранилища, используется без мастер-пароля
+	keyring   Keyring
+	logger    *zap.Logger
+	lps       map[int]domain.LoginPassword
+	lpCount   int
+	tds       map[int]domain.TextData
+	tdCount   int
+	bds       map[int]domain.BinaryData
+	bdCount   int
+	cards     map[int]domain.CardData
+	cardCount int
+	otps      map[int]domain.OTPSecret
+	otpCount  int
+	// unknownFrames фреймы с типами записей, неизвестными этой версии клиента
+	unknownFrames [][]byte
+	// keepBackups количество хранимых резервных копий файла, 0 - не делать копии
+	keepBackups int
 	fileHeaders
 }
 
@@ -43,6 +58,24 @@ type fileHeaders struct {
 	Email      string
 	HashedPass []byte
 	Token      string
+	// RefreshToken одноразовый токен для обновления Token
+	RefreshToken string
+	// SyncRevision последняя ревизия, полученная с сервера
+	SyncRevision int64
+	// Revisions серверные ревизии записей по ID
+	Revisions map[string]int64
+	// Dirty ID записей, измененных после последней синхронизации
+	Dirty map[string]bool
+	// Ancestors последние синхронизированные версии записей (общий предок для слияния)
+	Ancestors map[string][]byte
+	// Conflicts серверные версии записей, конфликтующие с локальными изменениями
+	Conflicts map[string]domain.Record
+	// Tombstones ID удаленных записей, удаление которых еще не отправлено на сервер
+	Tombstones map[string]bool
+	// Profiles именованные политики генерации паролей
+	Profiles map[string]domain.PasswordPolicy
+	// RecordSalt соль ключа записей аккаунта с сервера, пустая - соль получается из Email
+	RecordSalt []byte
 }
 
 var appFs = afero.NewOsFs()
@@ -50,15 +83,20 @@ var appFs = afero.NewOsFs()
 // New возвращает файловое хранилище.
 // Если существует - считывает служебные данные
 func New(filename string, masterPass string, logger *zap.Logger) (*storage, error) {
-	var s storage
+	return NewWithKeyring(filename, masterPass, nil, logger)
+}
+
+// NewWithKeyring возвращает файловое хранилище, открытое мастер-паролем или, если он пуст,
+// агентом, который шифрует данные ключами хранилища
+func NewWithKeyring(filename string, masterPass string, keyring Keyring, logger *zap.Logger) (*storage, error) {
+	s := newStorage(filename, masterPass, keyring, logger)
 	fstat, err := appFs.Stat(filename)
-	s.filename = filename
-	s.logger = logger
-	s.masterPass = masterPass
-	s.lps = make(map[int]domain.LoginPassword)
 	if errors.Is(err, os.ErrNotExist) || fstat.Size() == 0 {
 		s.UpdatedAt = time.Time{} //zero time
-
+		err = s.newKey()
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		err = s.readFile()
 		if err != nil {
@@ -66,24 +104,48 @@ func New(filename string, masterPass string, logger *zap.Logger) (*storage, erro
 		}
 	}
 
-	return &s, nil
+	return s, nil
 }
 
-// readHeaders считывает служебные поля в структуру fileHeaders
-func (s *storage) readHeaders(b []byte) error {
-	buf := bytes.NewBuffer(b)
+func newStorage(filename string, masterPass string, keyring Keyring, logger *zap.Logger) *storage {
+	var s storage
+	s.filename = filename
+	s.keepBackups = DefaultBackups
+	s.logger = logger
+	s.masterPass = masterPass
+	s.keyring = keyring
+	s.lps = make(map[int]domain.LoginPassword)
+	s.tds = make(map[int]domain.TextData)
+	s.bds = make(map[int]domain.BinaryData)
+	s.cards = make(map[int]domain.CardData)
+	s.otps = make(map[int]domain.OTPSecret)
+	s.Revisions = make(map[string]int64)
+	s.Dirty = make(map[string]bool)
+	s.Ancestors = make(map[string][]byte)
+	s.Conflicts = make(map[string]domain.Record)
+	s.Tombstones = make(map[string]bool)
+	s.Profiles = make(map[string]domain.PasswordPolicy)
+	return &s
+}
+
+// readHeaders считывает служебные поля в структуру fileHeaders.
+// Декодер читает из буфера ровно одно значение gob, поэтому разделитель после заголовков
+// не ищется в данных - они могут содержать байт 30.
+func (s *storage) readHeaders(buf *bytes.Buffer) error {
 	if err := gob.NewDecoder(buf).Decode(&s.fileHeaders); err != nil {
 		s.logger.Debug(err.Error())
 		return err
 	}
-	return nil
+	_, err := buf.ReadByte() // record separator
+	return err
 }
 
 // SaveUserData сохраняет данные о пользователе в структуру fileHeaders и файл
-func (s *storage) SaveUserData(user domain.User, token string) error {
+func (s *storage) SaveUserData(user domain.User, tokens domain.Tokens) error {
 	var err error
 	s.Email = user.Email
-	s.Token = token
+	s.Token = tokens.Access
+	s.RefreshToken = tokens.Refresh
 	s.HashedPass, err = bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -105,140 +167,271 @@ func (s *storage) UpdateTime() error {
 	return nil
 }
 
+// created проставляет время создания и изменения новой записи.
+// Время создания, заданное заранее (например при импорте), сохраняется
+func created(m domain.Metadata) domain.Metadata {
+	now := time.Now().UTC()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+	return m
+}
+
+// updated сохраняет время создания записи и обновляет время изменения
+func updated(m domain.Metadata, createdAt time.Time) domain.Metadata {
+	m.CreatedAt = createdAt
+	m.UpdatedAt = time.Now().UTC()
+	return m
+}
+
 // AddLoginPassword добавляет структуру логин-пароль и записывает файл
 func (s *storage) AddLoginPassword(lp domain.LoginPassword) error {
-	key := lp.Key
-	if key == 0 {
-		s.lpCount++
-		key = s.lpCount
+	id, err := newID()
+	if err != nil {
+		return err
 	}
-	if key > s.lpCount {
-		s.lpCount = key
+	lp.ID = id
+	lp.Key = 0
+	lp.Metadata = created(lp.Metadata)
+	if err = s.putSecret(TypeLoginPassword, lp, false); err != nil {
+		return err
 	}
-	lp.Key = key
-	s.lps[key] = lp
+	s.Dirty[lp.ID] = true
 	return s.writeFile()
 }
 
 func (s *storage) AddTextData(td domain.TextData) error {
-	td.Key = len(s.tds) + 1
-	s.tds = append(s.tds, td)
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	td.ID = id
+	td.Key = 0
+	td.Metadata = created(td.Metadata)
+	if err = s.putSecret(TypeText, td, false); err != nil {
+		return err
+	}
+	s.Dirty[td.ID] = true
 	return s.writeFile()
 }
 
 func (s *storage) AddBinaryData(bd domain.BinaryData) error {
-	bd.Key = len(s.bds) + 1
-	s.bds = append(s.bds, bd)
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	bd.ID = id
+	bd.Key = 0
+	bd.Metadata = created(bd.Metadata)
+	if err = s.putSecret(TypeBinary, bd, false); err != nil {
+		return err
+	}
+	s.Dirty[bd.ID] = true
 	return s.writeFile()
 }
 
 func (s *storage) AddCardData(card domain.CardData) error {
-	card.Key = len(s.cards) + 1
-	s.cards = append(s.cards, card)
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	card.ID = id
+	card.Key = 0
+	card.Metadata = created(card.Metadata)
+	if err = s.putSecret(TypeCard, card, false); err != nil {
+		return err
+	}
+	s.Dirty[card.ID] = true
 	return s.writeFile()
 }
 
-func (s *storage) makeHeaders() ([]byte, error) {
-	var buf bytes.Buffer
-	var err error
-	if err = gob.NewEncoder(&buf).Encode(s.fileHeaders); err != nil {
-		s.logger.Debug(err.Error())
-		return nil, err
-	}
-	_, err = buf.Write([]byte{30})
+func (s *storage) AddOTP(otp domain.OTPSecret) error {
+	id, err := newID()
 	if err != nil {
-		s.logger.Debug(err.Error())
-		return nil, err
+		return err
 	}
-	return buf.Bytes(), nil
+	otp.ID = id
+	otp.Key = 0
+	otp.Metadata = created(otp.Metadata)
+	if err = s.putSecret(TypeOTP, otp, false); err != nil {
+		return err
+	}
+	s.Dirty[otp.ID] = true
+	return s.writeFile()
 }
 
-func (s *storage) makeBody() ([]byte, error) {
-	var err error
-	var buf bytes.Buffer
-	for _, lp := range s.lps {
-		_, err = buf.Write([]byte{TypeLoginPassword})
-		if err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		if err = gob.NewEncoder(&buf).Encode(lp); err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		_, err = buf.Write([]byte{30}) // record separator
-		if err != nil {
-			return nil, err
-		}
+// UpdateLoginPassword заменяет логин-пароль с ключом lp.Key и записывает файл
+func (s *storage) UpdateLoginPassword(lp domain.LoginPassword) error {
+	old, ok := s.lps[lp.Key]
+	if !ok {
+		return ErrNotFound
 	}
-	for _, bd := range s.bds {
-		_, err = buf.Write([]byte{TypeBinary})
-		if err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		if err = gob.NewEncoder(&buf).Encode(bd); err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		_, err = buf.Write([]byte{30}) // record separator
-		if err != nil {
-			return nil, err
-		}
+	lp.ID = old.ID
+	lp.Metadata = updated(lp.Metadata, old.CreatedAt)
+	s.lps[lp.Key] = lp
+	s.Dirty[lp.ID] = true
+	return s.writeFile()
+}
+
+func (s *storage) UpdateTextData(td domain.TextData) error {
+	old, ok := s.tds[td.Key]
+	if !ok {
+		return ErrNotFound
 	}
-	for _, td := range s.tds {
-		_, err = buf.Write([]byte{TypeText})
-		if err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		if err = gob.NewEncoder(&buf).Encode(td); err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		_, err = buf.Write([]byte{30}) // record separator
-		if err != nil {
-			return nil, err
-		}
+	td.ID = old.ID
+	td.Metadata = updated(td.Metadata, old.CreatedAt)
+	s.tds[td.Key] = td
+	s.Dirty[td.ID] = true
+	return s.writeFile()
+}
+
+func (s *storage) UpdateBinaryData(bd domain.BinaryData) error {
+	old, ok := s.bds[bd.Key]
+	if !ok {
+		return ErrNotFound
 	}
-	for _, card := range s.cards {
-		_, err = buf.Write([]byte{TypeCard})
-		if err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		if err = gob.NewEncoder(&buf).Encode(card); err != nil {
-			s.logger.Debug(err.Error())
-			return nil, err
-		}
-		_, err = buf.Write([]byte{30}) // record separator
-		if err != nil {
-			return nil, err
-		}
+	bd.ID = old.ID
+	bd.Metadata = updated(bd.Metadata, old.CreatedAt)
+	s.bds[bd.Key] = bd
+	s.Dirty[bd.ID] = true
+	if err := s.writeFile(); err != nil {
+		return err
 	}
-	return buf.Bytes(), nil
+	s.removeUnusedChunks()
+	return nil
 }
 
-func (s *storage) writeFile() error {
-	headers, err := s.makeHeaders()
-	if err != nil {
-		s.logger.Debug(err.Error())
-		return err
+func (s *storage) UpdateCardData(card domain.CardData) error {
+	old, ok := s.cards[card.Key]
+	if !ok {
+		return ErrNotFound
 	}
-	body, err := s.makeBody()
-	if err != nil {
-		s.logger.Debug(err.Error())
+	card.ID = old.ID
+	card.Metadata = updated(card.Metadata, old.CreatedAt)
+	s.cards[card.Key] = card
+	s.Dirty[card.ID] = true
+	return s.writeFile()
+}
+
+func (s *storage) UpdateOTP(otp domain.OTPSecret) error {
+	old, ok := s.otps[otp.Key]
+	if !ok {
+		return ErrNotFound
+	}
+	otp.ID = old.ID
+	otp.Metadata = updated(otp.Metadata, old.CreatedAt)
+	s.otps[otp.Key] = otp
+	s.Dirty[otp.ID] = true
+	return s.writeFile()
+}
+
+// DeleteLoginPassword удаляет логин-пароль по ключу и записывает файл
+func (s *storage) DeleteLoginPassword(key int) error {
+	lp, ok := s.lps[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return s.deleteSecret(lp.ID)
+}
+
+func (s *storage) DeleteTextData(key int) error {
+	td, ok := s.tds[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return s.deleteSecret(td.ID)
+}
+
+func (s *storage) DeleteBinaryData(key int) error {
+	bd, ok := s.bds[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := s.deleteSecret(bd.ID); err != nil {
 		return err
 	}
-	full := make([]byte, 0, len(headers)+len(body))
-	full = append(full, headers...)
-	full = append(full, body...)
-	encrypted, err := s.encrypt(full)
+	s.removeUnusedChunks()
+	return nil
+}
+
+func (s *storage) DeleteCardData(key int) error {
+	card, ok := s.cards[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return s.deleteSecret(card.ID)
+}
+
+func (s *storage) DeleteOTP(key int) error {
+	otp, ok := s.otps[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return s.deleteSecret(otp.ID)
+}
+
+// deleteSecret удаляет запись. Для записей, уже отправленных на сервер,
+// сохраняется tombstone, чтобы удаление попало на другие устройства при синхронизации
+func (s *storage) deleteSecret(id string) error {
+	s.removeByID(id)
+	delete(s.Dirty, id)
+	if s.Revisions[id] > 0 {
+		s.Tombstones[id] = true
+	} else {
+		delete(s.Ancestors, id)
+	}
+	return s.writeFile()
+}
+
+// GetLoginPassword возвращает логин-пароль по ключу
+func (s *storage) GetLoginPassword(key int) (domain.LoginPassword, error) {
+	lp, ok := s.lps[key]
+	if !ok {
+		return domain.LoginPassword{}, ErrNotFound
+	}
+	return lp, nil
+}
+
+func (s *storage) GetText(key int) (domain.TextData, error) {
+	td, ok := s.tds[key]
+	if !ok {
+		return domain.TextData{}, ErrNotFound
+	}
+	return td, nil
+}
+
+func (s *storage) GetBinary(key int) (domain.BinaryData, error) {
+	bd, ok := s.bds[key]
+	if !ok {
+		return domain.BinaryData{}, ErrNotFound
+	}
+	return bd, nil
+}
+
+func (s *storage) GetCard(key int) (domain.CardData, error) {
+	card, ok := s.cards[key]
+	if !ok {
+		return domain.CardData{}, ErrNotFound
+	}
+	return card, nil
+}
+
+func (s *storage) GetOTP(key int) (domain.OTPSecret, error) {
+	otp, ok := s.otps[key]
+	if !ok {
+		return domain.OTPSecret{}, ErrNotFound
+	}
+	return otp, nil
+}
+
+func (s *storage) writeFile() error {
+	full, err := s.encodeContainer()
 	if err != nil {
 		s.logger.Error("encrypt file error", zap.Error(err))
 		return err
 	}
-	err = afero.WriteFile(appFs, s.filename, encrypted, 0644)
+	err = s.replaceFile(full)
 	if err != nil {
 		s.logger.Debug(err.Error())
 		return err
@@ -246,86 +439,50 @@ func (s *storage) writeFile() error {
 	return nil
 }
 
+// readFile читает файл текущего формата. Файлы предыдущих версий читаются и сразу перезаписываются в текущем формате
 func (s *storage) readFile() error {
-	encrypted, err := afero.ReadFile(appFs, s.filename)
+	raw, err := afero.ReadFile(appFs, s.filename)
 	if err != nil {
 		s.logger.Error("read file error", zap.Error(err))
 		return err
 	}
-	b, err := s.decrypt(encrypted)
-	if err != nil {
-		s.logger.Error("decrypt file error", zap.Error(err))
-		return err
-	}
-	buf := bytes.NewBuffer(b)
-	headers, err := buf.ReadBytes(30)
-	if err != nil {
+	legacy, err := s.decodeFile(raw)
+	if err != nil || !legacy {
 		return err
 	}
-	err = s.readHeaders(headers)
-	if err != nil {
-		return err
-	}
-	for {
-		b, err := buf.ReadBytes(30) // record separator
+	s.logger.Info("upgrading vault file format", zap.Int32("from", s.Version), zap.Uint16("to", formatVersion))
+	return s.writeFile()
+}
+
+// decodeFile расшифровывает содержимое файла хранилища. legacy - файл предыдущей версии формата
+func (s *storage) decodeFile(raw []byte) (legacy bool, err error) {
+	if bytes.HasPrefix(raw, formatMagic) {
+		b, err := s.decodeContainer(raw)
 		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				s.logger.Debug(err.Error())
-				break
-			}
+			s.logger.Error("decrypt file error", zap.Error(err))
+			return false, err
 		}
-		var secretType byte
-		if len(b) > 2 {
-			secretType = b[0]
-			b = b[1:]
+		return false, s.readFrames(b)
+	}
+	var b []byte
+	if bytes.HasPrefix(raw, kdfMagic) {
+		p, n, errKDF := unmarshalKDFParams(raw[len(kdfMagic):])
+		if errKDF != nil {
+			return false, errKDF
 		}
-		bufferGob := bytes.NewBuffer(b)
-
-		switch secretType {
-		case TypeLoginPassword:
-			lp := &domain.LoginPassword{}
-			bufferGob.Write(b)
-			err := gob.NewDecoder(bufferGob).Decode(lp)
-			if err != nil {
-				s.logger.Debug(err.Error())
-			}
-			bufferGob.Reset()
-			//s.lps = append(s.lps, *lp)
-			s.AddLoginPassword(*lp)
-		case TypeText:
-			td := &domain.TextData{}
-			bufferGob.Write(b)
-			err := gob.NewDecoder(bufferGob).Decode(td)
-			if err != nil {
-				s.logger.Debug(err.Error())
-			}
-			bufferGob.Reset()
-			s.tds = append(s.tds, *td)
-		case TypeBinary:
-			bd := &domain.BinaryData{}
-			bufferGob.Write(b)
-			err := gob.NewDecoder(bufferGob).Decode(bd)
-			if err != nil {
-				s.logger.Debug(err.Error())
-			}
-			bufferGob.Reset()
-			s.bds = append(s.bds, *bd)
-		case TypeCard:
-			card := &domain.CardData{}
-			bufferGob.Write(b)
-			err := gob.NewDecoder(bufferGob).Decode(card)
-			if err != nil {
-				s.logger.Debug(err.Error())
-			}
-			bufferGob.Reset()
-			s.cards = append(s.cards, *card)
-		default:
-			continue
+		err = s.setFileKey(p)
+		if err != nil {
+			return false, err
 		}
+		b, err = s.decrypt(raw[len(kdfMagic)+n:])
+	} else {
+		b, err = s.readLegacy(raw)
 	}
-	return nil
+	if err != nil {
+		s.logger.Error("decrypt file error", zap.Error(err))
+		return false, err
+	}
+	return true, s.readLegacyBody(b)
 }
 
 func (s *storage) GetLogins() []domain.LoginPassword {
@@ -333,19 +490,44 @@ func (s *storage) GetLogins() []domain.LoginPassword {
 	for _, lp := range s.lps {
 		lps = append(lps, lp)
 	}
+	sort.Slice(lps, func(i, j int) bool { return lps[i].Key < lps[j].Key })
 	return lps
 }
 
 func (s *storage) GetTextData() []domain.TextData {
-	return s.tds
+	var tds []domain.TextData
+	for _, td := range s.tds {
+		tds = append(tds, td)
+	}
+	sort.Slice(tds, func(i, j int) bool { return tds[i].Key < tds[j].Key })
+	return tds
 }
 
 func (s *storage) GetBinaryData() []domain.BinaryData {
-	return s.bds
+	var bds []domain.BinaryData
+	for _, bd := range s.bds {
+		bds = append(bds, bd)
+	}
+	sort.Slice(bds, func(i, j int) bool { return bds[i].Key < bds[j].Key })
+	return bds
 }
 
 func (s *storage) GetCardsData() []domain.CardData {
-	return s.cards
+	var cards []domain.CardData
+	for _, card := range s.cards {
+		cards = append(cards, card)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Key < cards[j].Key })
+	return cards
+}
+
+func (s *storage) GetOTPs() []domain.OTPSecret {
+	var otps []domain.OTPSecret
+	for _, otp := range s.otps {
+		otps = append(otps, otp)
+	}
+	sort.Slice(otps, func(i, j int) bool { return otps[i].Key < otps[j].Key })
+	return otps
 }
 
 // GetData Чтение всего файла секретов
@@ -359,16 +541,52 @@ func (s *storage) GetData() ([]byte, error) {
 
 // SetData Запись всего файла секретов
 func (s *storage) SetData(data []byte) error {
-	err := afero.WriteFile(appFs, s.filename, data, 0644)
+	err := s.replaceFile(data)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetToken возвращает токен пользователя
-func (s *storage) GetToken() string {
-	return s.Token
+// GetTokens возвращает токены пользователя
+func (s *storage) GetTokens() domain.Tokens {
+	return domain.Tokens{Access: s.Token, Refresh: s.RefreshToken}
+}
+
+// GetEmail возвращает email аккаунта, пустой - хранилище не подключено к серверу
+func (s *storage) GetEmail() string {
+	return s.Email
+}
+
+// ForgetAccount отключает хранилище от удаленного аккаунта. Секреты остаются и при входе
+// в другой аккаунт будут отправлены на сервер как новые
+func (s *storage) ForgetAccount() error {
+	s.Email = ""
+	s.RecordSalt = nil
+	s.Token = ""
+	s.RefreshToken = ""
+	s.HashedPass = nil
+	s.SyncRevision = 0
+	s.Revisions = make(map[string]int64)
+	s.Dirty = make(map[string]bool)
+	s.Ancestors = make(map[string][]byte)
+	s.Conflicts = make(map[string]domain.Record)
+	s.Tombstones = make(map[string]bool)
+	return s.writeFile()
+}
+
+// SetRecordSalt сохраняет соль ключа записей аккаунта, полученную с сервера при входе
+func (s *storage) SetRecordSalt(salt []byte) error {
+	s.RecordSalt = salt
+	s.recordKey = nil
+	return s.writeFile()
+}
+
+// SaveTokens сохраняет обновленные токены пользователя
+func (s *storage) SaveTokens(tokens domain.Tokens) error {
+	s.Token = tokens.Access
+	s.RefreshToken = tokens.Refresh
+	return s.writeFile()
 }
 
 // GetLocalSyncTime возвращает время обновления
@@ -376,51 +594,51 @@ func (s *storage) GetLocalSyncTime() time.Time {
 	return s.UpdatedAt
 }
 
-func (s *storage) encrypt(b []byte) (encryptedBytes []byte, err error) {
-
-	block, err := aes.NewCipher([]byte(s.masterPass))
+// newKey создает новую соль и получает из мастер-пароля ключ шифрования файла
+func (s *storage) newKey() error {
+	p, err := defaultKDFParams()
 	if err != nil {
-		s.logger.Debug(err.Error())
-		return nil, err
+		return err
+	}
+	if s.masterPass == "" {
+		return ErrLocked
 	}
+	s.kdf = p
+	s.key = deriveKey(s.masterPass, p)
+	return nil
+}
 
-	aesGCM, err := cipher.NewGCM(block)
+// readLegacy расшифровывает файл старого формата, где мастер-пароль использовался как ключ AES.
+// Для следующей записи создается ключ Argon2id, и файл будет перезаписан в новом формате.
+func (s *storage) readLegacy(encrypted []byte) ([]byte, error) {
+	switch len(s.masterPass) {
+	case 0:
+		return nil, ErrLocked
+	case 16, 24, 32:
+	default:
+		return nil, ErrWrongMasterPass
+	}
+	b, err := open([]byte(s.masterPass), encrypted, nil)
 	if err != nil {
-		s.logger.Debug(err.Error())
 		return nil, err
 	}
+	return b, s.newKey()
+}
 
-	nonce := make([]byte, aesGCM.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+func (s *storage) encrypt(b []byte) (encryptedBytes []byte, err error) {
+	encryptedBytes, err = s.sealFile(b, nil)
+	if err != nil {
 		s.logger.Debug(err.Error())
 		return nil, err
 	}
-	encryptedBytes = aesGCM.Seal(nonce, nonce, b, nil)
 	return encryptedBytes, nil
 }
 
 func (s *storage) decrypt(b []byte) (decryptedBytes []byte, err error) {
-
-	block, err := aes.NewCipher([]byte(s.masterPass))
+	decryptedBytes, err = s.openFile(b, nil)
 	if err != nil {
 		s.logger.Debug(err.Error())
 		return nil, err
 	}
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		s.logger.Debug(err.Error())
-		return nil, err
-	}
-
-	nonceSize := aesGCM.NonceSize()
-
-	nonce, ciphertext := b[:nonceSize], b[nonceSize:]
-
-	decryptedBytes, err = aesGCM.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		s.logger.Debug(err.Error())
-		return nil, err
-	}
-
 	return decryptedBytes, nil
 }
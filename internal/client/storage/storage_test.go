@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/gob"
 	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/Spear5030/yagophkeeper/pkg/logger"
 	"github.com/spf13/afero"
@@ -27,6 +29,87 @@ func TestEncryptDecrypt(t *testing.T) {
 	require.Equal(t, testData, dec)
 }
 
+func TestMasterPassword(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, err := New("test", "any length passphrase", lg)
+	require.NoError(t, err)
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "secret"}))
+
+	_, err = New("test", "wrong passphrase", lg)
+	require.ErrorIs(t, err, ErrWrongMasterPass)
+	fst2, err := New("test", "any length passphrase", lg)
+	require.NoError(t, err)
+	require.Equal(t, fst.GetTextData(), fst2.GetTextData())
+}
+
+// legacyBody кодирует записи как клиенты до версии формата 2: gob с разделителем 30
+func legacyBody(t *testing.T, fst *storage) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(fst.fileHeaders))
+	buf.WriteByte(30)
+	for _, sc := range fst.secrets() {
+		b, err := encodeSecret(sc.secretType, sc.value)
+		require.NoError(t, err)
+		buf.Write(b)
+		buf.WriteByte(30)
+	}
+	return buf.Bytes()
+}
+
+func TestReadLegacy(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "legacy"}))
+
+	legacy, err := seal([]byte("N1PCdw3M2B1TfJhoaY2mL736p2vCUc47"), legacyBody(t, fst), nil)
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(appFs, "v0", legacy, 0644))
+	fst2, err := New("v0", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Equal(t, fst.GetTextData(), fst2.GetTextData())
+	require.NotEqual(t, fst.kdf.Salt, fst2.kdf.Salt)
+
+	v1, err := seal(fst.key, legacyBody(t, fst), nil)
+	require.NoError(t, err)
+	v1 = append(append(append([]byte(nil), kdfMagic...), fst.kdf.marshal()...), v1...)
+	require.NoError(t, afero.WriteFile(appFs, "v1", v1, 0644))
+	fst3, err := New("v1", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Equal(t, fst.GetTextData(), fst3.GetTextData())
+
+	for _, name := range []string{"v0", "v1"} {
+		upgraded, err := afero.ReadFile(appFs, name)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(upgraded, formatMagic))
+	}
+}
+
+func TestFormatFrames(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	for i := 0; i < 31; i++ {
+		require.NoError(t, fst.AddTextData(domain.TextData{Text: "record\x1eseparator"}))
+	}
+	fst.unknownFrames = [][]byte{{0x7f, 0, 0, 0, 2, 30, 30}}
+	require.NoError(t, fst.UpdateTime())
+
+	fst2, err := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Equal(t, int32(formatVersion), fst2.Version)
+	require.Equal(t, fst.GetTextData(), fst2.GetTextData())
+	require.Equal(t, fst.unknownFrames, fst2.unknownFrames)
+
+	raw, err := afero.ReadFile(appFs, "test")
+	require.NoError(t, err)
+	raw[len(formatMagic)+4]++ // damaged kdf params length
+	require.NoError(t, afero.WriteFile(appFs, "test", raw, 0644))
+	_, err = New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.Error(t, err)
+}
+
 func TestWriteRead(t *testing.T) {
 	lg, _ := logger.New(true)
 	appFs = afero.NewMemMapFs()
@@ -43,6 +126,469 @@ func TestWriteRead(t *testing.T) {
 		Text: "test\ntext",
 	})
 	require.NoError(t, err)
+	err = fst.AddOTP(domain.OTPSecret{
+		Issuer:    "GitHub",
+		Account:   "octocat",
+		Secret:    "JBSWY3DPEHPK3PXP",
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	})
+	require.NoError(t, err)
 	fst2, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
 	require.Equal(t, fst, fst2)
 }
+
+func TestBackups(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	fst.Email = "test@test.ts"
+	fst.SetKeepBackups(2)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, fst.AddLoginPassword(domain.LoginPassword{Key: i, Login: "atata", Password: "dsada"}))
+	}
+	backups, err := fst.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.True(t, backups[0].CreatedAt.After(backups[1].CreatedAt))
+	exists, err := afero.Exists(appFs, "test.tmp")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// последняя копия - хранилище до третьей записи
+	require.NoError(t, fst.RestoreBackup(backups[0].Name))
+	require.Len(t, fst.GetLogins(), 2)
+	fst2, err := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Len(t, fst2.GetLogins(), 2)
+	// текущее хранилище перед восстановлением само попало в копии
+	restored, err := fst.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, restored, 2)
+	require.Equal(t, backups[0].Name, restored[1].Name)
+	require.NoError(t, fst.RestoreBackup(restored[0].Name))
+	require.Len(t, fst.GetLogins(), 3)
+
+	require.ErrorIs(t, fst.RestoreBackup("../test"), ErrBackupNotFound)
+	require.NoError(t, afero.WriteFile(appFs, "test.backups/"+restored[1].Name, []byte("broken"), 0600))
+	require.Error(t, fst.RestoreBackup(restored[1].Name))
+	fst3, err := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Len(t, fst3.GetLogins(), 3)
+}
+
+func TestRecordsSync(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	first, _ := New("first", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	second, _ := New("second", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	first.Email, second.Email = "test@test.ts", "test@test.ts"
+	err := first.AddLoginPassword(domain.LoginPassword{Login: "atata", Password: "dsada"})
+	require.NoError(t, err)
+	err = first.AddCardData(domain.CardData{Number: "4242", CVC: "123"})
+	require.NoError(t, err)
+
+	changed, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	require.Len(t, changed, 2)
+	for i := range changed {
+		changed[i].Revision = int64(i + 1)
+	}
+	err = first.MarkRecordsSynced(changed)
+	require.NoError(t, err)
+	changed2, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	require.Empty(t, changed2)
+
+	err = second.ApplyRecords(changed)
+	require.NoError(t, err)
+	require.Len(t, second.GetLogins(), 1)
+	require.Equal(t, "atata", second.GetLogins()[0].Login)
+	require.Len(t, second.GetCardsData(), 1)
+	require.Equal(t, first.GetCardsData()[0].ID, second.GetCardsData()[0].ID)
+
+	changed[0].Revision = 3
+	changed[0].Deleted = true
+	err = second.ApplyRecords(changed[:1])
+	require.NoError(t, err)
+	require.Equal(t, 1, len(second.GetLogins())+len(second.GetCardsData()))
+}
+
+func TestRestoreRecords(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	st, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	st.Email = "test@test.ts"
+	require.NoError(t, st.AddLoginPassword(domain.LoginPassword{Login: "user", Password: "old"}))
+	require.NoError(t, st.AddCardData(domain.CardData{Number: "4242"}))
+	snapshot, err := st.GetChangedRecords()
+	require.NoError(t, err)
+	for i := range snapshot {
+		snapshot[i].Revision = int64(i + 1)
+	}
+	require.NoError(t, st.MarkRecordsSynced(snapshot))
+
+	// restore без изменений после снимка ничего не отправляет
+	changed, err := st.RestoreRecords(snapshot, snapshot)
+	require.NoError(t, err)
+	require.Zero(t, changed)
+
+	lp := st.GetLogins()[0]
+	lp.Password = "new"
+	require.NoError(t, st.UpdateLoginPassword(lp))
+	require.NoError(t, st.DeleteCardData(st.GetCardsData()[0].Key))
+	require.NoError(t, st.AddTextData(domain.TextData{Text: "note"}))
+	current, err := st.GetChangedRecords()
+	require.NoError(t, err)
+	for i := range current {
+		current[i].Revision = int64(i + 3)
+	}
+	require.NoError(t, st.MarkRecordsSynced(current))
+
+	changed, err = st.RestoreRecords(snapshot, current)
+	require.NoError(t, err)
+	require.Equal(t, 3, changed)
+	require.Equal(t, "old", st.GetLogins()[0].Password)
+	require.Len(t, st.GetCardsData(), 1)
+	require.Empty(t, st.GetTextData())
+
+	// изменения отправляются от текущих серверных ревизий
+	revisions := make(map[string]int64)
+	for _, r := range current {
+		revisions[r.ID] = r.Revision
+	}
+	pending, err := st.GetChangedRecords()
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	for _, r := range pending {
+		require.Equal(t, revisions[r.ID], r.Revision)
+		require.Equal(t, r.ID != lp.ID && r.ID != snapshot[1].ID, r.Deleted)
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	base := domain.LoginPassword{ID: "1", Login: "user", Password: "old", Meta: "site"}
+	tests := []struct {
+		name   string
+		local  domain.LoginPassword
+		remote domain.LoginPassword
+		want   domain.LoginPassword
+		ok     bool
+	}{
+		{
+			name:   "different fields",
+			local:  domain.LoginPassword{Key: 3, ID: "1", Login: "user", Password: "new", Meta: "site"},
+			remote: domain.LoginPassword{Key: 1, ID: "1", Login: "user", Password: "old", Meta: "other site"},
+			want:   domain.LoginPassword{Key: 3, ID: "1", Login: "user", Password: "new", Meta: "other site"},
+			ok:     true,
+		},
+		{
+			name:   "same change",
+			local:  domain.LoginPassword{Key: 3, ID: "1", Login: "user", Password: "new", Meta: "site"},
+			remote: domain.LoginPassword{Key: 1, ID: "1", Login: "user", Password: "new", Meta: "site"},
+			want:   domain.LoginPassword{Key: 3, ID: "1", Login: "user", Password: "new", Meta: "site"},
+			ok:     true,
+		},
+		{
+			name:   "conflict",
+			local:  domain.LoginPassword{Key: 3, ID: "1", Login: "user", Password: "local", Meta: "site"},
+			remote: domain.LoginPassword{Key: 1, ID: "1", Login: "user", Password: "remote", Meta: "site"},
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, ok := mergeFields(base, tt.local, tt.remote)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.want, merged)
+			}
+		})
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+	base := domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+		Title: "site", Tags: []string{"work"}, CreatedAt: t0, UpdatedAt: t0}}
+	tests := []struct {
+		name   string
+		local  domain.LoginPassword
+		remote domain.LoginPassword
+		want   domain.LoginPassword
+		ok     bool
+	}{
+		{
+			name: "different metadata fields",
+			local: domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+				Title: "my site", Tags: []string{"work"}, CreatedAt: t0, UpdatedAt: t2}},
+			remote: domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+				Title: "site", Tags: []string{"work", "dev"}, Favorite: true, CreatedAt: t0, UpdatedAt: t1}},
+			want: domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+				Title: "my site", Tags: []string{"work", "dev"}, Favorite: true, CreatedAt: t0, UpdatedAt: t2}},
+			ok: true,
+		},
+		{
+			name: "metadata conflict",
+			local: domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+				Title: "local", Tags: []string{"work"}, CreatedAt: t0, UpdatedAt: t1}},
+			remote: domain.LoginPassword{ID: "1", Login: "user", Metadata: domain.Metadata{
+				Title: "remote", Tags: []string{"work"}, CreatedAt: t0, UpdatedAt: t2}},
+			ok: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, ok := mergeFields(base, tt.local, tt.remote)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.want, merged)
+			}
+		})
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	first, _ := New("first", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	second, _ := New("second", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	first.Email, second.Email = "test@test.ts", "test@test.ts"
+	require.NoError(t, first.AddLoginPassword(domain.LoginPassword{Login: "user", Password: "old"}))
+	records, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	records[0].Revision = 1
+	require.NoError(t, first.MarkRecordsSynced(records))
+	require.NoError(t, second.ApplyRecords(records))
+
+	lp := first.GetLogins()[0]
+	lp.Password = "first"
+	first.putSecret(TypeLoginPassword, lp, false)
+	first.Dirty[lp.ID] = true
+	lp = second.GetLogins()[0]
+	lp.Password = "second"
+	second.putSecret(TypeLoginPassword, lp, false)
+	second.Dirty[lp.ID] = true
+
+	changed, err := second.GetChangedRecords()
+	require.NoError(t, err)
+	changed[0].Revision = 2
+	require.NoError(t, first.ApplyRecords(changed))
+	conflicts, err := first.GetConflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	pending, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	require.NoError(t, first.ResolveConflict(lp.ID, domain.KeepBoth))
+	require.Empty(t, first.Conflicts)
+	require.Len(t, first.GetLogins(), 2)
+	pending, err = first.GetChangedRecords()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.NotEqual(t, lp.ID, pending[0].ID)
+}
+
+func TestUpdateDelete(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, _ := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	fst.Email = "test@test.ts"
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "first"}))
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "second"}))
+	records, err := fst.GetChangedRecords()
+	require.NoError(t, err)
+	for i := range records {
+		records[i].Revision = int64(i + 1)
+	}
+	require.NoError(t, fst.MarkRecordsSynced(records))
+
+	td, err := fst.GetText(1)
+	require.NoError(t, err)
+	td.Text = "updated"
+	require.NoError(t, fst.UpdateTextData(td))
+	require.ErrorIs(t, fst.UpdateTextData(domain.TextData{Key: 10}), ErrNotFound)
+	require.NoError(t, fst.DeleteTextData(2))
+	require.ErrorIs(t, fst.DeleteTextData(2), ErrNotFound)
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "third"}))
+	require.Equal(t, []int{1, 3}, []int{fst.GetTextData()[0].Key, fst.GetTextData()[1].Key})
+
+	changed, err := fst.GetChangedRecords()
+	require.NoError(t, err)
+	require.Len(t, changed, 3)
+	var deleted []domain.Record
+	for _, r := range changed {
+		if r.Deleted {
+			deleted = append(deleted, r)
+		}
+	}
+	require.Len(t, deleted, 1)
+	require.NotZero(t, deleted[0].Revision)
+
+	fst2, err := New("test", "N1PCdw3M2B1TfJhoaY2mL736p2vCUc47", lg)
+	require.NoError(t, err)
+	require.Equal(t, fst.Tombstones, fst2.Tombstones)
+	// серверная версия, от которой запись удалена, не конфликтует с удалением
+	require.NoError(t, fst.ApplyRecords(records[1:2]))
+	require.Empty(t, fst.Conflicts)
+	require.Len(t, fst.Tombstones, 1)
+	require.NoError(t, fst.MarkRecordsSynced(deleted))
+	require.Empty(t, fst.Tombstones)
+}
+
+func TestNewWithKeyring(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, err := New("test", "passphrase", lg)
+	require.NoError(t, err)
+	fst.Email = "test@test.ts"
+	require.NoError(t, fst.AddTextData(domain.TextData{Text: "secret"}))
+	keys, err := fst.ExportKeys()
+	require.NoError(t, err)
+
+	unlocked, err := NewWithKeyring("test", "", keys, lg)
+	require.NoError(t, err)
+	require.Nil(t, unlocked.key)
+	require.NoError(t, unlocked.CheckMasterPass("passphrase"))
+	require.ErrorIs(t, unlocked.CheckMasterPass("wrong"), ErrWrongMasterPass)
+	_, err = unlocked.ExportKeys()
+	require.ErrorIs(t, err, ErrLocked)
+	require.Equal(t, fst.GetTextData(), unlocked.GetTextData())
+	require.NoError(t, unlocked.AddTextData(domain.TextData{Text: "second"}))
+	records, err := unlocked.GetChangedRecords()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	reopened, err := New("test", "passphrase", lg)
+	require.NoError(t, err)
+	require.Len(t, reopened.GetTextData(), 2)
+
+	_, err = NewWithKeyring("test", "", Keys{}, lg)
+	require.ErrorIs(t, err, ErrLocked)
+	_, err = NewWithKeyring("test", "", nil, lg)
+	require.ErrorIs(t, err, ErrLocked)
+	_, err = NewWithKeyring("new", "", keys, lg)
+	require.ErrorIs(t, err, ErrLocked)
+}
+
+func TestBinaryChunks(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	fst, err := New("test", "passphrase", lg)
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), ChunkSize/16*2+100)
+	chunks, key, size, err := fst.WriteBinaryContent(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.Equal(t, int64(len(content)), size)
+	require.NoError(t, fst.AddBinaryData(domain.BinaryData{Chunks: chunks, ChunkKey: key, Size: size}))
+
+	bd, err := fst.GetBinary(1)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, fst.ReadBinaryContent(bd, &buf))
+	require.Equal(t, content, buf.Bytes())
+	require.ElementsMatch(t, chunks, fst.ChunkRefs())
+	require.Empty(t, fst.MissingChunks(chunks))
+
+	// чанк с чужим id не принимается, отсутствующий чанк загружается заново
+	data, err := fst.GetChunk(chunks[0])
+	require.NoError(t, err)
+	require.ErrorIs(t, fst.PutChunk(chunks[1], data), ErrChunkCorrupted)
+	require.NoError(t, appFs.Remove(fst.chunkPath(chunks[0])))
+	require.Equal(t, chunks[:1], fst.MissingChunks(chunks))
+	require.ErrorIs(t, fst.ReadBinaryContent(bd, &buf), ErrChunkNotFound)
+	require.NoError(t, fst.PutChunk(chunks[0], data))
+
+	// записи старого формата хранят содержимое в BinaryData
+	buf.Reset()
+	require.NoError(t, fst.ReadBinaryContent(domain.BinaryData{BinaryData: []byte("inline")}, &buf))
+	require.Equal(t, "inline", buf.String())
+
+	require.NoError(t, fst.DeleteBinaryData(1))
+	require.Equal(t, chunks, fst.MissingChunks(chunks))
+}
+
+func TestRecordSalt(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	first, _ := New("first", "passphrase", lg)
+	second, _ := New("second", "passphrase", lg)
+	first.Email, second.Email = "test@test.ts", "test@test.ts"
+	require.NoError(t, first.SetRecordSalt([]byte("0123456789abcdef")))
+	require.NoError(t, first.AddLoginPassword(domain.LoginPassword{Login: "atata", Password: "dsada"}))
+	changed, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	changed[0].Revision = 1
+
+	// ключ с солью из email записи аккаунта со случайной солью не открывает
+	require.Error(t, second.ApplyRecords(changed))
+	require.NoError(t, second.SetRecordSalt([]byte("0123456789abcdef")))
+	require.NoError(t, second.ApplyRecords(changed))
+	require.Len(t, second.GetLogins(), 1)
+	reopened, err := New("second", "passphrase", lg)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789abcdef"), reopened.RecordSalt)
+}
+
+func TestRekey(t *testing.T) {
+	lg, _ := logger.New(true)
+	appFs = afero.NewMemMapFs()
+	first, _ := New("first", "old passphrase", lg)
+	second, _ := New("second", "old passphrase", lg)
+	first.Email, second.Email = "test@test.ts", "test@test.ts"
+	require.NoError(t, first.AddLoginPassword(domain.LoginPassword{Login: "atata", Password: "dsada"}))
+	content := bytes.Repeat([]byte("content"), 1000)
+	chunks, key, size, err := first.WriteBinaryContent(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, first.AddBinaryData(domain.BinaryData{FileName: "file", Chunks: chunks, ChunkKey: key, Size: size}))
+	require.ErrorIs(t, first.CheckMasterPass("wrong"), ErrWrongMasterPass)
+	require.NoError(t, first.CheckMasterPass("old passphrase"))
+
+	records, newChunks, params, err := first.RekeyRecords("new passphrase")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.NotEqual(t, chunks, newChunks)
+	// до подтверждения сервером хранилище не меняется
+	require.Equal(t, chunks, first.ChunkRefs())
+	require.Len(t, params.Salt, saltSize)
+	ok, err := first.CheckRecordKey(params.KeyCheck)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	for i := range records {
+		records[i].Revision = int64(i + 1)
+	}
+	require.NoError(t, first.CommitRekey("new passphrase", params.Salt, records, 2))
+	ok, err = first.CheckRecordKey(params.KeyCheck)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, newChunks, first.ChunkRefs())
+	require.Equal(t, chunks, first.MissingChunks(chunks))
+	changed, err := first.GetChangedRecords()
+	require.NoError(t, err)
+	require.Empty(t, changed)
+	_, err = New("first", "old passphrase", lg)
+	require.ErrorIs(t, err, ErrWrongMasterPass)
+	reopened, err := New("first", "new passphrase", lg)
+	require.NoError(t, err)
+	bd, err := reopened.GetBinary(1)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, reopened.ReadBinaryContent(bd, &buf))
+	require.Equal(t, content, buf.Bytes())
+
+	// другое устройство принимает новый пароль только если он открывает keyCheck
+	require.ErrorIs(t, second.AdoptMasterPass("guess", params), ErrWrongMasterPass)
+	// без новой соли новый пароль не открывает keyCheck
+	require.ErrorIs(t, second.AdoptMasterPass("new passphrase", domain.RecordKeyParams{KeyCheck: params.KeyCheck}), ErrWrongMasterPass)
+	require.NoError(t, second.AdoptMasterPass("new passphrase", params))
+	require.NoError(t, second.ApplyRecords(records))
+	require.Len(t, second.GetLogins(), 1)
+	_, err = New("second", "new passphrase", lg)
+	require.NoError(t, err)
+}
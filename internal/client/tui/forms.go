@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/rivo/tview"
+	"os"
+	"path/filepath"
+)
+
+// showForm открывает форму добавления секрета текущего типа или редактирования выбранного, если r не nil
+func (t *TUI) showForm(r *row) {
+	form := tview.NewForm()
+	var save func() error
+	var err error
+	switch t.tab {
+	case tabLogins:
+		lp := domain.LoginPassword{}
+		if r != nil {
+			if lp, err = t.usecase.GetLoginPassword(r.key); err != nil {
+				t.report(err)
+				return
+			}
+		}
+		form.AddInputField("Login", lp.Login, 40, nil, func(text string) { lp.Login = text }).
+			AddPasswordField("Password", lp.Password, 40, '*', func(text string) { lp.Password = text }).
+			AddInputField("Meta", lp.Meta, 40, nil, func(text string) { lp.Meta = text })
+		save = func() error {
+			if r != nil {
+				return t.usecase.UpdateLoginPassword(lp)
+			}
+			return t.usecase.AddLoginPassword(lp)
+		}
+	case tabTexts:
+		td := domain.TextData{}
+		if r != nil {
+			if td, err = t.usecase.GetText(r.key); err != nil {
+				t.report(err)
+				return
+			}
+		}
+		form.AddTextArea("Text", td.Text, 40, 5, 0, func(text string) { td.Text = text }).
+			AddInputField("Meta", td.Meta, 40, nil, func(text string) { td.Meta = text })
+		save = func() error {
+			if r != nil {
+				return t.usecase.UpdateTextData(td)
+			}
+			return t.usecase.AddTextData(td)
+		}
+	case tabCards:
+		card := domain.CardData{}
+		if r != nil {
+			if card, err = t.usecase.GetCard(r.key); err != nil {
+				t.report(err)
+				return
+			}
+		}
+		form.AddInputField("Number", card.Number, 20, nil, func(text string) { card.Number = text }).
+			AddInputField("Card holder", card.CardHolder, 40, nil, func(text string) { card.CardHolder = text }).
+			AddPasswordField("CVC", card.CVC, 4, '*', func(text string) { card.CVC = text }).
+			AddInputField("Meta", card.Meta, 40, nil, func(text string) { card.Meta = text })
+		save = func() error {
+			if r != nil {
+				return t.usecase.UpdateCardData(card)
+			}
+			return t.usecase.AddCardData(card)
+		}
+	case tabBinary:
+		bd := domain.BinaryData{}
+		if r != nil {
+			if bd, err = t.usecase.GetBinary(r.key); err != nil {
+				t.report(err)
+				return
+			}
+		}
+		var path string
+		label := "Path"
+		if r != nil {
+			label = "Path (empty - keep data)"
+		}
+		form.AddInputField(label, "", 40, nil, func(text string) { path = text }).
+			AddInputField("Meta", bd.Meta, 40, nil, func(text string) { bd.Meta = text })
+		save = func() error {
+			if path != "" || r == nil {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				bd.BinaryData = data
+				bd.FileName = filepath.Base(path)
+			}
+			if r != nil {
+				return t.usecase.UpdateBinaryData(bd)
+			}
+			return t.usecase.AddBinaryData(bd)
+		}
+	case tabOTP:
+		otp := domain.OTPSecret{}
+		if r != nil {
+			if otp, err = t.usecase.GetOTP(r.key); err != nil {
+				t.report(err)
+				return
+			}
+		}
+		form.AddInputField("Issuer", otp.Issuer, 40, nil, func(text string) { otp.Issuer = text }).
+			AddInputField("Account", otp.Account, 40, nil, func(text string) { otp.Account = text }).
+			AddPasswordField("Secret (base32)", otp.Secret, 40, '*', func(text string) { otp.Secret = text }).
+			AddInputField("Meta", otp.Meta, 40, nil, func(text string) { otp.Meta = text })
+		save = func() error {
+			if r != nil {
+				return t.usecase.UpdateOTP(otp)
+			}
+			return t.usecase.AddOTP(otp)
+		}
+	}
+
+	closeForm := func() {
+		t.pages.RemovePage("form")
+		t.app.SetFocus(t.table)
+	}
+	form.AddButton("Save", func() {
+		if err := save(); err != nil {
+			t.report(err)
+		}
+		closeForm()
+		t.refresh()
+	}).AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+
+	title := " Add " + tabNames[t.tab] + " "
+	if r != nil {
+		title = " Edit " + tabNames[t.tab] + " "
+	}
+	form.SetBorder(true).SetTitle(title)
+	t.pages.AddPage("form", centered(form, 60, 17), true, true)
+}
+
+// centered размещает окно фиксированного размера по центру экрана
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
@@ -0,0 +1,347 @@
+// Package tui полноэкранный терминальный интерфейс клиента.
+// Работает поверх того же usecase, что и команды cobra.
+package tui
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"strings"
+	"time"
+)
+
+type usecase interface {
+	GetLogins() []domain.LoginPassword
+	GetTextData() []domain.TextData
+	GetBinaryData() []domain.BinaryData
+	GetCardsData() []domain.CardData
+	GetOTPs() []domain.OTPSecret
+	AddLoginPassword(domain.LoginPassword) error
+	AddTextData(domain.TextData) error
+	AddBinaryData(domain.BinaryData) error
+	AddCardData(domain.CardData) error
+	GetLoginPassword(key int) (domain.LoginPassword, error)
+	GetText(key int) (domain.TextData, error)
+	GetBinary(key int) (domain.BinaryData, error)
+	GetCard(key int) (domain.CardData, error)
+	GetOTP(key int) (domain.OTPSecret, error)
+	AddOTP(domain.OTPSecret) error
+	UpdateOTP(domain.OTPSecret) error
+	DeleteOTP(key int) error
+	GetOTPCode(key int) (string, time.Duration, error)
+	UpdateLoginPassword(domain.LoginPassword) error
+	UpdateTextData(domain.TextData) error
+	UpdateBinaryData(domain.BinaryData) error
+	UpdateCardData(domain.CardData) error
+	DeleteLoginPassword(key int) error
+	DeleteTextData(key int) error
+	DeleteBinaryData(key int) error
+	DeleteCardData(key int) error
+	SyncData() error
+	ListConflicts() ([]domain.Conflict, error)
+	GetLocalSyncTime() time.Time
+}
+
+const (
+	tabLogins = iota
+	tabTexts
+	tabCards
+	tabBinary
+	tabOTP
+)
+
+var tabNames = []string{"Logins", "Texts", "Cards", "Binary", "OTP"}
+
+const mask = "••••••••"
+
+const help = "[yellow]Tab[-] type  [yellow]/[-] search  [yellow]r[-] reveal  [yellow]a[-] add  [yellow]e[-] edit  [yellow]d[-] delete  [yellow]s[-] sync  [yellow]q[-] quit"
+
+// row строка таблицы секретов
+type row struct {
+	key int
+	id  string
+	// cells значения колонок, hidden - колонки, скрытые до раскрытия
+	cells  []string
+	hidden []bool
+}
+
+type TUI struct {
+	app      *tview.Application
+	usecase  usecase
+	pages    *tview.Pages
+	tabs     *tview.TextView
+	table    *tview.Table
+	search   *tview.InputField
+	status   *tview.TextView
+	tab      int
+	query    string
+	rows     []row
+	revealed map[string]bool
+	syncInfo string
+	busy     bool
+}
+
+func New(usecase usecase) *TUI {
+	t := &TUI{
+		app:      tview.NewApplication(),
+		usecase:  usecase,
+		revealed: make(map[string]bool),
+	}
+	t.tabs = tview.NewTextView().SetDynamicColors(true).SetRegions(true)
+	t.table = tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	t.table.SetBorder(true)
+	t.search = tview.NewInputField().SetLabel("Search: ")
+	t.search.SetChangedFunc(func(text string) {
+		t.query = text
+		t.refresh()
+	})
+	t.search.SetDoneFunc(func(key tcell.Key) {
+		t.app.SetFocus(t.table)
+	})
+	t.status = tview.NewTextView().SetDynamicColors(true)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(t.tabs, 1, 0, false).
+		AddItem(t.search, 1, 0, false).
+		AddItem(t.table, 0, 1, true).
+		AddItem(t.status, 2, 0, false)
+	t.pages = tview.NewPages().AddPage("main", layout, true, true)
+	t.table.SetInputCapture(t.handleKey)
+	t.app.SetRoot(t.pages, true)
+	t.syncInfo = "not synced in this session"
+	t.refresh()
+	return t
+}
+
+// Run запускает интерфейс и блокируется до выхода пользователя
+func (t *TUI) Run() error {
+	return t.app.Run()
+}
+
+func (t *TUI) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if t.busy {
+		return nil
+	}
+	switch event.Key() {
+	case tcell.KeyTab:
+		t.switchTab((t.tab + 1) % len(tabNames))
+		return nil
+	case tcell.KeyBacktab:
+		t.switchTab((t.tab + len(tabNames) - 1) % len(tabNames))
+		return nil
+	}
+	switch event.Rune() {
+	case 'q':
+		t.app.Stop()
+	case '/':
+		t.app.SetFocus(t.search)
+	case '1', '2', '3', '4', '5':
+		t.switchTab(int(event.Rune() - '1'))
+	case 'r':
+		if r, ok := t.selected(); ok {
+			t.revealed[r.id] = !t.revealed[r.id]
+			t.refresh()
+		}
+	case 'a':
+		t.showForm(nil)
+	case 'e':
+		if r, ok := t.selected(); ok {
+			t.showForm(&r)
+		}
+	case 'd':
+		if r, ok := t.selected(); ok {
+			t.confirmDelete(r)
+		}
+	case 's':
+		t.sync()
+	default:
+		return event
+	}
+	return nil
+}
+
+func (t *TUI) switchTab(tab int) {
+	t.tab = tab
+	t.table.Select(1, 0)
+	t.refresh()
+}
+
+// selected возвращает выбранную строку таблицы
+func (t *TUI) selected() (row, bool) {
+	i, _ := t.table.GetSelection()
+	if i < 1 || i > len(t.rows) {
+		return row{}, false
+	}
+	return t.rows[i-1], true
+}
+
+// refresh перечитывает секреты текущего типа и перерисовывает таблицу, вкладки и строку статуса
+func (t *TUI) refresh() {
+	var tabs []string
+	for i, name := range tabNames {
+		if i == t.tab {
+			tabs = append(tabs, fmt.Sprintf("[black:white] %d %s [-:-]", i+1, name))
+		} else {
+			tabs = append(tabs, fmt.Sprintf(" %d %s ", i+1, name))
+		}
+	}
+	t.tabs.SetText(strings.Join(tabs, " "))
+
+	headers, rows := t.load()
+	t.rows = filterRows(rows, t.query)
+	t.table.Clear()
+	for c, h := range headers {
+		t.table.SetCell(0, c, tview.NewTableCell(h).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+	for i, r := range t.rows {
+		for c, text := range r.cells {
+			if r.hidden[c] && !t.revealed[r.id] {
+				text = mask
+			}
+			t.table.SetCell(i+1, c, tview.NewTableCell(tview.Escape(text)).SetExpansion(1))
+		}
+	}
+	t.table.SetTitle(fmt.Sprintf(" %s (%d) ", tabNames[t.tab], len(t.rows)))
+
+	local := t.usecase.GetLocalSyncTime()
+	localText := "never"
+	if !local.IsZero() {
+		localText = local.Format(time.DateTime)
+	}
+	t.status.SetText(fmt.Sprintf("Local changes: %s | Sync: %s\n%s", localText, t.syncInfo, help))
+}
+
+// load возвращает заголовки колонок и строки секретов текущего типа
+func (t *TUI) load() ([]string, []row) {
+	var rows []row
+	switch t.tab {
+	case tabLogins:
+		for _, lp := range t.usecase.GetLogins() {
+			rows = append(rows, row{key: lp.Key, id: lp.ID,
+				cells:  []string{fmt.Sprint(lp.Key), lp.Login, lp.Password, lp.Meta},
+				hidden: []bool{false, false, true, false}})
+		}
+		return []string{"Key", "Login", "Password", "Meta"}, rows
+	case tabTexts:
+		for _, td := range t.usecase.GetTextData() {
+			rows = append(rows, row{key: td.Key, id: td.ID,
+				cells:  []string{fmt.Sprint(td.Key), firstLine(td.Text), td.Meta},
+				hidden: []bool{false, true, false}})
+		}
+		return []string{"Key", "Text", "Meta"}, rows
+	case tabCards:
+		for _, card := range t.usecase.GetCardsData() {
+			rows = append(rows, row{key: card.Key, id: card.ID,
+				cells:  []string{fmt.Sprint(card.Key), card.Number, card.CardHolder, card.CVC, card.Meta},
+				hidden: []bool{false, true, false, true, false}})
+		}
+		return []string{"Key", "Number", "Card holder", "CVC", "Meta"}, rows
+	case tabBinary:
+		for _, bd := range t.usecase.GetBinaryData() {
+			rows = append(rows, row{key: bd.Key, id: bd.ID,
+				cells:  []string{fmt.Sprint(bd.Key), bd.FileName, fmt.Sprintf("%d bytes", bd.Size), bd.MIMEType, bd.Meta},
+				hidden: []bool{false, false, false, false, false}})
+		}
+		return []string{"Key", "File", "Size", "Type", "Meta"}, rows
+	case tabOTP:
+		for _, otp := range t.usecase.GetOTPs() {
+			code, remaining, err := t.usecase.GetOTPCode(otp.Key)
+			if err != nil {
+				code = err.Error()
+			}
+			rows = append(rows, row{key: otp.Key, id: otp.ID,
+				cells:  []string{fmt.Sprint(otp.Key), otp.Issuer, otp.Account, code, remaining.String(), otp.Meta},
+				hidden: []bool{false, false, false, true, false, false}})
+		}
+		return []string{"Key", "Issuer", "Account", "Code", "Left", "Meta"}, rows
+	}
+	return nil, nil
+}
+
+// filterRows оставляет строки, в открытых колонках которых есть query без учета регистра
+func filterRows(rows []row, query string) []row {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return rows
+	}
+	var result []row
+	for _, r := range rows {
+		for c, text := range r.cells {
+			if !r.hidden[c] && strings.Contains(strings.ToLower(text), query) {
+				result = append(result, r)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i] + " …"
+	}
+	return text
+}
+
+// sync синхронизирует секреты в фоне, пока интерфейс показывает статус
+func (t *TUI) sync() {
+	t.busy = true
+	t.syncInfo = "[yellow]syncing...[-]"
+	t.refresh()
+	go func() {
+		err := t.usecase.SyncData()
+		t.app.QueueUpdateDraw(func() {
+			t.busy = false
+			switch conflicts, errConflicts := t.usecase.ListConflicts(); {
+			case err != nil:
+				t.syncInfo = "[red]" + tview.Escape(err.Error()) + "[-]"
+			case errConflicts != nil:
+				t.syncInfo = "[red]" + tview.Escape(errConflicts.Error()) + "[-]"
+			case len(conflicts) > 0:
+				t.syncInfo = fmt.Sprintf("[red]%d conflicts, resolve with `client resolve`[-] at %s", len(conflicts), time.Now().Format(time.TimeOnly))
+			default:
+				t.syncInfo = "[green]synced[-] at " + time.Now().Format(time.TimeOnly)
+			}
+			t.refresh()
+		})
+	}()
+}
+
+func (t *TUI) confirmDelete(r row) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Delete %s with key %d?", strings.ToLower(tabNames[t.tab]), r.key)).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			t.pages.RemovePage("modal")
+			if label == "Delete" {
+				t.report(t.delete(r.key))
+			}
+			t.app.SetFocus(t.table)
+		})
+	t.pages.AddPage("modal", modal, true, true)
+}
+
+func (t *TUI) delete(key int) error {
+	switch t.tab {
+	case tabLogins:
+		return t.usecase.DeleteLoginPassword(key)
+	case tabTexts:
+		return t.usecase.DeleteTextData(key)
+	case tabCards:
+		return t.usecase.DeleteCardData(key)
+	case tabBinary:
+		return t.usecase.DeleteBinaryData(key)
+	case tabOTP:
+		return t.usecase.DeleteOTP(key)
+	}
+	return nil
+}
+
+// report показывает ошибку в строке статуса или перерисовывает таблицу после изменения
+func (t *TUI) report(err error) {
+	if err != nil {
+		t.syncInfo = "[red]" + tview.Escape(err.Error()) + "[-]"
+	}
+	t.refresh()
+}
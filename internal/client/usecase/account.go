@@ -0,0 +1,23 @@
+package usecase
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+)
+
+// ChangeAccountPassword меняет пароль аккаунта на сервере. Другие устройства должны войти заново,
+// это устройство сохраняет новые токены
+func (u *usecase) ChangeAccountPassword(password string, newPassword string) error {
+	tokens, err := u.network.ChangePassword(password, newPassword)
+	if err != nil {
+		return err
+	}
+	return u.storage.SaveUserData(domain.User{Email: u.storage.GetEmail(), Password: newPassword}, tokens)
+}
+
+// DeleteAccount удаляет аккаунт и все данные на сервере. Локальные секреты остаются в хранилище
+func (u *usecase) DeleteAccount(password string) error {
+	if err := u.network.DeleteAccount(password); err != nil {
+		return err
+	}
+	return u.storage.ForgetAccount()
+}
@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+var ErrContentMismatch = errors.New("binary content does not match recorded size and SHA-256")
+
+// sniffLen сколько байт начала содержимого использует http.DetectContentType
+const sniffLen = 512
+
+// AddBinaryFile добавляет binary, содержимое читается из r по чанкам без загрузки файла в память целиком
+func (u *usecase) AddBinaryFile(bd domain.BinaryData, r io.Reader) error {
+	if err := u.storeContent(&bd, r); err != nil {
+		return err
+	}
+	return u.AddBinaryData(bd)
+}
+
+// UpdateBinaryFile заменяет содержимое binary содержимым r
+func (u *usecase) UpdateBinaryFile(bd domain.BinaryData, r io.Reader) error {
+	if err := u.storeContent(&bd, r); err != nil {
+		return err
+	}
+	return u.UpdateBinaryData(bd)
+}
+
+// GetBinaryContent записывает содержимое binary в w. Чанки, которых нет локально, загружаются с сервера.
+// Если при добавлении записан SHA256, содержимое сверяется с ним и размером, при расхождении
+// возвращается ErrContentMismatch - в w к этому моменту уже записаны данные, их нужно отбросить
+func (u *usecase) GetBinaryContent(key int, w io.Writer) error {
+	bd, err := u.storage.GetBinary(key)
+	if err != nil {
+		return err
+	}
+	if missing := u.storage.MissingChunks(bd.Chunks); len(missing) > 0 {
+		if err = u.network.DownloadChunks(missing, u.storage.PutChunk); err != nil {
+			return err
+		}
+	}
+	info := newContentInfo()
+	if err = u.storage.ReadBinaryContent(bd, io.MultiWriter(w, info)); err != nil {
+		return err
+	}
+	if bd.SHA256 != "" && (info.sum() != bd.SHA256 || info.size != bd.Size) {
+		return ErrContentMismatch
+	}
+	return nil
+}
+
+// storeContent сохраняет содержимое r зашифрованными чанками и записывает в bd их список,
+// размер, sha256 и тип содержимого. Тип определяется по расширению FileName, иначе по сигнатуре
+func (u *usecase) storeContent(bd *domain.BinaryData, r io.Reader) error {
+	info := newContentInfo()
+	chunks, key, size, err := u.storage.WriteBinaryContent(io.TeeReader(r, info))
+	if err != nil {
+		return err
+	}
+	bd.Chunks, bd.ChunkKey, bd.Size, bd.BinaryData = chunks, key, size, nil
+	bd.SHA256 = info.sum()
+	bd.MIMEType = mime.TypeByExtension(filepath.Ext(bd.FileName))
+	if bd.MIMEType == "" {
+		bd.MIMEType = http.DetectContentType(info.head)
+	}
+	return nil
+}
+
+// contentInfo считает размер и sha256 записанного содержимого и сохраняет его начало для определения типа
+type contentInfo struct {
+	hash hash.Hash
+	size int64
+	head []byte
+}
+
+func newContentInfo() *contentInfo {
+	return &contentInfo{hash: sha256.New()}
+}
+
+func (c *contentInfo) Write(p []byte) (int, error) {
+	if n := sniffLen - len(c.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		c.head = append(c.head, p[:n]...)
+	}
+	c.size += int64(len(p))
+	return c.hash.Write(p)
+}
+
+func (c *contentInfo) sum() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
+
+// chunkInline переносит в чанки содержимое, переданное в BinaryData (TUI, импорт, записи старого формата)
+func (u *usecase) chunkInline(bd *domain.BinaryData) error {
+	if len(bd.BinaryData) == 0 {
+		return nil
+	}
+	return u.storeContent(bd, bytes.NewReader(bd.BinaryData))
+}
+
+// binarySize размер содержимого binary, в том числе старого формата
+func binarySize(bd domain.BinaryData) int64 {
+	if len(bd.ChunkKey) == 0 {
+		return int64(len(bd.BinaryData))
+	}
+	return bd.Size
+}
+
+// uploadChunks отправляет на сервер чанки, которых там нет. Вызывается до отправки записей,
+// чтобы другие устройства не получили запись без содержимого. После обрыва повторная синхронизация
+// отправляет только оставшиеся чанки
+func (u *usecase) uploadChunks() error {
+	refs := u.storage.ChunkRefs()
+	if len(refs) == 0 {
+		return nil
+	}
+	missingLocal := make(map[string]bool)
+	for _, id := range u.storage.MissingChunks(refs) {
+		missingLocal[id] = true
+	}
+	var local []string
+	for _, id := range refs {
+		if !missingLocal[id] {
+			local = append(local, id)
+		}
+	}
+	missing, err := u.network.MissingChunks(local)
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	u.logger.Debug("upload chunks", zap.Int("count", len(missing)))
+	return u.network.UploadChunks(missing, u.storage.GetChunk)
+}
+
+// downloadChunks загружает с сервера чанки binary, которых нет локально
+func (u *usecase) downloadChunks() error {
+	missing := u.storage.MissingChunks(u.storage.ChunkRefs())
+	if len(missing) == 0 {
+		return nil
+	}
+	u.logger.Debug("download chunks", zap.Int("count", len(missing)))
+	return u.network.DownloadChunks(missing, u.storage.PutChunk)
+}
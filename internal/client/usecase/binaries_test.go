@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// binaryStorage хранилище одного binary, содержимое хранится открытым в content
+type binaryStorage struct {
+	storage
+	bd      domain.BinaryData
+	content []byte
+}
+
+func (s *binaryStorage) WriteBinaryContent(r io.Reader) ([]string, []byte, int64, error) {
+	var err error
+	s.content, err = io.ReadAll(r)
+	return []string{"chunk"}, []byte("key"), int64(len(s.content)), err
+}
+
+func (s *binaryStorage) ReadBinaryContent(_ domain.BinaryData, w io.Writer) error {
+	_, err := w.Write(s.content)
+	return err
+}
+
+func (s *binaryStorage) MissingChunks([]string) []string {
+	return nil
+}
+
+func (s *binaryStorage) GetBinary(int) (domain.BinaryData, error) {
+	return s.bd, nil
+}
+
+func TestBinaryContent(t *testing.T) {
+	st := &binaryStorage{}
+	u := &usecase{storage: st}
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 1000)...)
+	bd := domain.BinaryData{FileName: "photo"}
+	require.NoError(t, u.storeContent(&bd, bytes.NewReader(png)))
+	require.Equal(t, int64(len(png)), bd.Size)
+	require.Equal(t, "image/png", bd.MIMEType)
+	sum := sha256.Sum256(png)
+	require.Equal(t, hex.EncodeToString(sum[:]), bd.SHA256)
+
+	// тип по расширению важнее сигнатуры
+	pdf := domain.BinaryData{FileName: "doc.pdf"}
+	require.NoError(t, u.storeContent(&pdf, bytes.NewReader([]byte("text"))))
+	require.Equal(t, "application/pdf", pdf.MIMEType)
+
+	st.bd = bd
+	st.content = png
+	var buf bytes.Buffer
+	require.NoError(t, u.GetBinaryContent(1, &buf))
+	require.Equal(t, png, buf.Bytes())
+
+	st.content = append([]byte(nil), png...)
+	st.content[100] ^= 1
+	require.ErrorIs(t, u.GetBinaryContent(1, io.Discard), ErrContentMismatch)
+	st.content = png[:100]
+	require.ErrorIs(t, u.GetBinaryContent(1, io.Discard), ErrContentMismatch)
+
+	// у записей без SHA256 содержимое не проверяется
+	st.bd = domain.BinaryData{BinaryData: []byte("legacy")}
+	require.NoError(t, u.GetBinaryContent(1, io.Discard))
+	// размер записи старого формата считается по встроенному содержимому
+	legacy, err := u.GetBinary(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(len("legacy")), legacy.Size)
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/client/exporter"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+)
+
+// Export выгружает все секреты в открытом виде в формате JSON или CSV.
+// Возвращает список того, что в формат не вошло
+func (u *usecase) Export(format string) ([]byte, []string, error) {
+	v, err := u.vault()
+	if err != nil {
+		return nil, nil, err
+	}
+	return exporter.Export(format, v)
+}
+
+// ExportArchive выгружает все секреты в архив, зашифрованный паролем, независимым от мастер-пароля
+func (u *usecase) ExportArchive(password string) ([]byte, error) {
+	v, err := u.vault()
+	if err != nil {
+		return nil, err
+	}
+	return exporter.Seal(v, password)
+}
+
+// vault возвращает все секреты. Содержимое binary переносится из чанков в BinaryData,
+// ключи чанков в экспорт не попадают
+func (u *usecase) vault() (domain.Vault, error) {
+	bds := u.storage.GetBinaryData()
+	for i, bd := range bds {
+		if len(bd.ChunkKey) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := u.GetBinaryContent(bd.Key, &buf); err != nil {
+			return domain.Vault{}, fmt.Errorf("binary %d: %w", bd.Key, err)
+		}
+		bds[i].BinaryData, bds[i].Chunks, bds[i].ChunkKey = buf.Bytes(), nil, nil
+	}
+	return domain.Vault{
+		Logins:   u.storage.GetLogins(),
+		Texts:    u.storage.GetTextData(),
+		Cards:    u.storage.GetCardsData(),
+		Binaries: bds,
+		OTPs:     u.storage.GetOTPs(),
+	}, nil
+}
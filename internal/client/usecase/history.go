@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ListVaultVersions возвращает версии хранилища, сохраненные сервером, новые первыми
+func (u *usecase) ListVaultVersions() ([]domain.VaultVersion, error) {
+	return u.network.ListVersions()
+}
+
+// RestoreVersion возвращает хранилище к версии number и отправляет результат на сервер.
+// Перед этим текущее состояние синхронизируется и само остается в истории, поэтому восстановление
+// можно отменить, восстановив предыдущую версию. Возвращает число измененных записей
+func (u *usecase) RestoreVersion(number int64) (int, error) {
+	_, snapshot, err := u.network.GetVersion(number)
+	if err != nil {
+		return 0, err
+	}
+	if err = u.SyncData(); err != nil {
+		return 0, err
+	}
+	current, _, err := u.network.PullRecords(0)
+	if err != nil {
+		return 0, err
+	}
+	changed, err := u.storage.RestoreRecords(snapshot, current)
+	if err != nil {
+		return 0, err
+	}
+	u.logger.Debug("restore version", zap.Int64("version", number), zap.Int("changed", changed))
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, u.SyncData()
+}
+
+// ListBackups возвращает локальные резервные копии файла хранилища, новые первыми
+func (u *usecase) ListBackups() ([]domain.Backup, error) {
+	return u.storage.ListBackups()
+}
+
+// RestoreBackup заменяет локальное хранилище резервной копией. Изменения, сделанные на сервере
+// после копии, вернутся при следующей синхронизации
+func (u *usecase) RestoreBackup(name string) error {
+	return u.storage.RestoreBackup(name)
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/client/exporter"
+	"github.com/Spear5030/yagophkeeper/internal/client/importer"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"time"
+)
+
+// Import переносит записи из экспорта другого менеджера паролей. При dryRun ничего не сохраняется,
+// отчет показывает, что было бы создано. Записи с некорректными метаданными или секретом TOTP
+// не создаются и попадают в Dropped
+func (u *usecase) Import(format string, data []byte, dryRun bool) (domain.ImportReport, error) {
+	res, err := importer.Parse(format, data)
+	if err != nil {
+		return domain.ImportReport{}, err
+	}
+	return u.importResult(res, dryRun)
+}
+
+// ImportArchive загружает архив, созданный ExportArchive, на этой или другой установке
+func (u *usecase) ImportArchive(data []byte, password string, dryRun bool) (domain.ImportReport, error) {
+	v, err := exporter.Open(data, password)
+	if err != nil {
+		return domain.ImportReport{}, err
+	}
+	return u.importResult(importer.Result{Vault: v}, dryRun)
+}
+
+func (u *usecase) importResult(res importer.Result, dryRun bool) (domain.ImportReport, error) {
+	var err error
+	report := domain.ImportReport{Dropped: res.Dropped}
+	// add нормализует метаданные и сохраняет запись, если это не пробный запуск.
+	// name - подпись записи в отчете, если у нее нет названия
+	add := func(secretType, name string, m *domain.Metadata, save func() error) error {
+		title := m.Title
+		if title == "" {
+			title = name
+		}
+		normalized, err := normalizeMetadata(*m)
+		if err != nil {
+			report.Dropped = append(report.Dropped, fmt.Sprintf("%s %q: %v", secretType, title, err))
+			return nil
+		}
+		*m = normalized
+		if !dryRun {
+			if err = save(); err != nil {
+				return fmt.Errorf("%s %q: %w", secretType, title, err)
+			}
+		}
+		report.Created = append(report.Created, fmt.Sprintf("%s: %s", secretType, title))
+		return nil
+	}
+
+	for _, lp := range res.Logins {
+		lp := lp
+		if err = add("login", lp.Login, &lp.Metadata, func() error { return u.storage.AddLoginPassword(lp) }); err != nil {
+			return report, err
+		}
+	}
+	for _, td := range res.Texts {
+		td := td
+		if err = add("text", "", &td.Metadata, func() error { return u.storage.AddTextData(td) }); err != nil {
+			return report, err
+		}
+	}
+	for _, card := range res.Cards {
+		card := card
+		if err = add("card", card.CardHolder, &card.Metadata, func() error { return u.storage.AddCardData(card) }); err != nil {
+			return report, err
+		}
+	}
+	for _, bd := range res.Binaries {
+		bd := bd
+		if err = add("binary", "", &bd.Metadata, func() error {
+			if err := u.chunkInline(&bd); err != nil {
+				return err
+			}
+			return u.storage.AddBinaryData(bd)
+		}); err != nil {
+			return report, err
+		}
+	}
+	for _, otp := range res.OTPs {
+		otp := withOTPDefaults(otp)
+		if err = otpKey(otp).Validate(); err != nil {
+			report.Dropped = append(report.Dropped, fmt.Sprintf("otp %q: %v", otp.Title, err))
+			continue
+		}
+		if err = add("otp", otp.Issuer, &otp.Metadata, func() error { return u.storage.AddOTP(otp) }); err != nil {
+			return report, err
+		}
+	}
+
+	if dryRun || len(report.Created) == 0 {
+		return report, nil
+	}
+	u.localSyncTime = time.Now()
+	return report, u.storage.UpdateTime()
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidField = errors.New("invalid custom field")
+
+// normalizeMetadata проверяет метаданные и приводит их к единому виду:
+// убирает пробелы и повторы тегов и URL, лишние "/" в пути папки
+func normalizeMetadata(m domain.Metadata) (domain.Metadata, error) {
+	m.Title = strings.TrimSpace(m.Title)
+	m.Tags = uniq(m.Tags, strings.ToLower)
+	m.URLs = uniq(m.URLs, func(s string) string { return s })
+	for _, u := range m.URLs {
+		if _, err := url.Parse(u); err != nil {
+			return m, fmt.Errorf("invalid url %q: %w", u, err)
+		}
+	}
+	var parts []string
+	for _, p := range strings.Split(m.Folder, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	m.Folder = strings.Join(parts, "/")
+
+	m.Fields = append([]domain.CustomField(nil), m.Fields...)
+	names := make(map[string]bool)
+	for i, f := range m.Fields {
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" {
+			return m, fmt.Errorf("%w: empty name", ErrInvalidField)
+		}
+		if names[f.Name] {
+			return m, fmt.Errorf("%w: duplicate name %q", ErrInvalidField, f.Name)
+		}
+		names[f.Name] = true
+		switch f.Type {
+		case "":
+			f.Type = domain.FieldText
+		case domain.FieldText, domain.FieldHidden:
+		case domain.FieldBoolean:
+			b, err := parseBool(f.Value)
+			if err != nil {
+				return m, fmt.Errorf("%w: %s is not boolean", ErrInvalidField, f.Name)
+			}
+			f.Value = strconv.FormatBool(b)
+		default:
+			return m, fmt.Errorf("%w: unknown type %q", ErrInvalidField, f.Type)
+		}
+		m.Fields[i] = f
+	}
+	return m, nil
+}
+
+// uniq убирает пустые значения и повторы с точностью до key, сохраняя порядок
+func uniq(values []string, key func(string) string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[key(v)] {
+			continue
+		}
+		seen[key(v)] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// parseBool понимает кроме значений strconv.ParseBool yes/no и on/off
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "y", "on":
+		return true, nil
+	case "no", "n", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	fields := []domain.CustomField{{Name: " pin ", Value: "1234"}, {Name: "2fa", Type: domain.FieldBoolean, Value: "1"}}
+	m, err := normalizeMetadata(domain.Metadata{
+		Title:  " Bank ",
+		Tags:   []string{"Work", " work", "", "home"},
+		URLs:   []string{"https://bank.example", "https://bank.example"},
+		Folder: "/finance//cards/",
+		Fields: fields,
+	})
+	require.NoError(t, err)
+	require.Equal(t, domain.Metadata{
+		Title:  "Bank",
+		Tags:   []string{"Work", "home"},
+		URLs:   []string{"https://bank.example"},
+		Folder: "finance/cards",
+		Fields: []domain.CustomField{
+			{Name: "pin", Type: domain.FieldText, Value: "1234"},
+			{Name: "2fa", Type: domain.FieldBoolean, Value: "true"},
+		},
+	}, m)
+	require.Equal(t, " pin ", fields[0].Name, "input must not be modified")
+
+	_, err = normalizeMetadata(domain.Metadata{Fields: []domain.CustomField{{Name: "a"}, {Name: "a"}}})
+	require.ErrorIs(t, err, ErrInvalidField)
+	_, err = normalizeMetadata(domain.Metadata{Fields: []domain.CustomField{{Name: "a", Type: domain.FieldBoolean, Value: "maybe"}}})
+	require.ErrorIs(t, err, ErrInvalidField)
+	_, err = normalizeMetadata(domain.Metadata{Fields: []domain.CustomField{{Name: "a", Type: "date"}}})
+	require.ErrorIs(t, err, ErrInvalidField)
+}
@@ -6,6 +6,7 @@ import (
 	domain "github.com/Spear5030/yagophkeeper/internal/domain"
 	mock "github.com/stretchr/testify/mock"
 
+	io "io"
 	time "time"
 )
 
@@ -56,66 +57,632 @@ func (_m *storage) AddLoginPassword(_a0 domain.LoginPassword) error {
 	return r0
 }
 
+// AddOTP provides a mock function with given fields: _a0
+func (_m *storage) AddOTP(_a0 domain.OTPSecret) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.OTPSecret) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // AddTextData provides a mock function with given fields: _a0
 func (_m *storage) AddTextData(_a0 domain.TextData) error {
 	ret := _m.Called(_a0)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.TextData) error); ok {
-		r0 = rf(_a0)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.TextData) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AdoptMasterPass provides a mock function with given fields: newPass, params
+func (_m *storage) AdoptMasterPass(newPass string, params domain.RecordKeyParams) error {
+	ret := _m.Called(newPass, params)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, domain.RecordKeyParams) error); ok {
+		r0 = rf(newPass, params)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ApplyRecords provides a mock function with given fields: records
+func (_m *storage) ApplyRecords(records []domain.Record) error {
+	ret := _m.Called(records)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]domain.Record) error); ok {
+		r0 = rf(records)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ChangeMasterPass provides a mock function with given fields: newPass
+func (_m *storage) ChangeMasterPass(newPass string) error {
+	ret := _m.Called(newPass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(newPass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckMasterPass provides a mock function with given fields: pass
+func (_m *storage) CheckMasterPass(pass string) error {
+	ret := _m.Called(pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckRecordKey provides a mock function with given fields: keyCheck
+func (_m *storage) CheckRecordKey(keyCheck []byte) (bool, error) {
+	ret := _m.Called(keyCheck)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]byte) (bool, error)); ok {
+		return rf(keyCheck)
+	}
+	if rf, ok := ret.Get(0).(func([]byte) bool); ok {
+		r0 = rf(keyCheck)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func([]byte) error); ok {
+		r1 = rf(keyCheck)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChunkRefs provides a mock function with given fields:
+func (_m *storage) ChunkRefs() []string {
+	ret := _m.Called()
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// CommitRekey provides a mock function with given fields: newPass, salt, records, revision
+func (_m *storage) CommitRekey(newPass string, salt []byte, records []domain.Record, revision int64) error {
+	ret := _m.Called(newPass, salt, records, revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte, []domain.Record, int64) error); ok {
+		r0 = rf(newPass, salt, records, revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteBinaryData provides a mock function with given fields: key
+func (_m *storage) DeleteBinaryData(key int) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteCardData provides a mock function with given fields: key
+func (_m *storage) DeleteCardData(key int) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteLoginPassword provides a mock function with given fields: key
+func (_m *storage) DeleteLoginPassword(key int) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOTP provides a mock function with given fields: key
+func (_m *storage) DeleteOTP(key int) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeletePasswordProfile provides a mock function with given fields: name
+func (_m *storage) DeletePasswordProfile(name string) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteTextData provides a mock function with given fields: key
+func (_m *storage) DeleteTextData(key int) error {
+	ret := _m.Called(key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ForgetAccount provides a mock function with given fields:
+func (_m *storage) ForgetAccount() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBinary provides a mock function with given fields: key
+func (_m *storage) GetBinary(key int) (domain.BinaryData, error) {
+	ret := _m.Called(key)
+
+	var r0 domain.BinaryData
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (domain.BinaryData, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(int) domain.BinaryData); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(domain.BinaryData)
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBinaryData provides a mock function with given fields:
+func (_m *storage) GetBinaryData() []domain.BinaryData {
+	ret := _m.Called()
+
+	var r0 []domain.BinaryData
+	if rf, ok := ret.Get(0).(func() []domain.BinaryData); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.BinaryData)
+		}
+	}
+
+	return r0
+}
+
+// GetCard provides a mock function with given fields: key
+func (_m *storage) GetCard(key int) (domain.CardData, error) {
+	ret := _m.Called(key)
+
+	var r0 domain.CardData
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (domain.CardData, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(int) domain.CardData); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(domain.CardData)
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCardsData provides a mock function with given fields:
+func (_m *storage) GetCardsData() []domain.CardData {
+	ret := _m.Called()
+
+	var r0 []domain.CardData
+	if rf, ok := ret.Get(0).(func() []domain.CardData); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.CardData)
+		}
+	}
+
+	return r0
+}
+
+// GetChangedRecords provides a mock function with given fields:
+func (_m *storage) GetChangedRecords() ([]domain.Record, error) {
+	ret := _m.Called()
+
+	var r0 []domain.Record
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]domain.Record, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []domain.Record); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetChunk provides a mock function with given fields: id
+func (_m *storage) GetChunk(id string) ([]byte, error) {
+	ret := _m.Called(id)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]byte, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetConflicts provides a mock function with given fields:
+func (_m *storage) GetConflicts() ([]domain.Conflict, error) {
+	ret := _m.Called()
+
+	var r0 []domain.Conflict
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]domain.Conflict, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []domain.Conflict); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Conflict)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetData provides a mock function with given fields:
+func (_m *storage) GetData() ([]byte, error) {
+	ret := _m.Called()
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]byte, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEmail provides a mock function with given fields:
+func (_m *storage) GetEmail() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// GetLocalSyncTime provides a mock function with given fields:
+func (_m *storage) GetLocalSyncTime() time.Time {
+	ret := _m.Called()
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func() time.Time); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	return r0
+}
+
+// GetLoginPassword provides a mock function with given fields: key
+func (_m *storage) GetLoginPassword(key int) (domain.LoginPassword, error) {
+	ret := _m.Called(key)
+
+	var r0 domain.LoginPassword
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (domain.LoginPassword, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(int) domain.LoginPassword); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(domain.LoginPassword)
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLogins provides a mock function with given fields:
+func (_m *storage) GetLogins() []domain.LoginPassword {
+	ret := _m.Called()
+
+	var r0 []domain.LoginPassword
+	if rf, ok := ret.Get(0).(func() []domain.LoginPassword); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.LoginPassword)
+		}
+	}
+
+	return r0
+}
+
+// GetOTP provides a mock function with given fields: key
+func (_m *storage) GetOTP(key int) (domain.OTPSecret, error) {
+	ret := _m.Called(key)
+
+	var r0 domain.OTPSecret
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (domain.OTPSecret, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(int) domain.OTPSecret); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(domain.OTPSecret)
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOTPs provides a mock function with given fields:
+func (_m *storage) GetOTPs() []domain.OTPSecret {
+	ret := _m.Called()
+
+	var r0 []domain.OTPSecret
+	if rf, ok := ret.Get(0).(func() []domain.OTPSecret); ok {
+		r0 = rf()
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.OTPSecret)
+		}
 	}
 
 	return r0
 }
 
-// GetBinaryData provides a mock function with given fields:
-func (_m *storage) GetBinaryData() []domain.BinaryData {
+// GetPasswordProfiles provides a mock function with given fields:
+func (_m *storage) GetPasswordProfiles() map[string]domain.PasswordPolicy {
 	ret := _m.Called()
 
-	var r0 []domain.BinaryData
-	if rf, ok := ret.Get(0).(func() []domain.BinaryData); ok {
+	var r0 map[string]domain.PasswordPolicy
+	if rf, ok := ret.Get(0).(func() map[string]domain.PasswordPolicy); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.BinaryData)
+			r0 = ret.Get(0).(map[string]domain.PasswordPolicy)
 		}
 	}
 
 	return r0
 }
 
-// GetCardsData provides a mock function with given fields:
-func (_m *storage) GetCardsData() []domain.CardData {
+// GetSyncRevision provides a mock function with given fields:
+func (_m *storage) GetSyncRevision() int64 {
 	ret := _m.Called()
 
-	var r0 []domain.CardData
-	if rf, ok := ret.Get(0).(func() []domain.CardData); ok {
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	return r0
+}
+
+// GetText provides a mock function with given fields: key
+func (_m *storage) GetText(key int) (domain.TextData, error) {
+	ret := _m.Called(key)
+
+	var r0 domain.TextData
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) (domain.TextData, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(int) domain.TextData); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(domain.TextData)
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTextData provides a mock function with given fields:
+func (_m *storage) GetTextData() []domain.TextData {
+	ret := _m.Called()
+
+	var r0 []domain.TextData
+	if rf, ok := ret.Get(0).(func() []domain.TextData); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.CardData)
+			r0 = ret.Get(0).([]domain.TextData)
 		}
 	}
 
 	return r0
 }
 
-// GetData provides a mock function with given fields:
-func (_m *storage) GetData() ([]byte, error) {
+// GetTokens provides a mock function with given fields:
+func (_m *storage) GetTokens() domain.Tokens {
 	ret := _m.Called()
 
-	var r0 []byte
+	var r0 domain.Tokens
+	if rf, ok := ret.Get(0).(func() domain.Tokens); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(domain.Tokens)
+	}
+
+	return r0
+}
+
+// ListBackups provides a mock function with given fields:
+func (_m *storage) ListBackups() ([]domain.Backup, error) {
+	ret := _m.Called()
+
+	var r0 []domain.Backup
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]byte, error)); ok {
+	if rf, ok := ret.Get(0).(func() ([]domain.Backup, error)); ok {
 		return rf()
 	}
-	if rf, ok := ret.Get(0).(func() []byte); ok {
+	if rf, ok := ret.Get(0).(func() []domain.Backup); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).([]domain.Backup)
 		}
 	}
 
@@ -128,59 +695,193 @@ func (_m *storage) GetData() ([]byte, error) {
 	return r0, r1
 }
 
-// GetLocalSyncTime provides a mock function with given fields:
-func (_m *storage) GetLocalSyncTime() time.Time {
-	ret := _m.Called()
+// MarkRecordsSynced provides a mock function with given fields: records
+func (_m *storage) MarkRecordsSynced(records []domain.Record) error {
+	ret := _m.Called(records)
 
-	var r0 time.Time
-	if rf, ok := ret.Get(0).(func() time.Time); ok {
-		r0 = rf()
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]domain.Record) error); ok {
+		r0 = rf(records)
 	} else {
-		r0 = ret.Get(0).(time.Time)
+		r0 = ret.Error(0)
 	}
 
 	return r0
 }
 
-// GetLogins provides a mock function with given fields:
-func (_m *storage) GetLogins() []domain.LoginPassword {
-	ret := _m.Called()
+// MissingChunks provides a mock function with given fields: ids
+func (_m *storage) MissingChunks(ids []string) []string {
+	ret := _m.Called(ids)
 
-	var r0 []domain.LoginPassword
-	if rf, ok := ret.Get(0).(func() []domain.LoginPassword); ok {
-		r0 = rf()
+	var r0 []string
+	if rf, ok := ret.Get(0).(func([]string) []string); ok {
+		r0 = rf(ids)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.LoginPassword)
+			r0 = ret.Get(0).([]string)
 		}
 	}
 
 	return r0
 }
 
-// GetTextData provides a mock function with given fields:
-func (_m *storage) GetTextData() []domain.TextData {
-	ret := _m.Called()
+// PutChunk provides a mock function with given fields: id, data
+func (_m *storage) PutChunk(id string, data []byte) error {
+	ret := _m.Called(id, data)
 
-	var r0 []domain.TextData
-	if rf, ok := ret.Get(0).(func() []domain.TextData); ok {
-		r0 = rf()
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte) error); ok {
+		r0 = rf(id, data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReadBinaryContent provides a mock function with given fields: bd, w
+func (_m *storage) ReadBinaryContent(bd domain.BinaryData, w io.Writer) error {
+	ret := _m.Called(bd, w)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.BinaryData, io.Writer) error); ok {
+		r0 = rf(bd, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RekeyRecords provides a mock function with given fields: newPass
+func (_m *storage) RekeyRecords(newPass string) ([]domain.Record, []string, domain.RecordKeyParams, error) {
+	ret := _m.Called(newPass)
+
+	var r0 []domain.Record
+	var r1 []string
+	var r2 domain.RecordKeyParams
+	var r3 error
+	if rf, ok := ret.Get(0).(func(string) ([]domain.Record, []string, domain.RecordKeyParams, error)); ok {
+		return rf(newPass)
+	}
+	if rf, ok := ret.Get(0).(func(string) []domain.Record); ok {
+		r0 = rf(newPass)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.TextData)
+			r0 = ret.Get(0).([]domain.Record)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) []string); ok {
+		r1 = rf(newPass)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]string)
 		}
 	}
 
+	if rf, ok := ret.Get(2).(func(string) domain.RecordKeyParams); ok {
+		r2 = rf(newPass)
+	} else {
+		r2 = ret.Get(2).(domain.RecordKeyParams)
+	}
+
+	if rf, ok := ret.Get(3).(func(string) error); ok {
+		r3 = rf(newPass)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
+
+// ResolveConflict provides a mock function with given fields: id, resolution
+func (_m *storage) ResolveConflict(id string, resolution domain.Resolution) error {
+	ret := _m.Called(id, resolution)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, domain.Resolution) error); ok {
+		r0 = rf(id, resolution)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreBackup provides a mock function with given fields: name
+func (_m *storage) RestoreBackup(name string) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
 	return r0
 }
 
-// SaveUserData provides a mock function with given fields: user, token
-func (_m *storage) SaveUserData(user domain.User, token string) error {
-	ret := _m.Called(user, token)
+// RestoreRecords provides a mock function with given fields: snapshot, current
+func (_m *storage) RestoreRecords(snapshot []domain.Record, current []domain.Record) (int, error) {
+	ret := _m.Called(snapshot, current)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]domain.Record, []domain.Record) (int, error)); ok {
+		return rf(snapshot, current)
+	}
+	if rf, ok := ret.Get(0).(func([]domain.Record, []domain.Record) int); ok {
+		r0 = rf(snapshot, current)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func([]domain.Record, []domain.Record) error); ok {
+		r1 = rf(snapshot, current)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SavePasswordProfile provides a mock function with given fields: name, policy
+func (_m *storage) SavePasswordProfile(name string, policy domain.PasswordPolicy) error {
+	ret := _m.Called(name, policy)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, domain.PasswordPolicy) error); ok {
+		r0 = rf(name, policy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveTokens provides a mock function with given fields: tokens
+func (_m *storage) SaveTokens(tokens domain.Tokens) error {
+	ret := _m.Called(tokens)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.Tokens) error); ok {
+		r0 = rf(tokens)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveUserData provides a mock function with given fields: user, tokens
+func (_m *storage) SaveUserData(user domain.User, tokens domain.Tokens) error {
+	ret := _m.Called(user, tokens)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.User, string) error); ok {
-		r0 = rf(user, token)
+	if rf, ok := ret.Get(0).(func(domain.User, domain.Tokens) error); ok {
+		r0 = rf(user, tokens)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -202,6 +903,104 @@ func (_m *storage) SetData(data []byte) error {
 	return r0
 }
 
+// SetRecordSalt provides a mock function with given fields: salt
+func (_m *storage) SetRecordSalt(salt []byte) error {
+	ret := _m.Called(salt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(salt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetSyncRevision provides a mock function with given fields: revision
+func (_m *storage) SetSyncRevision(revision int64) error {
+	ret := _m.Called(revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateBinaryData provides a mock function with given fields: _a0
+func (_m *storage) UpdateBinaryData(_a0 domain.BinaryData) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.BinaryData) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateCardData provides a mock function with given fields: _a0
+func (_m *storage) UpdateCardData(_a0 domain.CardData) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.CardData) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateLoginPassword provides a mock function with given fields: _a0
+func (_m *storage) UpdateLoginPassword(_a0 domain.LoginPassword) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.LoginPassword) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOTP provides a mock function with given fields: _a0
+func (_m *storage) UpdateOTP(_a0 domain.OTPSecret) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.OTPSecret) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateTextData provides a mock function with given fields: _a0
+func (_m *storage) UpdateTextData(_a0 domain.TextData) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.TextData) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateTime provides a mock function with given fields:
 func (_m *storage) UpdateTime() error {
 	ret := _m.Called()
@@ -216,6 +1015,48 @@ func (_m *storage) UpdateTime() error {
 	return r0
 }
 
+// WriteBinaryContent provides a mock function with given fields: r
+func (_m *storage) WriteBinaryContent(r io.Reader) ([]string, []byte, int64, error) {
+	ret := _m.Called(r)
+
+	var r0 []string
+	var r1 []byte
+	var r2 int64
+	var r3 error
+	if rf, ok := ret.Get(0).(func(io.Reader) ([]string, []byte, int64, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(io.Reader) []string); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(io.Reader) []byte); ok {
+		r1 = rf(r)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(io.Reader) int64); ok {
+		r2 = rf(r)
+	} else {
+		r2 = ret.Get(2).(int64)
+	}
+
+	if rf, ok := ret.Get(3).(func(io.Reader) error); ok {
+		r3 = rf(r)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
+
 type mockConstructorTestingTnewStorage interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"errors"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrConflictsPending  = errors.New("resolve conflicts before changing master password")
+	ErrMasterPassChanged = errors.New("master password was changed on another device: run sync in terminal to enter new one")
+)
+
+// ChangeMasterPassword проверяет текущий мастер-пароль и перешифровывает хранилище новым.
+// Для хранилища с аккаунтом записи сначала синхронизируются, затем все записи и содержимое binary,
+// зашифрованные новыми ключами, одним запросом заменяют записи на сервере. Если за это время хранилище
+// изменилось на другом устройстве, сервер отклоняет запрос, и локальное хранилище остается со старым паролем
+func (u *usecase) ChangeMasterPassword(oldPass string, newPass string) error {
+	if err := u.storage.CheckMasterPass(oldPass); err != nil {
+		return err
+	}
+	if u.storage.GetTokens().Refresh == "" {
+		return u.storage.ChangeMasterPass(newPass)
+	}
+	if err := u.SyncData(); err != nil {
+		return err
+	}
+	// ревизия после собственной отправки: Rekey принимается только от полностью синхронизированного клиента
+	if err := u.pull(); err != nil {
+		return err
+	}
+	conflicts, err := u.storage.GetConflicts()
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrConflictsPending
+	}
+	records, chunks, params, err := u.storage.RekeyRecords(newPass)
+	if err != nil {
+		return err
+	}
+	if len(chunks) > 0 {
+		if err = u.network.UploadChunks(chunks, u.storage.GetChunk); err != nil {
+			return err
+		}
+	}
+	accepted, revision, err := u.network.Rekey(u.storage.GetSyncRevision(), records, params)
+	if err != nil {
+		return err
+	}
+	revisions := make(map[string]int64, len(accepted))
+	for _, r := range accepted {
+		revisions[r.ID] = r.Revision
+	}
+	for i := range records {
+		records[i].Revision = revisions[records[i].ID]
+	}
+	u.logger.Debug("vault rekeyed", zap.Int("records", len(records)), zap.Int64("revision", revision))
+	return u.storage.CommitRekey(newPass, params.Salt, records, revision)
+}
+
+// AdoptMasterPassword переводит хранилище на мастер-пароль, смененный на другом устройстве, и синхронизирует его
+func (u *usecase) AdoptMasterPassword(newPass string) error {
+	params, err := u.network.GetKeyCheck()
+	if err != nil {
+		return err
+	}
+	if err = u.storage.AdoptMasterPass(newPass, params); err != nil {
+		return err
+	}
+	return u.SyncData()
+}
+
+// checkRecordKey возвращает ErrMasterPassChanged, если мастер-пароль сменили на другом устройстве
+func (u *usecase) checkRecordKey() error {
+	params, err := u.network.GetKeyCheck()
+	if err != nil || len(params.KeyCheck) == 0 {
+		return err
+	}
+	ok, err := u.storage.CheckRecordKey(params.KeyCheck)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMasterPassChanged
+	}
+	return nil
+}
+
+// saveRecordSalt сохраняет соль ключа записей аккаунта после входа. Записи шифруются только после этого
+func (u *usecase) saveRecordSalt() error {
+	params, err := u.network.GetKeyCheck()
+	if err != nil {
+		return err
+	}
+	return u.storage.SetRecordSalt(params.Salt)
+}
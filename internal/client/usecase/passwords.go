@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/pkg/passgen"
+)
+
+// DefaultProfile профиль генерации, используемый, если профиль не указан
+const DefaultProfile = "default"
+
+var ErrBuiltinProfile = errors.New("built-in profile cannot be deleted")
+
+// builtinProfiles встроенные профили. Сохраненный в хранилище профиль с тем же именем их переопределяет
+var builtinProfiles = map[string]domain.PasswordPolicy{
+	DefaultProfile: {Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true, ExcludeAmbiguous: true},
+	"pin":          {Length: 6, Digits: true},
+	"passphrase":   {Words: 6, Separator: passgen.DefaultSeparator},
+}
+
+// GeneratePassword генерирует пароль по политике
+func (u *usecase) GeneratePassword(policy domain.PasswordPolicy) (string, error) {
+	return GeneratePassword(policy)
+}
+
+// GeneratePassword генерирует пароль по политике без открытия хранилища
+func GeneratePassword(policy domain.PasswordPolicy) (string, error) {
+	return passgen.Generate(passgenPolicy(policy))
+}
+
+// BuiltinProfile возвращает встроенный профиль генерации по имени
+func BuiltinProfile(name string) (domain.PasswordPolicy, bool) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := builtinProfiles[name]
+	return p, ok
+}
+
+// GetPasswordProfile возвращает профиль генерации по имени
+func (u *usecase) GetPasswordProfile(name string) (domain.PasswordPolicy, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if p, ok := u.storage.GetPasswordProfiles()[name]; ok {
+		return p, nil
+	}
+	if p, ok := builtinProfiles[name]; ok {
+		return p, nil
+	}
+	return domain.PasswordPolicy{}, fmt.Errorf("password profile %q not found", name)
+}
+
+// ListPasswordProfiles возвращает встроенные и сохраненные профили
+func (u *usecase) ListPasswordProfiles() map[string]domain.PasswordPolicy {
+	profiles := u.storage.GetPasswordProfiles()
+	for name, p := range builtinProfiles {
+		if _, ok := profiles[name]; !ok {
+			profiles[name] = p
+		}
+	}
+	return profiles
+}
+
+// SavePasswordProfile проверяет и сохраняет профиль генерации
+func (u *usecase) SavePasswordProfile(name string, policy domain.PasswordPolicy) error {
+	if name == "" {
+		return errors.New("profile name is empty")
+	}
+	if err := passgenPolicy(policy).Validate(); err != nil {
+		return err
+	}
+	return u.storage.SavePasswordProfile(name, policy)
+}
+
+// DeletePasswordProfile удаляет сохраненный профиль. Встроенный профиль удалить нельзя,
+// но удаление сохраненного профиля с тем же именем возвращает встроенный
+func (u *usecase) DeletePasswordProfile(name string) error {
+	if _, ok := u.storage.GetPasswordProfiles()[name]; !ok {
+		if _, builtin := builtinProfiles[name]; builtin {
+			return ErrBuiltinProfile
+		}
+	}
+	return u.storage.DeletePasswordProfile(name)
+}
+
+func passgenPolicy(p domain.PasswordPolicy) passgen.Policy {
+	return passgen.Policy{
+		Length:           p.Length,
+		Lower:            p.Lower,
+		Upper:            p.Upper,
+		Digits:           p.Digits,
+		Symbols:          p.Symbols,
+		ExcludeAmbiguous: p.ExcludeAmbiguous,
+		Words:            p.Words,
+		Separator:        p.Separator,
+	}
+}
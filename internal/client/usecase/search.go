@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nameFields поля, по которым свободные слова запроса ищутся нечетко
+var nameFields = []string{"title", "login", "issuer", "account", "holder", "url"}
+
+// textFields поля, по которым свободные слова ищутся как подстрока
+var textFields = []string{"meta", "text", "fields"}
+
+// searchFields поля, доступные в запросе как field:value
+var searchFields = map[string]bool{
+	"type": true, "tag": true, "title": true, "login": true, "issuer": true, "account": true,
+	"holder": true, "url": true, "meta": true, "text": true, "folder": true,
+}
+
+// query разобранный поисковый запрос
+type query struct {
+	terms   []string
+	types   []string
+	filters map[string][]string
+}
+
+// indexed секрет с полями для поиска
+type indexed struct {
+	result domain.SearchResult
+	fields map[string]string
+}
+
+// Search ищет секреты по запросу. Слова вида field:value (login:, meta:, type:card, tag:, folder: ...) фильтруют
+// по полю, остальные ищутся нечетко по названиям и URL и как подстрока в заметках, тексте и полях.
+// Все условия должны выполняться, несколько type: объединяются по "или".
+// Пустой запрос возвращает все секреты
+func (u *usecase) Search(q string) []domain.SearchResult {
+	parsed := parseQuery(q)
+	var results []domain.SearchResult
+	for _, rec := range u.index() {
+		if score, ok := parsed.match(rec); ok {
+			rec.result.Score = score
+			results = append(results, rec.result)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Favorite != results[j].Favorite {
+			return results[i].Favorite
+		}
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// index собирает несекретные поля всех записей
+func (u *usecase) index() []indexed {
+	var recs []indexed
+	add := func(r domain.SearchResult, m domain.Metadata, fields map[string]string) {
+		if m.Title != "" {
+			if r.Title != "" {
+				r.Details = strings.TrimSuffix(r.Title+" - "+r.Details, " - ")
+			}
+			r.Title = m.Title
+		}
+		r.Tags = m.Tags
+		r.Folder = m.Folder
+		r.Favorite = m.Favorite
+		fields["title"] = r.Title
+		fields["url"] = strings.Join(m.URLs, " ")
+		fields["folder"] = m.Folder
+		var values []string
+		for _, f := range m.Fields {
+			if f.Type != domain.FieldHidden {
+				values = append(values, f.Value)
+			}
+		}
+		fields["fields"] = strings.Join(values, " ")
+		recs = append(recs, indexed{result: r, fields: fields})
+	}
+	for _, lp := range u.storage.GetLogins() {
+		add(domain.SearchResult{Type: "login", Key: lp.Key, ID: lp.ID, Title: lp.Login, Details: lp.Meta}, lp.Metadata,
+			map[string]string{"login": lp.Login, "meta": lp.Meta})
+	}
+	for _, td := range u.storage.GetTextData() {
+		add(domain.SearchResult{Type: "text", Key: td.Key, ID: td.ID, Title: td.Meta}, td.Metadata,
+			map[string]string{"text": td.Text, "meta": td.Meta})
+	}
+	for _, card := range u.storage.GetCardsData() {
+		add(domain.SearchResult{Type: "card", Key: card.Key, ID: card.ID,
+			Title: strings.TrimSpace(card.CardHolder + " " + maskCard(card.Number)), Details: card.Meta}, card.Metadata,
+			map[string]string{"holder": card.CardHolder, "meta": card.Meta})
+	}
+	for _, bd := range u.storage.GetBinaryData() {
+		add(domain.SearchResult{Type: "binary", Key: bd.Key, ID: bd.ID, Title: bd.Meta,
+			Details: fmt.Sprintf("%d bytes", binarySize(bd))}, bd.Metadata,
+			map[string]string{"meta": bd.Meta})
+	}
+	for _, otp := range u.storage.GetOTPs() {
+		title := otp.Account
+		if otp.Issuer != "" {
+			title = otp.Issuer + ":" + otp.Account
+		}
+		add(domain.SearchResult{Type: "otp", Key: otp.Key, ID: otp.ID, Title: title, Details: otp.Meta}, otp.Metadata,
+			map[string]string{"issuer": otp.Issuer, "account": otp.Account, "meta": otp.Meta})
+	}
+	return recs
+}
+
+// parseQuery разбирает запрос на слова и фильтры. Значения в двойных кавычках могут содержать пробелы
+func parseQuery(q string) query {
+	parsed := query{filters: make(map[string][]string)}
+	for _, token := range splitQuery(q) {
+		field, value, ok := strings.Cut(token, ":")
+		field = strings.ToLower(field)
+		if !ok || !searchFields[field] || value == "" {
+			parsed.terms = append(parsed.terms, strings.ToLower(token))
+			continue
+		}
+		value = strings.ToLower(value)
+		if field == "type" {
+			parsed.types = append(parsed.types, value)
+			continue
+		}
+		parsed.filters[field] = append(parsed.filters[field], value)
+	}
+	return parsed
+}
+
+func splitQuery(q string) []string {
+	var tokens []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+// match проверяет запись и возвращает ее релевантность
+func (q query) match(rec indexed) (int, bool) {
+	if len(q.types) > 0 {
+		found := false
+		for _, t := range q.types {
+			found = found || t == rec.result.Type
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	score := 0
+	for field, values := range q.filters {
+		for _, value := range values {
+			if field == "tag" {
+				if !hasTag(rec.result.Tags, value) {
+					return 0, false
+				}
+				score += 10
+				continue
+			}
+			if field == "folder" {
+				// папка совпадает вместе с вложенными
+				folder := strings.ToLower(rec.result.Folder)
+				if folder != value && !strings.HasPrefix(folder, strings.TrimSuffix(value, "/")+"/") {
+					return 0, false
+				}
+				score += 10
+				continue
+			}
+			if !strings.Contains(strings.ToLower(rec.fields[field]), value) {
+				return 0, false
+			}
+			score += 10
+		}
+	}
+	for _, term := range q.terms {
+		best := 0
+		for _, f := range nameFields {
+			if s := fuzzyScore(rec.fields[f], term); s > best {
+				best = s
+			}
+		}
+		for _, f := range textFields {
+			if best == 0 && strings.Contains(strings.ToLower(rec.fields[f]), term) {
+				best = 1
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyScore нечеткое совпадение pattern с text: символы pattern должны идти в text по порядку.
+// Подстрока и совпадения в начале слов ценятся выше. 0 - нет совпадения
+func fuzzyScore(text, pattern string) int {
+	text = strings.ToLower(text)
+	if pattern == "" || text == "" {
+		return 0
+	}
+	if i := strings.Index(text, pattern); i >= 0 {
+		score := 100 + len(pattern)*2
+		if r, _ := utf8.DecodeLastRuneInString(text[:i]); i == 0 || !isWordRune(r) {
+			score += 20
+		}
+		return score
+	}
+	tr := []rune(text)
+	score, prev := 0, -2
+	ti := 0
+	for _, pr := range pattern {
+		for ti < len(tr) && tr[ti] != pr {
+			ti++
+		}
+		if ti == len(tr) {
+			return 0
+		}
+		score++
+		if ti == prev+1 {
+			score += 2
+		}
+		if ti == 0 || !isWordRune(tr[ti-1]) {
+			score += 3
+		}
+		prev = ti
+		ti++
+	}
+	return score
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// maskCard оставляет последние 4 цифры номера карты
+func maskCard(number string) string {
+	if len(number) <= 4 {
+		return ""
+	}
+	return "•••• " + number[len(number)-4:]
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// searchStorage хранилище с фиксированным набором секретов. Остальные методы не вызываются
+type searchStorage struct {
+	storage
+}
+
+func (s searchStorage) GetLogins() []domain.LoginPassword {
+	return []domain.LoginPassword{
+		{Key: 1, Login: "alice@github.com", Password: "hunter2", Meta: "work", Metadata: domain.Metadata{
+			Tags: []string{"Work", "dev"}, Folder: "work/code", URLs: []string{"https://github.com/login"}}},
+		{Key: 2, Login: "bob@gitlab.com", Password: "github", Meta: "home"},
+	}
+}
+
+func (s searchStorage) GetTextData() []domain.TextData {
+	return []domain.TextData{{Key: 1, Text: "wifi password is 12345", Meta: "home wifi", Metadata: domain.Metadata{
+		Title: "Router", Favorite: true, Folder: "network"}}}
+}
+
+func (s searchStorage) GetCardsData() []domain.CardData {
+	return []domain.CardData{{Key: 1, Number: "4111111111111111", CardHolder: "ALICE SMITH", CVC: "123"}}
+}
+
+func (s searchStorage) GetBinaryData() []domain.BinaryData {
+	return nil
+}
+
+func (s searchStorage) GetOTPs() []domain.OTPSecret {
+	return []domain.OTPSecret{{Key: 1, Issuer: "GitHub", Account: "alice", Secret: "JBSWY3DPEHPK3PXP"}}
+}
+
+func keys(results []domain.SearchResult) []string {
+	var k []string
+	for _, r := range results {
+		k = append(k, r.Type+":"+r.Title)
+	}
+	return k
+}
+
+func TestSearch(t *testing.T) {
+	u := &usecase{storage: searchStorage{}}
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"substring ranks above fuzzy", "github", []string{"login:alice@github.com", "otp:GitHub:alice"}},
+		{"fuzzy", "ghub", []string{"login:alice@github.com", "otp:GitHub:alice"}},
+		{"type filter", "type:otp alice", []string{"otp:GitHub:alice"}},
+		{"several types", "type:otp type:card alice", []string{"card:ALICE SMITH •••• 1111", "otp:GitHub:alice"}},
+		{"field filter", "meta:home", []string{"text:Router", "login:bob@gitlab.com"}},
+		{"quoted value", `meta:"home wifi"`, []string{"text:Router"}},
+		{"text content", "12345", []string{"text:Router"}},
+		{"secrets are not searched", "hunter2", nil},
+		{"tag", "tag:work", []string{"login:alice@github.com"}},
+		{"folder with subfolders", "folder:work", []string{"login:alice@github.com"}},
+		{"url", "url:github.com/login", []string{"login:alice@github.com"}},
+		{"no match", "login:carol", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, keys(u.Search(tt.query)))
+		})
+	}
+	require.Len(t, u.Search(""), 5)
+}
+
+func TestFuzzyScore(t *testing.T) {
+	require.Zero(t, fuzzyScore("github", "hg"))
+	require.Greater(t, fuzzyScore("github", "git"), fuzzyScore("github", "gth"))
+	require.Greater(t, fuzzyScore("my github", "gh"), fuzzyScore("mygithub", "gh"))
+}
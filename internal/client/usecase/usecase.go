@@ -1,18 +1,41 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/pkg/totp"
 	"go.uber.org/zap"
+	"io"
 	"time"
 )
 
+var (
+	ErrUnknownType  = errors.New("unknown secret type")
+	ErrUnknownField = errors.New("unknown field")
+)
+
 type network interface {
-	RegisterUser(user domain.User) (string, error)
-	LoginUser(user domain.User) (string, error)
+	RegisterUser(user domain.User) (domain.Tokens, error)
+	LoginUser(user domain.User) (domain.Tokens, error)
+	Logout() error
+	ChangePassword(password string, newPassword string) (domain.Tokens, error)
+	DeleteAccount(password string) error
+	RegisterDevice(name string) (domain.Tokens, error)
+	ListDevices() ([]domain.Device, error)
+	RevokeDevice(id string) error
 	CheckSync(email string) (time.Time, error)
 	GetData() ([]byte, error)
 	SendData(data []byte) error
+	PushRecords(records []domain.Record) (accepted []domain.Record, conflicts []domain.Record, err error)
+	PullRecords(since int64) ([]domain.Record, int64, error)
+	MissingChunks(ids []string) ([]string, error)
+	UploadChunks(ids []string, read func(id string) ([]byte, error)) error
+	DownloadChunks(ids []string, write func(id string, data []byte) error) error
+	ListVersions() ([]domain.VaultVersion, error)
+	GetVersion(number int64) (domain.VaultVersion, []domain.Record, error)
+	Rekey(revision int64, records []domain.Record, params domain.RecordKeyParams) (accepted []domain.Record, newRevision int64, err error)
+	GetKeyCheck() (domain.RecordKeyParams, error)
 }
 
 //go:generate mockery --name "storage"
@@ -25,11 +48,58 @@ type storage interface {
 	AddTextData(domain.TextData) error
 	AddBinaryData(domain.BinaryData) error
 	AddCardData(domain.CardData) error
-	SaveUserData(user domain.User, token string) error
+	UpdateLoginPassword(domain.LoginPassword) error
+	UpdateTextData(domain.TextData) error
+	UpdateBinaryData(domain.BinaryData) error
+	UpdateCardData(domain.CardData) error
+	DeleteLoginPassword(key int) error
+	DeleteTextData(key int) error
+	DeleteBinaryData(key int) error
+	DeleteCardData(key int) error
+	GetLoginPassword(key int) (domain.LoginPassword, error)
+	GetText(key int) (domain.TextData, error)
+	GetBinary(key int) (domain.BinaryData, error)
+	GetCard(key int) (domain.CardData, error)
+	WriteBinaryContent(r io.Reader) (chunks []string, key []byte, size int64, err error)
+	ReadBinaryContent(bd domain.BinaryData, w io.Writer) error
+	GetChunk(id string) ([]byte, error)
+	PutChunk(id string, data []byte) error
+	MissingChunks(ids []string) []string
+	ChunkRefs() []string
+	GetOTPs() []domain.OTPSecret
+	AddOTP(domain.OTPSecret) error
+	UpdateOTP(domain.OTPSecret) error
+	DeleteOTP(key int) error
+	GetOTP(key int) (domain.OTPSecret, error)
+	GetPasswordProfiles() map[string]domain.PasswordPolicy
+	SavePasswordProfile(name string, policy domain.PasswordPolicy) error
+	DeletePasswordProfile(name string) error
+	SaveUserData(user domain.User, tokens domain.Tokens) error
+	SaveTokens(tokens domain.Tokens) error
 	UpdateTime() error
 	GetData() ([]byte, error)
 	SetData(data []byte) error
 	GetLocalSyncTime() time.Time
+	GetChangedRecords() ([]domain.Record, error)
+	ApplyRecords(records []domain.Record) error
+	RestoreRecords(snapshot []domain.Record, current []domain.Record) (changed int, err error)
+	ListBackups() ([]domain.Backup, error)
+	RestoreBackup(name string) error
+	GetTokens() domain.Tokens
+	GetEmail() string
+	ForgetAccount() error
+	CheckMasterPass(pass string) error
+	CheckRecordKey(keyCheck []byte) (bool, error)
+	RekeyRecords(newPass string) (records []domain.Record, chunks []string, params domain.RecordKeyParams, err error)
+	CommitRekey(newPass string, salt []byte, records []domain.Record, revision int64) error
+	ChangeMasterPass(newPass string) error
+	AdoptMasterPass(newPass string, params domain.RecordKeyParams) error
+	SetRecordSalt(salt []byte) error
+	MarkRecordsSynced(records []domain.Record) error
+	GetSyncRevision() int64
+	SetSyncRevision(revision int64) error
+	GetConflicts() ([]domain.Conflict, error)
+	ResolveConflict(id string, resolution domain.Resolution) error
 }
 
 type usecase struct {
@@ -72,13 +142,43 @@ func (u *usecase) ListSecrets() []string {
 	}
 	result = append(result, "Binary:")
 	for _, b := range u.storage.GetBinaryData() {
-		result = append(result, fmt.Sprintf("Key[%d],%s,%b", b.Key, b.Meta, b.BinaryData[:20]))
+		result = append(result, fmt.Sprintf("Key[%d],%s,%s,%d bytes,%s", b.Key, b.Meta, b.FileName, binarySize(b), b.MIMEType))
+	}
+	result = append(result, "OTP:")
+	for _, otp := range u.storage.GetOTPs() {
+		result = append(result, fmt.Sprintf("Key[%d],%s:%s", otp.Key, otp.Issuer, otp.Account))
 	}
 	return result
 }
 
+// GetLogins возвращает логины-пароли, отсортированные по ключу
+func (u *usecase) GetLogins() []domain.LoginPassword {
+	return u.storage.GetLogins()
+}
+
+func (u *usecase) GetTextData() []domain.TextData {
+	return u.storage.GetTextData()
+}
+
+// GetBinaryData возвращает binary с размером содержимого в Size, в том числе для записей старого формата
+func (u *usecase) GetBinaryData() []domain.BinaryData {
+	bds := u.storage.GetBinaryData()
+	for i := range bds {
+		bds[i].Size = binarySize(bds[i])
+	}
+	return bds
+}
+
+func (u *usecase) GetCardsData() []domain.CardData {
+	return u.storage.GetCardsData()
+}
+
 func (u *usecase) AddLoginPassword(lp domain.LoginPassword) error {
-	err := u.storage.AddLoginPassword(lp)
+	var err error
+	if lp.Metadata, err = normalizeMetadata(lp.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.AddLoginPassword(lp)
 	if err != nil {
 		return err
 	}
@@ -87,7 +187,11 @@ func (u *usecase) AddLoginPassword(lp domain.LoginPassword) error {
 }
 
 func (u *usecase) AddTextData(td domain.TextData) error {
-	err := u.storage.AddTextData(td)
+	var err error
+	if td.Metadata, err = normalizeMetadata(td.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.AddTextData(td)
 	if err != nil {
 		return err
 	}
@@ -96,7 +200,14 @@ func (u *usecase) AddTextData(td domain.TextData) error {
 }
 
 func (u *usecase) AddBinaryData(bd domain.BinaryData) error {
-	err := u.storage.AddBinaryData(bd)
+	var err error
+	if bd.Metadata, err = normalizeMetadata(bd.Metadata); err != nil {
+		return err
+	}
+	if err = u.chunkInline(&bd); err != nil {
+		return err
+	}
+	err = u.storage.AddBinaryData(bd)
 	if err != nil {
 		return err
 	}
@@ -105,7 +216,89 @@ func (u *usecase) AddBinaryData(bd domain.BinaryData) error {
 }
 
 func (u *usecase) AddCardData(card domain.CardData) error {
-	err := u.storage.AddCardData(card)
+	var err error
+	if card.Metadata, err = normalizeMetadata(card.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.AddCardData(card)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) GetLoginPassword(key int) (domain.LoginPassword, error) {
+	return u.storage.GetLoginPassword(key)
+}
+
+func (u *usecase) UpdateLoginPassword(lp domain.LoginPassword) error {
+	var err error
+	if lp.Metadata, err = normalizeMetadata(lp.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.UpdateLoginPassword(lp)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) DeleteLoginPassword(key int) error {
+	err := u.storage.DeleteLoginPassword(key)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) GetText(key int) (domain.TextData, error) {
+	return u.storage.GetText(key)
+}
+
+func (u *usecase) UpdateTextData(td domain.TextData) error {
+	var err error
+	if td.Metadata, err = normalizeMetadata(td.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.UpdateTextData(td)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) DeleteTextData(key int) error {
+	err := u.storage.DeleteTextData(key)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+// GetBinary возвращает binary по ключу с размером содержимого в Size, в том числе для записи старого формата
+func (u *usecase) GetBinary(key int) (domain.BinaryData, error) {
+	bd, err := u.storage.GetBinary(key)
+	if err != nil {
+		return domain.BinaryData{}, err
+	}
+	bd.Size = binarySize(bd)
+	return bd, nil
+}
+
+func (u *usecase) UpdateBinaryData(bd domain.BinaryData) error {
+	var err error
+	if bd.Metadata, err = normalizeMetadata(bd.Metadata); err != nil {
+		return err
+	}
+	if err = u.chunkInline(&bd); err != nil {
+		return err
+	}
+	err = u.storage.UpdateBinaryData(bd)
 	if err != nil {
 		return err
 	}
@@ -113,18 +306,217 @@ func (u *usecase) AddCardData(card domain.CardData) error {
 	return u.storage.UpdateTime()
 }
 
+func (u *usecase) DeleteBinaryData(key int) error {
+	err := u.storage.DeleteBinaryData(key)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) GetCard(key int) (domain.CardData, error) {
+	return u.storage.GetCard(key)
+}
+
+func (u *usecase) UpdateCardData(card domain.CardData) error {
+	var err error
+	if card.Metadata, err = normalizeMetadata(card.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.UpdateCardData(card)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) DeleteCardData(key int) error {
+	err := u.storage.DeleteCardData(key)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) GetOTPs() []domain.OTPSecret {
+	return u.storage.GetOTPs()
+}
+
+// AddOTP проверяет параметры TOTP и добавляет секрет. Незаданные параметры получают значения по умолчанию
+func (u *usecase) AddOTP(otp domain.OTPSecret) error {
+	otp = withOTPDefaults(otp)
+	if err := otpKey(otp).Validate(); err != nil {
+		return err
+	}
+	var err error
+	if otp.Metadata, err = normalizeMetadata(otp.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.AddOTP(otp)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) GetOTP(key int) (domain.OTPSecret, error) {
+	return u.storage.GetOTP(key)
+}
+
+func (u *usecase) UpdateOTP(otp domain.OTPSecret) error {
+	otp = withOTPDefaults(otp)
+	if err := otpKey(otp).Validate(); err != nil {
+		return err
+	}
+	var err error
+	if otp.Metadata, err = normalizeMetadata(otp.Metadata); err != nil {
+		return err
+	}
+	err = u.storage.UpdateOTP(otp)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+func (u *usecase) DeleteOTP(key int) error {
+	err := u.storage.DeleteOTP(key)
+	if err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
+	return u.storage.UpdateTime()
+}
+
+// GetOTPCode возвращает текущий код TOTP и время до его смены
+func (u *usecase) GetOTPCode(key int) (string, time.Duration, error) {
+	otp, err := u.storage.GetOTP(key)
+	if err != nil {
+		return "", 0, err
+	}
+	return otpKey(otp).Code(time.Now())
+}
+
+// ParseOTPURI разбирает otpauth:// URI в секрет TOTP
+func (u *usecase) ParseOTPURI(uri string) (domain.OTPSecret, error) {
+	k, err := totp.ParseURI(uri)
+	if err != nil {
+		return domain.OTPSecret{}, err
+	}
+	return domain.OTPSecret{
+		Issuer:    k.Issuer,
+		Account:   k.Account,
+		Secret:    k.Secret,
+		Algorithm: k.Algorithm,
+		Digits:    k.Digits,
+		Period:    k.Period,
+	}, nil
+}
+
+func otpKey(otp domain.OTPSecret) totp.Key {
+	return totp.Key{
+		Issuer:    otp.Issuer,
+		Account:   otp.Account,
+		Secret:    otp.Secret,
+		Algorithm: otp.Algorithm,
+		Digits:    otp.Digits,
+		Period:    otp.Period,
+	}
+}
+
+func withOTPDefaults(otp domain.OTPSecret) domain.OTPSecret {
+	if otp.Algorithm == "" {
+		otp.Algorithm = totp.DefaultAlgorithm
+	}
+	if otp.Digits == 0 {
+		otp.Digits = totp.DefaultDigits
+	}
+	if otp.Period == 0 {
+		otp.Period = totp.DefaultPeriod
+	}
+	return otp
+}
+
+// GetSecretField возвращает значение поля секрета для копирования. Пустое field - основное поле типа:
+// пароль логина, номер карты, текст, текущий код TOTP
+func (u *usecase) GetSecretField(secretType string, key int, field string) (string, error) {
+	switch secretType {
+	case "login":
+		lp, err := u.storage.GetLoginPassword(key)
+		if err != nil {
+			return "", err
+		}
+		switch field {
+		case "", "password":
+			return lp.Password, nil
+		case "login":
+			return lp.Login, nil
+		}
+	case "card":
+		card, err := u.storage.GetCard(key)
+		if err != nil {
+			return "", err
+		}
+		switch field {
+		case "", "number":
+			return card.Number, nil
+		case "cvc":
+			return card.CVC, nil
+		case "holder":
+			return card.CardHolder, nil
+		}
+	case "text":
+		td, err := u.storage.GetText(key)
+		if err != nil {
+			return "", err
+		}
+		if field == "" || field == "text" {
+			return td.Text, nil
+		}
+	case "otp":
+		switch field {
+		case "", "code":
+			code, _, err := u.GetOTPCode(key)
+			return code, err
+		case "secret":
+			otp, err := u.storage.GetOTP(key)
+			return otp.Secret, err
+		}
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownType, secretType)
+	}
+	return "", fmt.Errorf("%w: %s has no field %s", ErrUnknownField, secretType, field)
+}
+
 func (u *usecase) RegisterUser(user domain.User) error {
-	token, err := u.network.RegisterUser(user)
+	_, err := u.network.RegisterUser(user)
+	if err != nil {
+		return err
+	}
+	tokens, err := u.network.RegisterDevice(user.Device)
 	if err != nil {
 		return err
 	}
-	err = u.storage.SaveUserData(user, token)
+	if err = u.saveRecordSalt(); err != nil {
+		return err
+	}
+	err = u.storage.SaveUserData(user, tokens)
 	u.email = user.Email
 	return err
 }
 
 func (u *usecase) LoginUser(user domain.User) error {
-	token, err := u.network.LoginUser(user)
+	_, err := u.network.LoginUser(user)
+	if err != nil {
+		u.logger.Debug(err.Error())
+		return err
+	}
+	tokens, err := u.network.RegisterDevice(user.Device)
 	if err != nil {
 		u.logger.Debug(err.Error())
 		return err
@@ -135,11 +527,37 @@ func (u *usecase) LoginUser(user domain.User) error {
 		return err
 	}
 	u.serverSyncTime = tSync
-	err = u.storage.SaveUserData(user, token)
+	if err = u.saveRecordSalt(); err != nil {
+		return err
+	}
+	err = u.storage.SaveUserData(user, tokens)
 	u.email = user.Email
 	return err
 }
 
+// ListDevices возвращает устройства пользователя
+func (u *usecase) ListDevices() ([]domain.Device, error) {
+	return u.network.ListDevices()
+}
+
+// RevokeDevice отзывает потерянное устройство: оно больше не сможет синхронизироваться
+func (u *usecase) RevokeDevice(id string) error {
+	return u.network.RevokeDevice(id)
+}
+
+// Logout отзывает refresh token на сервере и удаляет токены из хранилища
+func (u *usecase) Logout() error {
+	err := u.network.Logout()
+	if err != nil {
+		u.logger.Debug(err.Error())
+	}
+	errSave := u.storage.SaveTokens(domain.Tokens{})
+	if errSave != nil {
+		return errSave
+	}
+	return err
+}
+
 func (u *usecase) CheckSync() (time.Time, error) {
 	t, err := u.network.CheckSync(u.email)
 	if err != nil {
@@ -153,39 +571,77 @@ func (u *usecase) GetLocalSyncTime() time.Time {
 	return u.storage.GetLocalSyncTime()
 }
 
-// SyncData сравнивает время обновления на сервере и локально. Синхронизирует файлы секретов на сервере и локально
+// maxPushAttempts ограничивает число повторных отправок, если сервер вернул записи,
+// изменившиеся на других устройствах во время синхронизации
+const maxPushAttempts = 3
+
+// SyncData получает записи, измененные на других устройствах, объединяет их с локальными изменениями
+// и отправляет на сервер локально измененные записи.
+// Записи, измененные с обеих сторон в одних и тех же полях, остаются конфликтами - см. ListConflicts.
 func (u *usecase) SyncData() error {
-	var data []byte
-	var err error
-	if u.serverSyncTime.IsZero() {
-		_, err = u.CheckSync()
+	if err := u.checkRecordKey(); err != nil {
+		return err
+	}
+	if err := u.pull(); err != nil {
+		return err
+	}
+	if err := u.uploadChunks(); err != nil {
+		return err
+	}
+	for i := 0; i < maxPushAttempts; i++ {
+		changed, err := u.storage.GetChangedRecords()
 		if err != nil {
 			return err
 		}
-	}
-	if u.localSyncTime.IsZero() {
-		u.localSyncTime = u.storage.GetLocalSyncTime()
-	}
-
-	if u.serverSyncTime.After(u.localSyncTime) {
-		data, err = u.network.GetData()
+		if len(changed) == 0 {
+			break
+		}
+		accepted, conflicts, err := u.network.PushRecords(changed)
 		if err != nil {
 			return err
 		}
-		u.storage.SetData(data)
-	} else {
-		data, err = u.storage.GetData()
+		err = u.storage.MarkRecordsSynced(accepted)
 		if err != nil {
 			return err
 		}
-		err = u.network.SendData(data)
+		if len(conflicts) == 0 {
+			break
+		}
+		u.logger.Debug("records changed on server during sync", zap.Int("count", len(conflicts)))
+		err = u.storage.ApplyRecords(conflicts)
 		if err != nil {
 			return err
 		}
 	}
+	if err := u.downloadChunks(); err != nil {
+		return err
+	}
+	u.localSyncTime = time.Now()
 	return nil
 }
 
+// pull получает записи, измененные на сервере после последней полученной ревизии, и применяет их
+func (u *usecase) pull() error {
+	records, revision, err := u.network.PullRecords(u.storage.GetSyncRevision())
+	if err != nil {
+		return err
+	}
+	if err = u.storage.ApplyRecords(records); err != nil {
+		return err
+	}
+	return u.storage.SetSyncRevision(revision)
+}
+
+// ListConflicts возвращает записи, требующие решения пользователя после синхронизации
+func (u *usecase) ListConflicts() ([]domain.Conflict, error) {
+	return u.storage.GetConflicts()
+}
+
+// ResolveConflict разрешает конфликт выбранным способом. Результат отправится на сервер при следующей синхронизации
+func (u *usecase) ResolveConflict(id string, resolution domain.Resolution) error {
+	return u.storage.ResolveConflict(id, resolution)
+}
+
 func (u *usecase) GetVersion() string {
 	return u.version
 }
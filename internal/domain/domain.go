@@ -1,33 +1,233 @@
 package domain
 
+import "time"
+
+// FieldType тип пользовательского поля
+type FieldType string
+
+const (
+	FieldText    FieldType = "text"
+	FieldHidden  FieldType = "hidden"
+	FieldBoolean FieldType = "boolean"
+)
+
+// CustomField произвольное поле записи. Значение boolean - "true" или "false"
+type CustomField struct {
+	Name  string
+	Type  FieldType
+	Value string
+}
+
+// Metadata общие метаданные записей всех типов. Meta остается свободной заметкой
+type Metadata struct {
+	Title string
+	URLs  []string
+	Tags  []string
+	// Folder путь папки через "/", например "work/servers"
+	Folder    string
+	Favorite  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Fields    []CustomField
+}
+
 type LoginPassword struct {
 	Key      int
+	ID       string
 	Login    string
 	Password string
 	Meta     string
+	Metadata
 }
 
 type TextData struct {
 	Key  int
+	ID   string
 	Text string
 	Meta string
+	Metadata
 }
 
+// BinaryData файл. Содержимое хранится отдельно от записи зашифрованными чанками Chunks;
+// BinaryData заполнено у записей, созданных до хранения чанками, и в экспорте
 type BinaryData struct {
 	Key        int
+	ID         string
 	BinaryData []byte
 	Meta       string
+	// Chunks id чанков содержимого по порядку
+	Chunks []string
+	// ChunkKey ключ шифрования чанков этой записи
+	ChunkKey []byte
+	// Size размер содержимого в байтах
+	Size int64
+	// FileName исходное имя файла
+	FileName string
+	// MIMEType тип содержимого по расширению или сигнатуре файла
+	MIMEType string
+	// SHA256 hex sha256 открытого содержимого, проверяется при извлечении
+	SHA256 string
+	Metadata
 }
 
 type CardData struct {
 	Key        int
+	ID         string
 	Number     string
 	CardHolder string
 	CVC        string
 	Meta       string
+	Metadata
+}
+
+// OTPSecret секрет TOTP (RFC 6238). Secret в base32
+type OTPSecret struct {
+	Key       int
+	ID        string
+	Issuer    string
+	Account   string
+	Secret    string
+	Algorithm string
+	Digits    int
+	Period    int
+	Meta      string
+	Metadata
+}
+
+// SearchResult найденный секрет. Значения паролей, CVC и секретов в результат не попадают
+type SearchResult struct {
+	Type  string
+	Key   int
+	ID    string
+	Title string
+	// Details несекретные поля для вывода: логин, аккаунт, метаданные
+	Details  string
+	Tags     []string
+	Folder   string
+	Favorite bool
+	// Score релевантность, больше - выше в выдаче
+	Score int
+}
+
+// Vault все секреты хранилища, например для экспорта
+type Vault struct {
+	Logins   []LoginPassword
+	Texts    []TextData
+	Cards    []CardData
+	Binaries []BinaryData
+	OTPs     []OTPSecret
+}
+
+// ImportReport результат импорта: созданные записи вида "login: title" и непереносимые поля и записи
+type ImportReport struct {
+	Created []string
+	Dropped []string
+}
+
+// PasswordPolicy политика генерации пароля. При Words > 0 генерируется diceware-фраза
+type PasswordPolicy struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+	Words            int
+	Separator        string
 }
 
 type User struct {
 	Email    string
 	Password string
+	// Device имя устройства, под которым клиент регистрируется после входа
+	Device string
+}
+
+// Record зашифрованная запись секрета для синхронизации с сервером.
+// ID постоянный между устройствами, Revision - ревизия записи на сервере.
+type Record struct {
+	ID       string
+	Revision int64
+	Data     []byte
+	Deleted  bool
+	// Chunks id чанков содержимого binary, на которые ссылается запись
+	Chunks []string
+}
+
+// VaultVersion версия хранилища на сервере - снимок записей после принятой отправки с устройства
+type VaultVersion struct {
+	// Number ревизия сервера, на которой сделан снимок
+	Number     int64
+	CreatedAt  time.Time
+	DeviceID   string
+	DeviceName string
+	// Records количество записей в снимке
+	Records int64
+}
+
+// Backup локальная зашифрованная копия файла хранилища
+type Backup struct {
+	Name      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// RecordKeyParams параметры ключа записей аккаунта, которые хранит сервер
+type RecordKeyParams struct {
+	// Salt случайная соль Argon2id ключа записей. Пустая у аккаунтов, созданных до ее появления:
+	// их ключ получается с солью из email до следующей смены мастер-пароля
+	Salt []byte
+	// KeyCheck значение, зашифрованное ключом записей после смены мастер-пароля, пустое - пароль не менялся
+	KeyCheck []byte
+}
+
+// Conflict запись, измененная одновременно локально и на сервере
+type Conflict struct {
+	ID     string
+	Type   string
+	Local  string
+	Remote string
+}
+
+// Resolution способ разрешения конфликта
+type Resolution string
+
+const (
+	KeepLocal  Resolution = "keep-local"
+	KeepRemote Resolution = "keep-remote"
+	KeepBoth   Resolution = "keep-both"
+)
+
+// Tokens пара токенов пользователя: короткоживущий JWT и одноразовый refresh token
+type Tokens struct {
+	Access  string
+	Refresh string
+}
+
+// RefreshToken refresh token на сервере. Хранится по хешу токена.
+// Family общая для всех токенов, полученных ротацией из одного входа
+type RefreshToken struct {
+	Email     string
+	Family    string
+	DeviceID  string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// Device устройство пользователя, получившее токены
+type Device struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	LastSeen  time.Time
+	Revoked   bool
+	// Current устройство, с которого выполнен запрос
+	Current bool
+}
+
+// LoginAttempts неудачные попытки входа по ключу - email или адресу клиента
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
 }
@@ -20,12 +20,28 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	YaGophKeeper_RegisterUser_FullMethodName = "/yagophkeeper.YaGophKeeper/RegisterUser"
-	YaGophKeeper_LoginUser_FullMethodName    = "/yagophkeeper.YaGophKeeper/LoginUser"
-	YaGophKeeper_Ping_FullMethodName         = "/yagophkeeper.YaGophKeeper/Ping"
-	YaGophKeeper_CheckSync_FullMethodName    = "/yagophkeeper.YaGophKeeper/CheckSync"
-	YaGophKeeper_SetData_FullMethodName      = "/yagophkeeper.YaGophKeeper/SetData"
-	YaGophKeeper_GetData_FullMethodName      = "/yagophkeeper.YaGophKeeper/GetData"
+	YaGophKeeper_RegisterUser_FullMethodName   = "/yagophkeeper.YaGophKeeper/RegisterUser"
+	YaGophKeeper_LoginUser_FullMethodName      = "/yagophkeeper.YaGophKeeper/LoginUser"
+	YaGophKeeper_RefreshToken_FullMethodName   = "/yagophkeeper.YaGophKeeper/RefreshToken"
+	YaGophKeeper_Logout_FullMethodName         = "/yagophkeeper.YaGophKeeper/Logout"
+	YaGophKeeper_ChangePassword_FullMethodName = "/yagophkeeper.YaGophKeeper/ChangePassword"
+	YaGophKeeper_DeleteAccount_FullMethodName  = "/yagophkeeper.YaGophKeeper/DeleteAccount"
+	YaGophKeeper_RegisterDevice_FullMethodName = "/yagophkeeper.YaGophKeeper/RegisterDevice"
+	YaGophKeeper_ListDevices_FullMethodName    = "/yagophkeeper.YaGophKeeper/ListDevices"
+	YaGophKeeper_RevokeDevice_FullMethodName   = "/yagophkeeper.YaGophKeeper/RevokeDevice"
+	YaGophKeeper_Ping_FullMethodName           = "/yagophkeeper.YaGophKeeper/Ping"
+	YaGophKeeper_CheckSync_FullMethodName      = "/yagophkeeper.YaGophKeeper/CheckSync"
+	YaGophKeeper_SetData_FullMethodName        = "/yagophkeeper.YaGophKeeper/SetData"
+	YaGophKeeper_GetData_FullMethodName        = "/yagophkeeper.YaGophKeeper/GetData"
+	YaGophKeeper_PushRecords_FullMethodName    = "/yagophkeeper.YaGophKeeper/PushRecords"
+	YaGophKeeper_PullRecords_FullMethodName    = "/yagophkeeper.YaGophKeeper/PullRecords"
+	YaGophKeeper_MissingChunks_FullMethodName  = "/yagophkeeper.YaGophKeeper/MissingChunks"
+	YaGophKeeper_UploadChunks_FullMethodName   = "/yagophkeeper.YaGophKeeper/UploadChunks"
+	YaGophKeeper_DownloadChunks_FullMethodName = "/yagophkeeper.YaGophKeeper/DownloadChunks"
+	YaGophKeeper_ListVersions_FullMethodName   = "/yagophkeeper.YaGophKeeper/ListVersions"
+	YaGophKeeper_GetVersion_FullMethodName     = "/yagophkeeper.YaGophKeeper/GetVersion"
+	YaGophKeeper_Rekey_FullMethodName          = "/yagophkeeper.YaGophKeeper/Rekey"
+	YaGophKeeper_GetKeyCheck_FullMethodName    = "/yagophkeeper.YaGophKeeper/GetKeyCheck"
 )
 
 // YaGophKeeperClient is the client API for YaGophKeeper service.
@@ -34,10 +50,38 @@ const (
 type YaGophKeeperClient interface {
 	RegisterUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*AuthResponse, error)
 	LoginUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*AuthResponse, error)
+	// RefreshToken обменивает refresh token на новую пару токенов, старый refresh token становится недействительным
+	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// Logout отзывает refresh token
+	Logout(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ChangePassword меняет пароль аккаунта. Токены и другие устройства отзываются, текущее устройство получает новые токены
+	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// DeleteAccount удаляет аккаунт и все данные пользователя на сервере
+	DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error)
+	ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	// RevokeDevice отзывает токены устройства, следующий запрос с него получит Unauthenticated
+	RevokeDevice(ctx context.Context, in *RevokeDeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Ping(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	CheckSync(ctx context.Context, in *CheckSyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
 	SetData(ctx context.Context, in *Secrets, opts ...grpc.CallOption) (*SyncResponse, error)
 	GetData(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Secrets, error)
+	PushRecords(ctx context.Context, in *PushRecordsRequest, opts ...grpc.CallOption) (*PushRecordsResponse, error)
+	PullRecords(ctx context.Context, in *PullRecordsRequest, opts ...grpc.CallOption) (*PullRecordsResponse, error)
+	// MissingChunks возвращает чанки из запроса, которых нет на сервере. Повторная загрузка после обрыва
+	// начинается с этого вызова и отправляет только недостающие чанки
+	MissingChunks(ctx context.Context, in *ChunkIDs, opts ...grpc.CallOption) (*ChunkIDs, error)
+	UploadChunks(ctx context.Context, opts ...grpc.CallOption) (YaGophKeeper_UploadChunksClient, error)
+	// DownloadChunks отдает запрошенные чанки, отсутствующие на сервере пропускаются
+	DownloadChunks(ctx context.Context, in *ChunkIDs, opts ...grpc.CallOption) (YaGophKeeper_DownloadChunksClient, error)
+	// ListVersions возвращает сохраненные версии хранилища, новые первыми
+	ListVersions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListVersionsResponse, error)
+	// GetVersion возвращает записи версии. Восстановление выполняет клиент, отправляя их как новые изменения
+	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error)
+	// Rekey атомарно заменяет записи после смены мастер-пароля. История хранилища, зашифрованная старым ключом, удаляется
+	Rekey(ctx context.Context, in *RekeyRequest, opts ...grpc.CallOption) (*RekeyResponse, error)
+	// GetKeyCheck возвращает соль ключа записей и key_check последней смены мастер-пароля, пустой - пароль не менялся
+	GetKeyCheck(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*KeyCheck, error)
 }
 
 type yaGophKeeperClient struct {
@@ -66,6 +110,69 @@ func (c *yaGophKeeperClient) LoginUser(ctx context.Context, in *User, opts ...gr
 	return out, nil
 }
 
+func (c *yaGophKeeperClient) RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_RefreshToken_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) Logout(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, YaGophKeeper_Logout_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_ChangePassword_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, YaGophKeeper_DeleteAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*RegisterDeviceResponse, error) {
+	out := new(RegisterDeviceResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_RegisterDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_ListDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) RevokeDevice(ctx context.Context, in *RevokeDeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, YaGophKeeper_RevokeDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *yaGophKeeperClient) Ping(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, YaGophKeeper_Ping_FullMethodName, in, out, opts...)
@@ -102,16 +209,173 @@ func (c *yaGophKeeperClient) GetData(ctx context.Context, in *emptypb.Empty, opt
 	return out, nil
 }
 
+func (c *yaGophKeeperClient) PushRecords(ctx context.Context, in *PushRecordsRequest, opts ...grpc.CallOption) (*PushRecordsResponse, error) {
+	out := new(PushRecordsResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_PushRecords_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) PullRecords(ctx context.Context, in *PullRecordsRequest, opts ...grpc.CallOption) (*PullRecordsResponse, error) {
+	out := new(PullRecordsResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_PullRecords_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) MissingChunks(ctx context.Context, in *ChunkIDs, opts ...grpc.CallOption) (*ChunkIDs, error) {
+	out := new(ChunkIDs)
+	err := c.cc.Invoke(ctx, YaGophKeeper_MissingChunks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) UploadChunks(ctx context.Context, opts ...grpc.CallOption) (YaGophKeeper_UploadChunksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &YaGophKeeper_ServiceDesc.Streams[0], YaGophKeeper_UploadChunks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &yaGophKeeperUploadChunksClient{stream}
+	return x, nil
+}
+
+type YaGophKeeper_UploadChunksClient interface {
+	Send(*Chunk) error
+	CloseAndRecv() (*UploadChunksResponse, error)
+	grpc.ClientStream
+}
+
+type yaGophKeeperUploadChunksClient struct {
+	grpc.ClientStream
+}
+
+func (x *yaGophKeeperUploadChunksClient) Send(m *Chunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *yaGophKeeperUploadChunksClient) CloseAndRecv() (*UploadChunksResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadChunksResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *yaGophKeeperClient) DownloadChunks(ctx context.Context, in *ChunkIDs, opts ...grpc.CallOption) (YaGophKeeper_DownloadChunksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &YaGophKeeper_ServiceDesc.Streams[1], YaGophKeeper_DownloadChunks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &yaGophKeeperDownloadChunksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type YaGophKeeper_DownloadChunksClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type yaGophKeeperDownloadChunksClient struct {
+	grpc.ClientStream
+}
+
+func (x *yaGophKeeperDownloadChunksClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *yaGophKeeperClient) ListVersions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_ListVersions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error) {
+	out := new(GetVersionResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_GetVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) Rekey(ctx context.Context, in *RekeyRequest, opts ...grpc.CallOption) (*RekeyResponse, error) {
+	out := new(RekeyResponse)
+	err := c.cc.Invoke(ctx, YaGophKeeper_Rekey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *yaGophKeeperClient) GetKeyCheck(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*KeyCheck, error) {
+	out := new(KeyCheck)
+	err := c.cc.Invoke(ctx, YaGophKeeper_GetKeyCheck_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // YaGophKeeperServer is the server API for YaGophKeeper service.
 // All implementations must embed UnimplementedYaGophKeeperServer
 // for forward compatibility
 type YaGophKeeperServer interface {
 	RegisterUser(context.Context, *User) (*AuthResponse, error)
 	LoginUser(context.Context, *User) (*AuthResponse, error)
+	// RefreshToken обменивает refresh token на новую пару токенов, старый refresh token становится недействительным
+	RefreshToken(context.Context, *RefreshTokenRequest) (*AuthResponse, error)
+	// Logout отзывает refresh token
+	Logout(context.Context, *RefreshTokenRequest) (*emptypb.Empty, error)
+	// ChangePassword меняет пароль аккаунта. Токены и другие устройства отзываются, текущее устройство получает новые токены
+	ChangePassword(context.Context, *ChangePasswordRequest) (*AuthResponse, error)
+	// DeleteAccount удаляет аккаунт и все данные пользователя на сервере
+	DeleteAccount(context.Context, *DeleteAccountRequest) (*emptypb.Empty, error)
+	RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error)
+	ListDevices(context.Context, *emptypb.Empty) (*ListDevicesResponse, error)
+	// RevokeDevice отзывает токены устройства, следующий запрос с него получит Unauthenticated
+	RevokeDevice(context.Context, *RevokeDeviceRequest) (*emptypb.Empty, error)
 	Ping(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
 	CheckSync(context.Context, *CheckSyncRequest) (*SyncResponse, error)
 	SetData(context.Context, *Secrets) (*SyncResponse, error)
 	GetData(context.Context, *emptypb.Empty) (*Secrets, error)
+	PushRecords(context.Context, *PushRecordsRequest) (*PushRecordsResponse, error)
+	PullRecords(context.Context, *PullRecordsRequest) (*PullRecordsResponse, error)
+	// MissingChunks возвращает чанки из запроса, которых нет на сервере. Повторная загрузка после обрыва
+	// начинается с этого вызова и отправляет только недостающие чанки
+	MissingChunks(context.Context, *ChunkIDs) (*ChunkIDs, error)
+	UploadChunks(YaGophKeeper_UploadChunksServer) error
+	// DownloadChunks отдает запрошенные чанки, отсутствующие на сервере пропускаются
+	DownloadChunks(*ChunkIDs, YaGophKeeper_DownloadChunksServer) error
+	// ListVersions возвращает сохраненные версии хранилища, новые первыми
+	ListVersions(context.Context, *emptypb.Empty) (*ListVersionsResponse, error)
+	// GetVersion возвращает записи версии. Восстановление выполняет клиент, отправляя их как новые изменения
+	GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error)
+	// Rekey атомарно заменяет записи после смены мастер-пароля. История хранилища, зашифрованная старым ключом, удаляется
+	Rekey(context.Context, *RekeyRequest) (*RekeyResponse, error)
+	// GetKeyCheck возвращает соль ключа записей и key_check последней смены мастер-пароля, пустой - пароль не менялся
+	GetKeyCheck(context.Context, *emptypb.Empty) (*KeyCheck, error)
 	mustEmbedUnimplementedYaGophKeeperServer()
 }
 
@@ -125,6 +389,27 @@ func (UnimplementedYaGophKeeperServer) RegisterUser(context.Context, *User) (*Au
 func (UnimplementedYaGophKeeperServer) LoginUser(context.Context, *User) (*AuthResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LoginUser not implemented")
 }
+func (UnimplementedYaGophKeeperServer) RefreshToken(context.Context, *RefreshTokenRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (UnimplementedYaGophKeeperServer) Logout(context.Context, *RefreshTokenRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedYaGophKeeperServer) ChangePassword(context.Context, *ChangePasswordRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
+}
+func (UnimplementedYaGophKeeperServer) DeleteAccount(context.Context, *DeleteAccountRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccount not implemented")
+}
+func (UnimplementedYaGophKeeperServer) RegisterDevice(context.Context, *RegisterDeviceRequest) (*RegisterDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDevice not implemented")
+}
+func (UnimplementedYaGophKeeperServer) ListDevices(context.Context, *emptypb.Empty) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedYaGophKeeperServer) RevokeDevice(context.Context, *RevokeDeviceRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeDevice not implemented")
+}
 func (UnimplementedYaGophKeeperServer) Ping(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
@@ -137,6 +422,33 @@ func (UnimplementedYaGophKeeperServer) SetData(context.Context, *Secrets) (*Sync
 func (UnimplementedYaGophKeeperServer) GetData(context.Context, *emptypb.Empty) (*Secrets, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetData not implemented")
 }
+func (UnimplementedYaGophKeeperServer) PushRecords(context.Context, *PushRecordsRequest) (*PushRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushRecords not implemented")
+}
+func (UnimplementedYaGophKeeperServer) PullRecords(context.Context, *PullRecordsRequest) (*PullRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PullRecords not implemented")
+}
+func (UnimplementedYaGophKeeperServer) MissingChunks(context.Context, *ChunkIDs) (*ChunkIDs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MissingChunks not implemented")
+}
+func (UnimplementedYaGophKeeperServer) UploadChunks(YaGophKeeper_UploadChunksServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadChunks not implemented")
+}
+func (UnimplementedYaGophKeeperServer) DownloadChunks(*ChunkIDs, YaGophKeeper_DownloadChunksServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadChunks not implemented")
+}
+func (UnimplementedYaGophKeeperServer) ListVersions(context.Context, *emptypb.Empty) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedYaGophKeeperServer) GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedYaGophKeeperServer) Rekey(context.Context, *RekeyRequest) (*RekeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rekey not implemented")
+}
+func (UnimplementedYaGophKeeperServer) GetKeyCheck(context.Context, *emptypb.Empty) (*KeyCheck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKeyCheck not implemented")
+}
 func (UnimplementedYaGophKeeperServer) mustEmbedUnimplementedYaGophKeeperServer() {}
 
 // UnsafeYaGophKeeperServer may be embedded to opt out of forward compatibility for this service.
@@ -186,6 +498,132 @@ func _YaGophKeeper_LoginUser_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _YaGophKeeper_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_RefreshToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).RefreshToken(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).Logout(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_ChangePassword_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).ChangePassword(ctx, req.(*ChangePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_DeleteAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).DeleteAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_DeleteAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).DeleteAccount(ctx, req.(*DeleteAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_RegisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).RegisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_RegisterDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).RegisterDevice(ctx, req.(*RegisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).ListDevices(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_RevokeDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).RevokeDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_RevokeDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).RevokeDevice(ctx, req.(*RevokeDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _YaGophKeeper_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -258,6 +696,179 @@ func _YaGophKeeper_GetData_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _YaGophKeeper_PushRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).PushRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_PushRecords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).PushRecords(ctx, req.(*PushRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_PullRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PullRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).PullRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_PullRecords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).PullRecords(ctx, req.(*PullRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_MissingChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChunkIDs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).MissingChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_MissingChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).MissingChunks(ctx, req.(*ChunkIDs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_UploadChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(YaGophKeeperServer).UploadChunks(&yaGophKeeperUploadChunksServer{stream})
+}
+
+type YaGophKeeper_UploadChunksServer interface {
+	SendAndClose(*UploadChunksResponse) error
+	Recv() (*Chunk, error)
+	grpc.ServerStream
+}
+
+type yaGophKeeperUploadChunksServer struct {
+	grpc.ServerStream
+}
+
+func (x *yaGophKeeperUploadChunksServer) SendAndClose(m *UploadChunksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *yaGophKeeperUploadChunksServer) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _YaGophKeeper_DownloadChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChunkIDs)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(YaGophKeeperServer).DownloadChunks(m, &yaGophKeeperDownloadChunksServer{stream})
+}
+
+type YaGophKeeper_DownloadChunksServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+type yaGophKeeperDownloadChunksServer struct {
+	grpc.ServerStream
+}
+
+func (x *yaGophKeeperDownloadChunksServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _YaGophKeeper_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).ListVersions(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).GetVersion(ctx, req.(*GetVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_Rekey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RekeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).Rekey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_Rekey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).Rekey(ctx, req.(*RekeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _YaGophKeeper_GetKeyCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(YaGophKeeperServer).GetKeyCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: YaGophKeeper_GetKeyCheck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(YaGophKeeperServer).GetKeyCheck(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // YaGophKeeper_ServiceDesc is the grpc.ServiceDesc for YaGophKeeper service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -273,6 +884,34 @@ var YaGophKeeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LoginUser",
 			Handler:    _YaGophKeeper_LoginUser_Handler,
 		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _YaGophKeeper_RefreshToken_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _YaGophKeeper_Logout_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _YaGophKeeper_ChangePassword_Handler,
+		},
+		{
+			MethodName: "DeleteAccount",
+			Handler:    _YaGophKeeper_DeleteAccount_Handler,
+		},
+		{
+			MethodName: "RegisterDevice",
+			Handler:    _YaGophKeeper_RegisterDevice_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _YaGophKeeper_ListDevices_Handler,
+		},
+		{
+			MethodName: "RevokeDevice",
+			Handler:    _YaGophKeeper_RevokeDevice_Handler,
+		},
 		{
 			MethodName: "Ping",
 			Handler:    _YaGophKeeper_Ping_Handler,
@@ -289,7 +928,46 @@ var YaGophKeeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetData",
 			Handler:    _YaGophKeeper_GetData_Handler,
 		},
+		{
+			MethodName: "PushRecords",
+			Handler:    _YaGophKeeper_PushRecords_Handler,
+		},
+		{
+			MethodName: "PullRecords",
+			Handler:    _YaGophKeeper_PullRecords_Handler,
+		},
+		{
+			MethodName: "MissingChunks",
+			Handler:    _YaGophKeeper_MissingChunks_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _YaGophKeeper_ListVersions_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _YaGophKeeper_GetVersion_Handler,
+		},
+		{
+			MethodName: "Rekey",
+			Handler:    _YaGophKeeper_Rekey_Handler,
+		},
+		{
+			MethodName: "GetKeyCheck",
+			Handler:    _YaGophKeeper_GetKeyCheck_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadChunks",
+			Handler:       _YaGophKeeper_UploadChunks_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadChunks",
+			Handler:       _YaGophKeeper_DownloadChunks_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "yagophkeeper.proto",
 }
@@ -0,0 +1,163 @@
+// Package admin передает административные команды запущенному серверу через Unix-сокет.
+// Файл bolt, открытый сервером, заблокирован, поэтому команды выполняет сам сервер.
+// Сокет доступен только пользователю, от которого запущен сервер.
+package admin
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	opPing     = "ping"
+	opLockouts = "lockouts"
+	opUnlock   = "unlock"
+
+	timeout = 5 * time.Second
+)
+
+var (
+	ErrNotRunning     = errors.New("server admin socket is not available")
+	ErrAlreadyRunning = errors.New("server admin socket is in use by another server")
+)
+
+type request struct {
+	Op      string
+	Subject string
+}
+
+type response struct {
+	Lockouts []usecase.Lockout
+	Err      string
+}
+
+// Usecase административные операции сервера
+type Usecase interface {
+	ListLockouts() ([]usecase.Lockout, error)
+	ClearLockout(subject string) error
+}
+
+type Server struct {
+	socket   string
+	usecase  Usecase
+	logger   *zap.Logger
+	listener *net.UnixListener
+}
+
+// Listen создает сокет, доступный только владельцу. Сокет, оставшийся после аварийного
+// завершения сервера, удаляется
+func Listen(socket string, uc Usecase, logger *zap.Logger) (*Server, error) {
+	if _, err := call(socket, request{Op: opPing}); err == nil {
+		return nil, ErrAlreadyRunning
+	}
+	_ = os.Remove(socket)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return &Server{socket: socket, usecase: uc, logger: logger, listener: l}, nil
+}
+
+// Serve обрабатывает команды до Close
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.logger.Debug(err.Error())
+			continue
+		}
+		s.handle(conn)
+	}
+}
+
+// Close закрывает и удаляет сокет
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	_ = os.Remove(s.socket)
+	return err
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	var req request
+	if err := gob.NewDecoder(conn).Decode(&req); err != nil {
+		s.logger.Debug(err.Error())
+		return
+	}
+	var resp response
+	var err error
+	switch req.Op {
+	case opPing:
+	case opLockouts:
+		resp.Lockouts, err = s.usecase.ListLockouts()
+	case opUnlock:
+		err = s.usecase.ClearLockout(req.Subject)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	}
+	if err = gob.NewEncoder(conn).Encode(resp); err != nil {
+		s.logger.Debug(err.Error())
+	}
+}
+
+// Client выполняет административные команды в запущенном сервере
+type Client struct {
+	socket string
+}
+
+// Dial проверяет, что сервер слушает сокет, и возвращает клиент к нему
+func Dial(socket string) (*Client, error) {
+	if _, err := call(socket, request{Op: opPing}); err != nil {
+		return nil, err
+	}
+	return &Client{socket: socket}, nil
+}
+
+// ListLockouts возвращает счетчики неудачных попыток входа
+func (c *Client) ListLockouts() ([]usecase.Lockout, error) {
+	resp, err := call(c.socket, request{Op: opLockouts})
+	return resp.Lockouts, err
+}
+
+// ClearLockout сбрасывает блокировку входа по email или адресу клиента
+func (c *Client) ClearLockout(subject string) error {
+	_, err := call(c.socket, request{Op: opUnlock, Subject: subject})
+	return err
+}
+
+func call(socket string, req request) (response, error) {
+	conn, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		return response{}, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err = gob.NewEncoder(conn).Encode(req); err != nil {
+		return response{}, err
+	}
+	var resp response
+	if err = gob.NewDecoder(conn).Decode(&resp); err != nil {
+		return response{}, err
+	}
+	if resp.Err != "" {
+		return resp, errors.New(resp.Err)
+	}
+	return resp, nil
+}
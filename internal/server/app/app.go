@@ -1,7 +1,10 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"github.com/Spear5030/yagophkeeper/internal/server"
+	"github.com/Spear5030/yagophkeeper/internal/server/admin"
 	"github.com/Spear5030/yagophkeeper/internal/server/config"
 	"github.com/Spear5030/yagophkeeper/internal/server/storage"
 	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
@@ -10,8 +13,10 @@ import (
 )
 
 type App struct {
-	GRPCServer *server.YaGophKeeperServer
-	logger     *zap.Logger
+	GRPCServer  *server.YaGophKeeperServer
+	admin       Admin
+	adminSocket string
+	logger      *zap.Logger
 }
 
 func New(cfg config.Config) (*App, error) {
@@ -19,16 +24,103 @@ func New(cfg config.Config) (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	s, err := storage.New(cfg.FileStorage, lg)
-	if err != nil {
-		return nil, err
+	var srv *server.YaGophKeeperServer
+	var adm Admin
+	switch cfg.Storage {
+	case config.StorageBolt:
+		s, err := storage.New(cfg.FileStorage, lg)
+		if err != nil {
+			return nil, err
+		}
+		uc := usecase.New(s, lg, cfg.Secret, cfg.History)
+		uc.SetLoginLimit(loginLimit(cfg))
+		srv = server.New(uc, lg, cfg)
+		adm = uc
+	case config.StoragePostgres:
+		if cfg.DatabaseDSN == "" {
+			return nil, errors.New("GK_SERVER_DSN is required for postgres storage")
+		}
+		s, err := storage.NewPostgres(cfg.DatabaseDSN, lg)
+		if err != nil {
+			return nil, err
+		}
+		uc := usecase.New(s, lg, cfg.Secret, cfg.History)
+		uc.SetLoginLimit(loginLimit(cfg))
+		srv = server.New(uc, lg, cfg)
+		adm = uc
+	default:
+		return nil, fmt.Errorf("unknown storage %q, supported: %s, %s", cfg.Storage, config.StorageBolt, config.StoragePostgres)
 	}
-	uc := usecase.New(s, lg, cfg.Secret)
-	srv := server.New(uc, lg, cfg)
-	return &App{GRPCServer: srv, logger: lg}, nil
+	return &App{GRPCServer: srv, admin: adm, adminSocket: cfg.AdminSocket, logger: lg}, nil
 }
 
 func (app *App) Run() error {
+	if app.adminSocket != "" {
+		a, err := admin.Listen(app.adminSocket, app.admin, app.logger)
+		if err != nil {
+			return fmt.Errorf("admin socket %s: %w", app.adminSocket, err)
+		}
+		defer a.Close()
+		go func() {
+			if err := a.Serve(); err != nil {
+				app.logger.Error("admin socket", zap.Error(err))
+			}
+		}()
+	}
 	return app.GRPCServer.Start()
 
 }
+
+// Admin административные операции сервера, выполняемые без запуска gRPC сервера
+type Admin interface {
+	ListLockouts() ([]usecase.Lockout, error)
+	ClearLockout(subject string) error
+}
+
+// NewAdmin подключается к запущенному серверу через AdminSocket, а если сервер не запущен,
+// открывает его хранилище. Файл bolt запущенного сервера заблокирован, без сокета команда не выполнится
+func NewAdmin(cfg config.Config) (Admin, error) {
+	if cfg.AdminSocket != "" {
+		if c, err := admin.Dial(cfg.AdminSocket); err == nil {
+			return c, nil
+		}
+	}
+	lg, err := logger.New(false)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Storage {
+	case config.StorageBolt:
+		s, err := storage.New(cfg.FileStorage, lg)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w; if the server is running, set GK_SERVER_ADMIN_SOCKET to its admin socket",
+				cfg.FileStorage, err)
+		}
+		uc := usecase.New(s, lg, cfg.Secret, cfg.History)
+		uc.SetLoginLimit(loginLimit(cfg))
+		return uc, nil
+	case config.StoragePostgres:
+		if cfg.DatabaseDSN == "" {
+			return nil, errors.New("GK_SERVER_DSN is required for postgres storage")
+		}
+		s, err := storage.NewPostgres(cfg.DatabaseDSN, lg)
+		if err != nil {
+			return nil, err
+		}
+		uc := usecase.New(s, lg, cfg.Secret, cfg.History)
+		uc.SetLoginLimit(loginLimit(cfg))
+		return uc, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q, supported: %s, %s", cfg.Storage, config.StorageBolt, config.StoragePostgres)
+	}
+}
+
+func loginLimit(cfg config.Config) usecase.LoginLimit {
+	return usecase.LoginLimit{
+		Attempts:     cfg.LoginAttempts,
+		AddrAttempts: cfg.LoginAddrAttempts,
+		Lockout:      cfg.LoginLockout,
+		MaxLockout:   cfg.LoginMaxLockout,
+		Window:       cfg.LoginWindow,
+	}
+}
@@ -3,14 +3,39 @@ package config
 import (
 	"flag"
 	"github.com/caarlos0/env"
+	"time"
+)
+
+const (
+	StorageBolt     = "bolt"
+	StoragePostgres = "postgres"
 )
 
 type Config struct {
+	// Storage хранилище: bolt - файл FileStorage, postgres - база DatabaseDSN, которую могут использовать несколько реплик
+	Storage     string `env:"GK_SERVER_STORAGE" envDefault:"bolt"`
 	FileStorage string `env:"GK_SERVER_FILE" envDefault:"gkdata.pbb"`
+	DatabaseDSN string `env:"GK_SERVER_DSN"`
 	Secret      string `env:"GK_SERVER_SECRET" envDefault:"V3ry$trongK3y"`
-	Port        string `env:"GK_SERVER_PORT" envDefault:"22345"`
-	ServerCert  string `env:"GK_SERVER_CERT" envDefault:"cert/server-cert.pem"`
-	ServerKey   string `env:"GK_SERVER_KEY" envDefault:"cert/server-key.pem"`
+	// History сколько последних версий хранилища пользователя хранить, 0 - не хранить
+	History    int    `env:"GK_SERVER_HISTORY" envDefault:"20"`
+	Port       string `env:"GK_SERVER_PORT" envDefault:"22345"`
+	ServerCert string `env:"GK_SERVER_CERT" envDefault:"cert/server-cert.pem"`
+	ServerKey  string `env:"GK_SERVER_KEY" envDefault:"cert/server-key.pem"`
+	// LoginAttempts неудачных попыток входа по email до блокировки, LoginAddrAttempts - по адресу клиента, 0 - без ограничения.
+	// Блокировка LoginLockout удваивается с каждой следующей неудачей до LoginMaxLockout,
+	// счетчик сбрасывается через LoginWindow после последней неудачи
+	LoginAttempts     int           `env:"GK_SERVER_LOGIN_ATTEMPTS" envDefault:"5"`
+	LoginAddrAttempts int           `env:"GK_SERVER_LOGIN_ADDR_ATTEMPTS" envDefault:"20"`
+	LoginLockout      time.Duration `env:"GK_SERVER_LOGIN_LOCKOUT" envDefault:"30s"`
+	LoginMaxLockout   time.Duration `env:"GK_SERVER_LOGIN_MAX_LOCKOUT" envDefault:"1h"`
+	LoginWindow       time.Duration `env:"GK_SERVER_LOGIN_WINDOW" envDefault:"24h"`
+	// AdminSocket Unix-сокет, через который -unlock и -lockouts выполняются запущенным сервером, пустой - без сокета
+	AdminSocket string `env:"GK_SERVER_ADMIN_SOCKET" envDefault:"gkadmin.sock"`
+	// Unlock email или адрес клиента, блокировку входа которого нужно снять вместо запуска сервера
+	Unlock string
+	// Lockouts вывести счетчики неудачных попыток входа вместо запуска сервера
+	Lockouts bool
 }
 
 var cfg Config
@@ -19,6 +44,8 @@ func New() (Config, error) {
 	if err := env.Parse(&cfg); err != nil {
 		return Config{}, err
 	}
+	flag.StringVar(&cfg.Unlock, "unlock", "", "clear login lockout of email or client address and exit")
+	flag.BoolVar(&cfg.Lockouts, "lockouts", false, "list failed login attempts and lockouts and exit")
 	flag.Parse()
 	return cfg, nil
 }
@@ -3,19 +3,25 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/Spear5030/yagophkeeper/internal/pb"
 	"github.com/Spear5030/yagophkeeper/internal/server/config"
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	ucase "github.com/Spear5030/yagophkeeper/internal/server/usecase"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
 	"log"
 	"net"
 	"time"
@@ -31,11 +37,28 @@ type YaGophKeeperServer struct {
 }
 
 type usecase interface {
-	RegisterUser(email string, password string) (token string, err error)
-	LoginUser(email string, password string) (token string, err error)
+	RegisterUser(email string, password string) (tokens domain.Tokens, err error)
+	LoginUser(email string, password string, addr string) (tokens domain.Tokens, err error)
+	ChangePassword(email string, deviceID string, password string, newPassword string, addr string) (domain.Tokens, error)
+	DeleteAccount(email string, password string, addr string) error
+	RefreshToken(refreshToken string) (tokens domain.Tokens, err error)
+	Logout(refreshToken string) error
+	RegisterDevice(email string, name string, refreshToken string) (deviceID string, tokens domain.Tokens, err error)
+	ListDevices(email string, current string) ([]domain.Device, error)
+	RevokeDevice(email string, id string) error
+	CheckDevice(email string, id string) error
 	GetLastSyncTime(email string) (lastSync time.Time, err error)
 	SetData(email string, data []byte) (err error)
 	GetData(email string) (data []byte, err error)
+	PushRecords(email string, deviceID string, records []domain.Record) (accepted []domain.Record, conflicts []domain.Record, revision int64, err error)
+	PullRecords(email string, since int64) (records []domain.Record, revision int64, err error)
+	SaveChunk(email string, id string, data []byte) error
+	GetChunk(email string, id string) ([]byte, error)
+	MissingChunks(email string, ids []string) ([]string, error)
+	ListVersions(email string) ([]domain.VaultVersion, error)
+	GetVersion(email string, number int64) (domain.VaultVersion, []domain.Record, error)
+	Rekey(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams) (accepted []domain.Record, newRevision int64, err error)
+	GetRecordKeyParams(email string) (domain.RecordKeyParams, error)
 }
 
 func New(usecase usecase, logger *zap.Logger, cfg config.Config) *YaGophKeeperServer {
@@ -54,6 +77,7 @@ func New(usecase usecase, logger *zap.Logger, cfg config.Config) *YaGophKeeperSe
 	s.server = grpc.NewServer(
 		grpc.Creds(tlsCredentials),
 		grpc.UnaryInterceptor(s.AuthInterceptor),
+		grpc.StreamInterceptor(s.StreamAuthInterceptor),
 	)
 	reflection.Register(s.server) // for postman
 	pb.RegisterYaGophKeeperServer(s.server, s)
@@ -71,14 +95,57 @@ func (s *YaGophKeeperServer) Start() error {
 }
 
 func (s *YaGophKeeperServer) RegisterUser(ctx context.Context, user *pb.User) (*pb.AuthResponse, error) {
-	var resp = &pb.AuthResponse{}
-	var err error
-	resp.Token, err = s.usecase.RegisterUser(user.Email, user.Password)
+	tokens, err := s.usecase.RegisterUser(user.Email, user.Password)
 	if err != nil {
 		s.logger.Debug("RegisterUser error", zap.Error(err))
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	return resp, err
+	return &pb.AuthResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, err
+}
+
+// RegisterDevice регистрирует устройство и выдает привязанные к нему токены
+func (s *YaGophKeeperServer) RegisterDevice(ctx context.Context, req *pb.RegisterDeviceRequest) (*pb.RegisterDeviceResponse, error) {
+	if getDeviceFromContext(ctx) != "" {
+		return nil, status.Error(codes.FailedPrecondition, "device already registered")
+	}
+	deviceID, tokens, err := s.usecase.RegisterDevice(getEmailFromContext(ctx), req.Name, req.RefreshToken)
+	if errors.Is(err, ucase.ErrInvalidRefreshToken) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.RegisterDeviceResponse{DeviceId: deviceID, Token: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+func (s *YaGophKeeperServer) ListDevices(ctx context.Context, empty *emptypb.Empty) (*pb.ListDevicesResponse, error) {
+	devices, err := s.usecase.ListDevices(getEmailFromContext(ctx), getDeviceFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var resp = &pb.ListDevicesResponse{}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, &pb.Device{
+			Id:        d.ID,
+			Name:      d.Name,
+			CreatedAt: timestamppb.New(d.CreatedAt),
+			LastSeen:  timestamppb.New(d.LastSeen),
+			Revoked:   d.Revoked,
+			Current:   d.Current,
+		})
+	}
+	return resp, nil
+}
+
+func (s *YaGophKeeperServer) RevokeDevice(ctx context.Context, req *pb.RevokeDeviceRequest) (*emptypb.Empty, error) {
+	err := s.usecase.RevokeDevice(getEmailFromContext(ctx), req.DeviceId)
+	if errors.Is(err, storage.ErrDeviceNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
 }
 
 func (s *YaGophKeeperServer) CheckSync(ctx context.Context, req *pb.CheckSyncRequest) (*pb.SyncResponse, error) {
@@ -93,14 +160,80 @@ func (s *YaGophKeeperServer) CheckSync(ctx context.Context, req *pb.CheckSyncReq
 	return resp, err
 }
 
+// LoginUser выдает токены по email и паролю. Неверный пароль и неизвестный email - Unauthenticated
+// с одинаковым сообщением, чтобы по ответу нельзя было проверить email. После серии неудач email
+// или адрес клиента временно блокируются - ResourceExhausted
 func (s *YaGophKeeperServer) LoginUser(ctx context.Context, user *pb.User) (*pb.AuthResponse, error) {
-	var resp = &pb.AuthResponse{}
-	var err error
-	resp.Token, err = s.usecase.LoginUser(user.Email, user.Password)
+	tokens, err := s.usecase.LoginUser(user.Email, user.Password, getPeerHost(ctx))
+	if errors.Is(err, ucase.ErrTooManyAttempts) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, ucase.ErrWrongPassword) || errors.Is(err, storage.ErrUserNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "wrong email or password")
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	return resp, err
+	return &pb.AuthResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, err
+}
+
+// ChangePassword меняет пароль аккаунта и выдает новые токены текущему устройству.
+// Неверный пароль - PermissionDenied, чтобы клиент не принял его за истекший токен.
+// Блокировка после серии неудач - ResourceExhausted, как при входе
+func (s *YaGophKeeperServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.AuthResponse, error) {
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty new password")
+	}
+	tokens, err := s.usecase.ChangePassword(getEmailFromContext(ctx), getDeviceFromContext(ctx), req.Password, req.NewPassword, getPeerHost(ctx))
+	if errors.Is(err, ucase.ErrTooManyAttempts) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, ucase.ErrWrongPassword) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.AuthResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+// DeleteAccount удаляет аккаунт и все данные пользователя
+func (s *YaGophKeeperServer) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*emptypb.Empty, error) {
+	err := s.usecase.DeleteAccount(getEmailFromContext(ctx), req.Password, getPeerHost(ctx))
+	if errors.Is(err, ucase.ErrTooManyAttempts) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, ucase.ErrWrongPassword) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// RefreshToken выдает новую пару токенов в обмен на refresh token
+func (s *YaGophKeeperServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.AuthResponse, error) {
+	tokens, err := s.usecase.RefreshToken(req.RefreshToken)
+	if errors.Is(err, ucase.ErrInvalidRefreshToken) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.AuthResponse{Token: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+// Logout отзывает refresh token
+func (s *YaGophKeeperServer) Logout(ctx context.Context, req *pb.RefreshTokenRequest) (*emptypb.Empty, error) {
+	err := s.usecase.Logout(req.RefreshToken)
+	if errors.Is(err, ucase.ErrInvalidRefreshToken) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
 }
 
 func (s *YaGophKeeperServer) SetData(ctx context.Context, secrets *pb.Secrets) (*pb.SyncResponse, error) {
@@ -123,13 +256,192 @@ func (s *YaGophKeeperServer) GetData(ctx context.Context, empty *emptypb.Empty)
 	return resp, nil
 }
 
+// PushRecords принимает измененные записи. Записи, измененные на сервере другим устройством, возвращаются как конфликты
+func (s *YaGophKeeperServer) PushRecords(ctx context.Context, req *pb.PushRecordsRequest) (*pb.PushRecordsResponse, error) {
+	var resp = &pb.PushRecordsResponse{}
+	for _, r := range req.Records {
+		if r.Id == "" {
+			return nil, status.Error(codes.InvalidArgument, "empty record id")
+		}
+	}
+	accepted, conflicts, revision, err := s.usecase.PushRecords(getEmailFromContext(ctx), getDeviceFromContext(ctx), recordsFromPB(req.Records))
+	if errors.Is(err, ucase.ErrInvalidChunks) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, storage.ErrMissingChunks) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp.Accepted = recordsToPB(accepted)
+	resp.Conflicts = recordsToPB(conflicts)
+	resp.Revision = revision
+	return resp, nil
+}
+
+// PullRecords отдает записи, измененные после ревизии клиента
+func (s *YaGophKeeperServer) PullRecords(ctx context.Context, req *pb.PullRecordsRequest) (*pb.PullRecordsResponse, error) {
+	var resp = &pb.PullRecordsResponse{}
+	records, revision, err := s.usecase.PullRecords(getEmailFromContext(ctx), req.SinceRevision)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp.Records = recordsToPB(records)
+	resp.Revision = revision
+	return resp, nil
+}
+
+// MissingChunks возвращает чанки, которых нет на сервере
+func (s *YaGophKeeperServer) MissingChunks(ctx context.Context, req *pb.ChunkIDs) (*pb.ChunkIDs, error) {
+	missing, err := s.usecase.MissingChunks(getEmailFromContext(ctx), req.Ids)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.ChunkIDs{Ids: missing}, nil
+}
+
+// UploadChunks принимает поток чанков. Каждый чанк сохраняется сразу, поэтому после обрыва
+// клиент отправляет только те, которых еще нет на сервере
+func (s *YaGophKeeperServer) UploadChunks(stream pb.YaGophKeeper_UploadChunksServer) error {
+	email := getEmailFromContext(stream.Context())
+	var stored int64
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.UploadChunksResponse{Stored: stored})
+		}
+		if err != nil {
+			return err
+		}
+		err = s.usecase.SaveChunk(email, chunk.Id, chunk.Data)
+		if errors.Is(err, ucase.ErrChunkHash) || errors.Is(err, ucase.ErrChunkTooLarge) {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("chunk %s: %v", chunk.Id, err))
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		stored++
+	}
+}
+
+// DownloadChunks отдает запрошенные чанки потоком
+func (s *YaGophKeeperServer) DownloadChunks(req *pb.ChunkIDs, stream pb.YaGophKeeper_DownloadChunksServer) error {
+	email := getEmailFromContext(stream.Context())
+	for _, id := range req.Ids {
+		data, err := s.usecase.GetChunk(email, id)
+		if errors.Is(err, storage.ErrChunkNotFound) {
+			continue
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err = stream.Send(&pb.Chunk{Id: id, Data: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListVersions отдает сохраненные версии хранилища пользователя
+func (s *YaGophKeeperServer) ListVersions(ctx context.Context, empty *emptypb.Empty) (*pb.ListVersionsResponse, error) {
+	versions, err := s.usecase.ListVersions(getEmailFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var resp = &pb.ListVersionsResponse{}
+	for _, v := range versions {
+		resp.Versions = append(resp.Versions, versionToPB(v))
+	}
+	return resp, nil
+}
+
+// GetVersion отдает записи версии хранилища
+func (s *YaGophKeeperServer) GetVersion(ctx context.Context, req *pb.GetVersionRequest) (*pb.GetVersionResponse, error) {
+	version, records, err := s.usecase.GetVersion(getEmailFromContext(ctx), req.Number)
+	if errors.Is(err, storage.ErrVersionNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.GetVersionResponse{Version: versionToPB(version), Records: recordsToPB(records)}, nil
+}
+
+// Rekey заменяет записи пользователя записями, зашифрованными новым мастер-паролем.
+// Если после ревизии клиента на сервер отправлялись изменения, возвращает Aborted
+func (s *YaGophKeeperServer) Rekey(ctx context.Context, req *pb.RekeyRequest) (*pb.RekeyResponse, error) {
+	for _, r := range req.Records {
+		if r.Id == "" || r.Deleted {
+			return nil, status.Error(codes.InvalidArgument, "rekey requires existing records with id")
+		}
+	}
+	if len(req.KeyCheck) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty key check")
+	}
+	accepted, revision, err := s.usecase.Rekey(getEmailFromContext(ctx), getDeviceFromContext(ctx), req.Revision,
+		recordsFromPB(req.Records), domain.RecordKeyParams{Salt: req.Salt, KeyCheck: req.KeyCheck})
+	if errors.Is(err, storage.ErrRevisionMismatch) {
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	if errors.Is(err, ucase.ErrInvalidChunks) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, storage.ErrMissingChunks) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.RekeyResponse{Accepted: recordsToPB(accepted), Revision: revision}, nil
+}
+
+// GetKeyCheck отдает соль ключа записей и значение для его проверки
+func (s *YaGophKeeperServer) GetKeyCheck(ctx context.Context, empty *emptypb.Empty) (*pb.KeyCheck, error) {
+	params, err := s.usecase.GetRecordKeyParams(getEmailFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.KeyCheck{KeyCheck: params.KeyCheck, Salt: params.Salt}, nil
+}
+
 func (s *YaGophKeeperServer) AuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	switch info.FullMethod {
 	case "/yagophkeeper.YaGophKeeper/RegisterUser":
 		return handler(ctx, req)
 	case "/yagophkeeper.YaGophKeeper/LoginUser":
 		return handler(ctx, req)
+	case "/yagophkeeper.YaGophKeeper/RefreshToken", "/yagophkeeper.YaGophKeeper/Logout":
+		return handler(ctx, req)
 	}
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamAuthInterceptor проверяет JWT потоковых методов, все они требуют входа
+func (s *YaGophKeeperServer) StreamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+// authStream поток с контекстом, в который добавлены email и устройство из JWT
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authStream) Context() context.Context {
+	return a.ctx
+}
+
+// authenticate проверяет JWT из метаданных запроса и возвращает контекст с email и устройством пользователя
+func (s *YaGophKeeperServer) authenticate(ctx context.Context, method string) (context.Context, error) {
 	var token *jwt.Token
 	var err error
 	s.logger.Debug("auth interceptor")
@@ -149,16 +461,35 @@ func (s *YaGophKeeperServer) AuthInterceptor(ctx context.Context, req interface{
 			}
 		}
 	}
+	if token == nil {
+		return nil, status.Error(codes.Unauthenticated, "no token")
+	}
 	if claims, ok := token.Claims.(jwt.MapClaims); token.Valid && ok {
 		email := claims["email"].(string)
-		s.logger.Debug("user email from jwt", zap.String("email", email))
-		ctx = metadata.AppendToOutgoingContext(ctx, "email", email) //todo check merged keys
-		return handler(ctx, req)
+		device, _ := claims["device"].(string)
+		s.logger.Debug("user email from jwt", zap.String("email", email), zap.String("device", device))
+		// без устройства токен годится только для его регистрации
+		if method != pb.YaGophKeeper_RegisterDevice_FullMethodName {
+			if err = s.usecase.CheckDevice(email, device); err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+		}
+		return metadata.AppendToOutgoingContext(ctx, "email", email, "device", device), nil //todo check merged keys
 	} else {
 		return nil, status.Error(codes.Unauthenticated, "wrong token") //todo check exp
 	}
 }
 
+func getDeviceFromContext(ctx context.Context) (device string) {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		values := md.Get("device")
+		if len(values) > 0 {
+			device = values[0]
+		}
+	}
+	return
+}
+
 func getEmailFromContext(ctx context.Context) (email string) {
 	if md, ok := metadata.FromOutgoingContext(ctx); ok {
 		values := md.Get("email")
@@ -169,6 +500,32 @@ func getEmailFromContext(ctx context.Context) (email string) {
 	return
 }
 
+func recordsFromPB(pbRecords []*pb.Record) []domain.Record {
+	records := make([]domain.Record, 0, len(pbRecords))
+	for _, r := range pbRecords {
+		records = append(records, domain.Record{ID: r.Id, Revision: r.Revision, Data: r.Data, Deleted: r.Deleted, Chunks: r.Chunks})
+	}
+	return records
+}
+
+func recordsToPB(records []domain.Record) []*pb.Record {
+	pbRecords := make([]*pb.Record, 0, len(records))
+	for _, r := range records {
+		pbRecords = append(pbRecords, &pb.Record{Id: r.ID, Revision: r.Revision, Data: r.Data, Deleted: r.Deleted, Chunks: r.Chunks})
+	}
+	return pbRecords
+}
+
+func versionToPB(v domain.VaultVersion) *pb.VaultVersion {
+	return &pb.VaultVersion{
+		Number:     v.Number,
+		CreatedAt:  timestamppb.New(v.CreatedAt),
+		DeviceId:   v.DeviceID,
+		DeviceName: v.DeviceName,
+		Records:    v.Records,
+	}
+}
+
 func loadTLSCredentials(cert string, key string) (credentials.TransportCredentials, error) {
 	serverCert, err := tls.LoadX509KeyPair(cert, key)
 	if err != nil {
@@ -182,3 +539,16 @@ func loadTLSCredentials(cert string, key string) (credentials.TransportCredentia
 
 	return credentials.NewTLS(cfg), nil
 }
+
+// getPeerHost возвращает IP адрес клиента запроса без порта
+func getPeerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
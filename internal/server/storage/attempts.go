@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+var ErrNoLoginAttempts = errors.New("no failed login attempts")
+
+// loginEmailKey ключ счетчика попыток входа по email в том виде, в котором его ведет usecase
+func loginEmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ReserveLoginAttempt засчитывает попытку входа по ключу как неудачу, если allow разрешает ее при текущем
+// состоянии счетчика. Проверка и увеличение выполняются в одной транзакции, поэтому параллельные попытки
+// не проходят сверх лимита. Счетчик, последняя неудача которого раньше resetBefore, начинается заново.
+// Возвращает состояние после попытки или, если попытка не разрешена, текущее
+func (pp *storage) ReserveLoginAttempt(key string, at time.Time, resetBefore time.Time, allow func(domain.LoginAttempts) bool) (attempts domain.LoginAttempts, reserved bool, err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("attempts"))
+		if v := b.Get([]byte(key)); v != nil {
+			if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&attempts); errDecode != nil {
+				return errDecode
+			}
+		}
+		attempts.Key = key
+		if attempts.LastFailure.Before(resetBefore) {
+			attempts.Failures = 0
+		}
+		if !allow(attempts) {
+			return nil
+		}
+		reserved = true
+		attempts.Failures++
+		attempts.LastFailure = at
+		return putLoginAttempts(b, attempts)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// ReleaseLoginAttempt отменяет попытку, засчитанную ReserveLoginAttempt, после успешного входа
+func (pp *storage) ReleaseLoginAttempt(key string) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("attempts"))
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		var attempts domain.LoginAttempts
+		if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&attempts); errDecode != nil {
+			return errDecode
+		}
+		attempts.Failures--
+		if attempts.Failures <= 0 {
+			return b.Delete([]byte(key))
+		}
+		return putLoginAttempts(b, attempts)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// GetLoginAttempts возвращает неудачные попытки входа по ключу, без попыток - нулевой счетчик
+func (pp *storage) GetLoginAttempts(key string) (attempts domain.LoginAttempts, err error) {
+	attempts.Key = key
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket([]byte("attempts")).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		return gob.NewDecoder(bytes.NewReader(v)).Decode(&attempts)
+	})
+	return
+}
+
+// ListLoginAttempts возвращает все счетчики неудачных попыток входа
+func (pp *storage) ListLoginAttempts() (list []domain.LoginAttempts, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("attempts")).ForEach(func(k, v []byte) error {
+			var attempts domain.LoginAttempts
+			if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&attempts); errDecode != nil {
+				return errDecode
+			}
+			list = append(list, attempts)
+			return nil
+		})
+	})
+	return
+}
+
+// DeleteLoginAttempts сбрасывает счетчик неудачных попыток входа по ключу
+func (pp *storage) DeleteLoginAttempts(key string) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("attempts"))
+		if b.Get([]byte(key)) == nil {
+			return ErrNoLoginAttempts
+		}
+		return b.Delete([]byte(key))
+	})
+	return
+}
+
+func putLoginAttempts(b *bbolt.Bucket, attempts domain.LoginAttempts) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(attempts); err != nil {
+		return err
+	}
+	return b.Put([]byte(attempts.Key), buf.Bytes())
+}
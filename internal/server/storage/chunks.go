@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var ErrChunkNotFound = errors.New("chunk not found")
+
+var ErrMissingChunks = errors.New("record refers to chunks not uploaded to server")
+
+// Ссылки на чанки: chunkrefs/email/id -> число текущих записей и значений в истории, которые ссылаются
+// на чанк. Счетчик создается при загрузке чанка, и чанк, на который больше никто не ссылается, удаляется.
+// Чанки, загруженные до учета ссылок, счетчика не имеют и не удаляются: на них могут ссылаться записи
+// без списка чанков
+
+// chunkRefs изменения числа ссылок на чанки в транзакции. Применяются в ее конце, чтобы чанк,
+// ссылка на который переходит от прежнего значения записи к новому, не удалялся
+type chunkRefs map[string]int
+
+func (c chunkRefs) add(ids []string, delta int) {
+	for _, id := range ids {
+		c[id] += delta
+	}
+}
+
+// apply сохраняет изменения числа ссылок и удаляет чанки, на которые не осталось ссылок
+func (c chunkRefs) apply(tx *bbolt.Tx, email string) error {
+	refs := tx.Bucket([]byte("chunkrefs")).Bucket([]byte(email))
+	if refs == nil {
+		return nil
+	}
+	chunks := tx.Bucket([]byte("chunks")).Bucket([]byte(email))
+	var err error
+	for id, delta := range c {
+		v := refs.Get([]byte(id))
+		if delta == 0 || v == nil {
+			continue
+		}
+		n := int64(binary.BigEndian.Uint64(v)) + int64(delta)
+		if n > 0 {
+			if err = refs.Put([]byte(id), binary.BigEndian.AppendUint64(nil, uint64(n))); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = refs.Delete([]byte(id)); err != nil {
+			return err
+		}
+		if chunks != nil {
+			if err = chunks.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkChunks возвращает ErrMissingChunks, если каких-то чанков из ids нет у пользователя
+func checkChunks(tx *bbolt.Tx, email string, ids []string) error {
+	b := tx.Bucket([]byte("chunks")).Bucket([]byte(email))
+	for _, id := range ids {
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrMissingChunks
+		}
+	}
+	return nil
+}
+
+// SaveChunk сохраняет чанк в бакет пользователя. Чанк с тем же id уже содержит те же данные и не перезаписывается.
+// Для нового чанка заводится счетчик ссылок
+func (pp *storage) SaveChunk(email string, id string, data []byte) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b, errCreate := tx.Bucket([]byte("chunks")).CreateBucketIfNotExists([]byte(email))
+		if errCreate != nil {
+			return errCreate
+		}
+		if b.Get([]byte(id)) != nil {
+			return nil
+		}
+		refs, errCreate := tx.Bucket([]byte("chunkrefs")).CreateBucketIfNotExists([]byte(email))
+		if errCreate != nil {
+			return errCreate
+		}
+		if errPut := refs.Put([]byte(id), make([]byte, 8)); errPut != nil {
+			return errPut
+		}
+		return b.Put([]byte(id), data)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// GetChunk возвращает чанк пользователя
+func (pp *storage) GetChunk(email string, id string) (data []byte, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("chunks")).Bucket([]byte(email))
+		if b == nil {
+			return ErrChunkNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrChunkNotFound
+		}
+		data = append([]byte(nil), v...)
+		return nil
+	})
+	return
+}
+
+// MissingChunks возвращает id чанков, которых нет у пользователя
+func (pp *storage) MissingChunks(email string, ids []string) (missing []string, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("chunks")).Bucket([]byte(email))
+		for _, id := range ids {
+			if b == nil || b.Get([]byte(id)) == nil {
+				missing = append(missing, id)
+			}
+		}
+		return nil
+	})
+	return
+}
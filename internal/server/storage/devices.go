@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// SaveDevice сохраняет устройство в бакет пользователя
+func (pp *storage) SaveDevice(email string, device domain.Device) (err error) {
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(device); err != nil {
+		return err
+	}
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b, errCreate := tx.Bucket([]byte("devices")).CreateBucketIfNotExists([]byte(email))
+		if errCreate != nil {
+			return errCreate
+		}
+		return b.Put([]byte(device.ID), buf.Bytes())
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// TouchDevice обновляет время последнего запроса устройства. Устройство перечитывается в той же транзакции,
+// поэтому отзыв, сохраненный между проверкой устройства и обновлением, не теряется
+func (pp *storage) TouchDevice(email string, id string, at time.Time) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("devices")).Bucket([]byte(email))
+		if b == nil {
+			return ErrDeviceNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrDeviceNotFound
+		}
+		var device domain.Device
+		if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&device); errDecode != nil {
+			return errDecode
+		}
+		device.LastSeen = at
+		var buf bytes.Buffer
+		if errEncode := gob.NewEncoder(&buf).Encode(device); errEncode != nil {
+			return errEncode
+		}
+		return b.Put([]byte(id), buf.Bytes())
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+func (pp *storage) GetDevice(email string, id string) (device domain.Device, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("devices")).Bucket([]byte(email))
+		if b == nil {
+			return ErrDeviceNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrDeviceNotFound
+		}
+		return gob.NewDecoder(bytes.NewReader(v)).Decode(&device)
+	})
+	return
+}
+
+// ListDevices возвращает устройства пользователя в порядке регистрации
+func (pp *storage) ListDevices(email string) (devices []domain.Device, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("devices")).Bucket([]byte(email))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var device domain.Device
+			if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&device); errDecode != nil {
+				return errDecode
+			}
+			devices = append(devices, device)
+			return nil
+		})
+	})
+	sort.Slice(devices, func(i, j int) bool { return devices[i].CreatedAt.Before(devices[j].CreatedAt) })
+	return
+}
@@ -0,0 +1,47 @@
+CREATE TABLE users (
+    email           TEXT PRIMARY KEY,
+    hashed_password BYTEA NOT NULL,
+    last_sync       TIMESTAMPTZ,
+    data            BYTEA,
+    -- revision последняя ревизия записей пользователя, аналог sequence бакета records в bbolt
+    revision        BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE records (
+    email    TEXT   NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    id       TEXT   NOT NULL,
+    revision BIGINT NOT NULL,
+    deleted  BOOLEAN NOT NULL DEFAULT FALSE,
+    data     BYTEA,
+    PRIMARY KEY (email, id)
+);
+
+CREATE INDEX records_revision_idx ON records (email, revision);
+
+CREATE TABLE refresh_tokens (
+    hash       TEXT PRIMARY KEY,
+    email      TEXT        NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    family     TEXT        NOT NULL,
+    device_id  TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used       BOOLEAN     NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX refresh_tokens_email_idx ON refresh_tokens (email);
+
+CREATE TABLE devices (
+    email      TEXT        NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    id         TEXT        NOT NULL,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen  TIMESTAMPTZ NOT NULL,
+    revoked    BOOLEAN     NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (email, id)
+);
+
+CREATE TABLE chunks (
+    email TEXT  NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    id    TEXT  NOT NULL,
+    data  BYTEA NOT NULL,
+    PRIMARY KEY (email, id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE vault_versions (
+    email      TEXT        NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    number     BIGINT      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    device_id  TEXT        NOT NULL,
+    records    BIGINT      NOT NULL,
+    -- snapshot записи версии в gob, как в хранилище bbolt
+    snapshot   BYTEA       NOT NULL,
+    PRIMARY KEY (email, number)
+);
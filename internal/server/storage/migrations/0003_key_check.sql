@@ -0,0 +1,2 @@
+-- key_check значение, зашифрованное ключом записей после смены мастер-пароля
+ALTER TABLE users ADD COLUMN key_check BYTEA;
@@ -0,0 +1,6 @@
+-- login_attempts неудачные попытки входа по email или адресу клиента
+CREATE TABLE login_attempts (
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER     NOT NULL,
+    last_failure TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+-- record_salt случайная соль ключа записей, пустая у аккаунтов, созданных до ее появления
+ALTER TABLE users ADD COLUMN record_salt BYTEA;
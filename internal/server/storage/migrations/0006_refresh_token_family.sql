@@ -0,0 +1,2 @@
+-- отзыв семейства и очистка использованных токенов при ротации ищут токены по email и family
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (email, family);
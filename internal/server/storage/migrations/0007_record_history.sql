@@ -0,0 +1,14 @@
+-- record_history прежние значения записей: значение с ревизией revision заменено на ревизии superseded_at.
+-- Версии хранилища собираются из records и record_history, см. versions.go
+CREATE TABLE record_history (
+    email         TEXT    NOT NULL REFERENCES users (email) ON DELETE CASCADE,
+    superseded_at BIGINT  NOT NULL,
+    id            TEXT    NOT NULL,
+    revision      BIGINT  NOT NULL,
+    deleted       BOOLEAN NOT NULL,
+    data          BYTEA,
+    PRIMARY KEY (email, superseded_at, id)
+);
+
+-- snapshot остается только у версий, сохраненных до появления истории
+ALTER TABLE vault_versions ALTER COLUMN snapshot DROP NOT NULL;
@@ -0,0 +1,7 @@
+-- chunks записи: id чанков binary, на которые она ссылается
+ALTER TABLE records ADD COLUMN chunks TEXT[];
+ALTER TABLE record_history ADD COLUMN chunks TEXT[];
+
+-- refs число записей и значений в истории, которые ссылаются на чанк. Чанк без ссылок удаляется.
+-- У чанков, загруженных раньше, refs NULL: на них могут ссылаться записи без списка чанков, и они не удаляются
+ALTER TABLE chunks ADD COLUMN refs BIGINT;
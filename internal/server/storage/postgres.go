@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// queryTimeout ограничивает время одного обращения к PostgreSQL
+const queryTimeout = 10 * time.Second
+
+// migrationLock ключ advisory lock, под которым применяются миграции
+const migrationLock = 0x676b6d6967
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// pgStorage хранилище в PostgreSQL. В отличие от файла bbolt его могут использовать несколько реплик сервера
+type pgStorage struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewPostgres подключается к PostgreSQL по dsn и применяет миграции схемы
+func NewPostgres(dsn string, lg *zap.Logger) (*pgStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		lg.Debug("postgres connect", zap.Error(err))
+		return nil, err
+	}
+	if err = pool.Ping(ctx); err != nil {
+		lg.Debug("postgres ping", zap.Error(err))
+		pool.Close()
+		return nil, err
+	}
+	if err = migrate(ctx, pool, lg); err != nil {
+		lg.Debug("postgres migrate", zap.Error(err))
+		pool.Close()
+		return nil, err
+	}
+	return &pgStorage{pool: pool, logger: lg}, nil
+}
+
+// Close закрывает соединения с базой
+func (pp *pgStorage) Close() {
+	pp.pool.Close()
+}
+
+// migrate применяет по порядку имен файлы migrations, которых нет в schema_migrations.
+// Реплики, запущенные одновременно, ждут друг друга на advisory lock
+func migrate(ctx context.Context, pool *pgxpool.Pool, lg *zap.Logger) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		version := strings.TrimSuffix(path.Base(name), ".sql")
+		var applied bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+		sql, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		lg.Info("applying migration", zap.String("version", version))
+		// без аргументов Exec использует simple protocol, в файле может быть несколько команд
+		if _, err = tx.Exec(ctx, string(sql)); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (pp *pgStorage) RegisterUser(email string, hashedPassword []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	tag, err := pp.pool.Exec(ctx, `INSERT INTO users (email, hashed_password) VALUES ($1, $2) ON CONFLICT (email) DO NOTHING`,
+		email, hashedPassword)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserExists
+	}
+	return nil
+}
+
+func (pp *pgStorage) GetUserHashedPassword(email string) (hashedPassword []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pp.pool.QueryRow(ctx, `SELECT hashed_password FROM users WHERE email = $1`, email).Scan(&hashedPassword)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return hashedPassword, err
+}
+
+// UpdateUserPassword заменяет хеш пароля пользователя
+func (pp *pgStorage) UpdateUserPassword(email string, hashedPassword []byte) error {
+	return pp.updateUser(`UPDATE users SET hashed_password = $2 WHERE email = $1`, email, hashedPassword)
+}
+
+// DeleteUser удаляет пользователя, остальные его данные удаляются каскадно.
+// Счетчик попыток входа по email не связан с пользователем внешним ключом и удаляется отдельно
+func (pp *pgStorage) DeleteUser(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		tag, errTx := tx.Exec(ctx, `DELETE FROM users WHERE email = $1`, email)
+		if errTx != nil {
+			return errTx
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUserNotFound
+		}
+		_, errTx = tx.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, loginEmailKey(email))
+		return errTx
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
+
+func (pp *pgStorage) GetLastSyncTime(email string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var lastSync *time.Time
+	err := pp.pool.QueryRow(ctx, `SELECT last_sync FROM users WHERE email = $1`, email).Scan(&lastSync)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && lastSync == nil {
+		return time.Time{}, ErrNoSyncTime
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *lastSync, nil
+}
+
+func (pp *pgStorage) SetLastSyncTime(email string, lastSync time.Time) error {
+	return pp.updateUser(`UPDATE users SET last_sync = $2 WHERE email = $1`, email, lastSync)
+}
+
+func (pp *pgStorage) SetData(email string, data []byte) error {
+	return pp.updateUser(`UPDATE users SET data = $2 WHERE email = $1`, email, data)
+}
+
+func (pp *pgStorage) GetData(email string) (data []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pp.pool.QueryRow(ctx, `SELECT data FROM users WHERE email = $1`, email).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && len(data) == 0 {
+		return nil, ErrNoData
+	}
+	return data, err
+}
+
+// updateUser выполняет UPDATE строки пользователя email ($1)
+func (pp *pgStorage) updateUser(sql string, email string, value any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	tag, err := pp.pool.Exec(ctx, sql, email, value)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// PushRecords сохраняет записи по тем же правилам, что и хранилище bbolt. Строка пользователя блокируется
+// до конца транзакции, поэтому ревизии остаются последовательными при отправке через разные реплики
+func (pp *pgStorage) PushRecords(email string, deviceID string, records []domain.Record, keep int) (accepted []domain.Record, conflicts []domain.Record, revision int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		errTx := tx.QueryRow(ctx, `SELECT revision FROM users WHERE email = $1 FOR UPDATE`, email).Scan(&revision)
+		if errors.Is(errTx, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if errTx != nil {
+			return errTx
+		}
+		refs := chunkRefs{}
+		for _, r := range records {
+			current := domain.Record{ID: r.ID}
+			errTx = tx.QueryRow(ctx, `SELECT revision, deleted, data, chunks FROM records WHERE email = $1 AND id = $2`, email, r.ID).
+				Scan(&current.Revision, &current.Deleted, &current.Data, &current.Chunks)
+			if errTx != nil && !errors.Is(errTx, pgx.ErrNoRows) {
+				return errTx
+			}
+			if errTx == nil && current.Revision != r.Revision {
+				conflicts = append(conflicts, current)
+				continue
+			}
+			exists := errTx == nil
+			if !r.Deleted {
+				if errTx = pp.checkChunks(ctx, tx, email, r.Chunks); errTx != nil {
+					return errTx
+				}
+			}
+			revision++
+			r.Revision = revision
+			if exists && keep > 0 {
+				_, errTx = tx.Exec(ctx, `INSERT INTO record_history (email, superseded_at, id, revision, deleted, data, chunks)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)`, email, revision, current.ID, current.Revision, current.Deleted, current.Data, current.Chunks)
+				if errTx != nil {
+					return errTx
+				}
+			} else if exists {
+				refs.add(current.Chunks, -1)
+			}
+			refs.add(r.Chunks, 1)
+			_, errTx = tx.Exec(ctx, `INSERT INTO records (email, id, revision, deleted, data, chunks) VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (email, id) DO UPDATE SET revision = EXCLUDED.revision, deleted = EXCLUDED.deleted, data = EXCLUDED.data,
+					chunks = EXCLUDED.chunks`,
+				email, r.ID, r.Revision, r.Deleted, r.Data, r.Chunks)
+			if errTx != nil {
+				return errTx
+			}
+			accepted = append(accepted, domain.Record{ID: r.ID, Revision: r.Revision, Deleted: r.Deleted})
+		}
+		if _, errTx = tx.Exec(ctx, `UPDATE users SET revision = $2 WHERE email = $1`, email, revision); errTx != nil {
+			return errTx
+		}
+		if len(accepted) == 0 {
+			return nil
+		}
+		if keep <= 0 {
+			errTx = pp.deleteVersions(ctx, tx, email, refs)
+		} else {
+			errTx = pp.saveVersion(ctx, tx, email, domain.VaultVersion{Number: revision, CreatedAt: time.Now(), DeviceID: deviceID}, keep, refs)
+		}
+		if errTx != nil {
+			return errTx
+		}
+		return pp.applyChunkRefs(ctx, tx, email, refs)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return nil, nil, 0, err
+	}
+	return
+}
+
+// PullRecords возвращает записи пользователя с ревизией больше since и текущую ревизию.
+// Ревизия и записи читаются из одного снимка базы
+func (pp *pgStorage) PullRecords(email string, since int64) (records []domain.Record, revision int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	err = pgx.BeginTxFunc(ctx, pp.pool, opts, func(tx pgx.Tx) error {
+		errTx := tx.QueryRow(ctx, `SELECT revision FROM users WHERE email = $1`, email).Scan(&revision)
+		if errors.Is(errTx, pgx.ErrNoRows) {
+			return nil
+		}
+		if errTx != nil {
+			return errTx
+		}
+		rows, errTx := tx.Query(ctx, `SELECT id, revision, deleted, data, chunks FROM records WHERE email = $1 AND revision > $2 ORDER BY id`,
+			email, since)
+		if errTx != nil {
+			return errTx
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r domain.Record
+			if errTx = rows.Scan(&r.ID, &r.Revision, &r.Deleted, &r.Data, &r.Chunks); errTx != nil {
+				return errTx
+			}
+			records = append(records, r)
+		}
+		return rows.Err()
+	})
+	return
+}
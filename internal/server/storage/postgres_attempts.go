@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ReserveLoginAttempt засчитывает попытку входа по ключу как неудачу, если allow разрешает ее,
+// см. storage.ReserveLoginAttempt. Строка счетчика блокируется до конца транзакции
+func (pp *pgStorage) ReserveLoginAttempt(key string, at time.Time, resetBefore time.Time, allow func(domain.LoginAttempts) bool) (attempts domain.LoginAttempts, reserved bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		_, errTx := tx.Exec(ctx, `INSERT INTO login_attempts (key, failures, last_failure) VALUES ($1, 0, $2)
+			ON CONFLICT (key) DO NOTHING`, key, at)
+		if errTx != nil {
+			return errTx
+		}
+		errTx = tx.QueryRow(ctx, `SELECT key, failures, last_failure FROM login_attempts WHERE key = $1 FOR UPDATE`, key).
+			Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailure)
+		if errTx != nil {
+			return errTx
+		}
+		if attempts.LastFailure.Before(resetBefore) {
+			attempts.Failures = 0
+		}
+		if !allow(attempts) {
+			return nil
+		}
+		reserved = true
+		attempts.Failures++
+		attempts.LastFailure = at
+		_, errTx = tx.Exec(ctx, `UPDATE login_attempts SET failures = $2, last_failure = $3 WHERE key = $1`,
+			key, attempts.Failures, attempts.LastFailure)
+		return errTx
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// ReleaseLoginAttempt отменяет попытку, засчитанную ReserveLoginAttempt, после успешного входа
+func (pp *pgStorage) ReleaseLoginAttempt(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		_, errTx := tx.Exec(ctx, `UPDATE login_attempts SET failures = failures - 1 WHERE key = $1`, key)
+		if errTx != nil {
+			return errTx
+		}
+		_, errTx = tx.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1 AND failures <= 0`, key)
+		return errTx
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
+
+// GetLoginAttempts возвращает неудачные попытки входа по ключу, без попыток - нулевой счетчик
+func (pp *pgStorage) GetLoginAttempts(key string) (attempts domain.LoginAttempts, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := pp.pool.Query(ctx, `SELECT key, failures, last_failure FROM login_attempts WHERE key = $1`, key)
+	if err != nil {
+		return domain.LoginAttempts{}, err
+	}
+	list, err := pgx.CollectRows(rows, scanLoginAttempts)
+	if err != nil || len(list) == 0 {
+		return domain.LoginAttempts{Key: key}, err
+	}
+	return list[0], nil
+}
+
+// ListLoginAttempts возвращает все счетчики неудачных попыток входа
+func (pp *pgStorage) ListLoginAttempts() ([]domain.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := pp.pool.Query(ctx, `SELECT key, failures, last_failure FROM login_attempts ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanLoginAttempts)
+}
+
+// DeleteLoginAttempts сбрасывает счетчик неудачных попыток входа по ключу
+func (pp *pgStorage) DeleteLoginAttempts(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	tag, err := pp.pool.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoLoginAttempts
+	}
+	return nil
+}
+
+func scanLoginAttempts(row pgx.CollectableRow) (attempts domain.LoginAttempts, err error) {
+	err = row.Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailure)
+	return
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// SaveChunk сохраняет чанк пользователя. Чанк с тем же id уже содержит те же данные и не перезаписывается.
+// Новый чанк получает счетчик ссылок, см. chunkRefs
+func (pp *pgStorage) SaveChunk(email string, id string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	_, err := pp.pool.Exec(ctx, `INSERT INTO chunks (email, id, data, refs) VALUES ($1, $2, $3, 0) ON CONFLICT (email, id) DO NOTHING`,
+		email, id, data)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
+
+// GetChunk возвращает чанк пользователя
+func (pp *pgStorage) GetChunk(email string, id string) (data []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pp.pool.QueryRow(ctx, `SELECT data FROM chunks WHERE email = $1 AND id = $2`, email, id).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrChunkNotFound
+	}
+	return
+}
+
+// MissingChunks возвращает id чанков, которых нет у пользователя, в порядке ids
+func (pp *pgStorage) MissingChunks(email string, ids []string) (missing []string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := pp.pool.Query(ctx, `SELECT id FROM chunks WHERE email = $1 AND id = ANY($2)`, email, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	present := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		present[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if !present[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// applyChunkRefs сохраняет изменения числа ссылок на чанки и удаляет чанки без ссылок, см. chunkRefs.apply
+func (pp *pgStorage) applyChunkRefs(ctx context.Context, tx pgx.Tx, email string, refs chunkRefs) error {
+	ids := make([]string, 0, len(refs))
+	deltas := make([]int64, 0, len(refs))
+	for id, delta := range refs {
+		if delta != 0 {
+			ids = append(ids, id)
+			deltas = append(deltas, int64(delta))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, errTx := tx.Exec(ctx, `UPDATE chunks c SET refs = c.refs + d.delta FROM unnest($2::text[], $3::bigint[]) AS d (id, delta)
+		WHERE c.email = $1 AND c.id = d.id AND c.refs IS NOT NULL`, email, ids, deltas)
+	if errTx != nil {
+		return errTx
+	}
+	_, errTx = tx.Exec(ctx, `DELETE FROM chunks WHERE email = $1 AND id = ANY($2) AND refs <= 0`, email, ids)
+	return errTx
+}
+
+// checkChunks возвращает ErrMissingChunks, если каких-то чанков из ids нет у пользователя
+func (pp *pgStorage) checkChunks(ctx context.Context, tx pgx.Tx, email string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var missing bool
+	errTx := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM unnest($2::text[]) AS i (id)
+		WHERE NOT EXISTS (SELECT 1 FROM chunks c WHERE c.email = $1 AND c.id = i.id))`, email, ids).Scan(&missing)
+	if errTx != nil {
+		return errTx
+	}
+	if missing {
+		return ErrMissingChunks
+	}
+	return nil
+}
+
+// addChunkRefs добавляет в refs с множителем delta списки чанков из rows - результата запроса одного столбца chunks
+func addChunkRefs(rows pgx.Rows, refs chunkRefs, delta int) error {
+	lists, err := pgx.CollectRows(rows, pgx.RowTo[[]string])
+	if err != nil {
+		return err
+	}
+	for _, ids := range lists {
+		refs.add(ids, delta)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// SaveDevice сохраняет устройство пользователя
+func (pp *pgStorage) SaveDevice(email string, device domain.Device) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	_, err := pp.pool.Exec(ctx, `INSERT INTO devices (email, id, name, created_at, last_seen, revoked)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (email, id) DO UPDATE SET name = EXCLUDED.name, created_at = EXCLUDED.created_at,
+			last_seen = EXCLUDED.last_seen, revoked = EXCLUDED.revoked`,
+		email, device.ID, device.Name, device.CreatedAt, device.LastSeen, device.Revoked)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
+
+// TouchDevice обновляет время последнего запроса устройства, не меняя остальные поля
+func (pp *pgStorage) TouchDevice(email string, id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	tag, err := pp.pool.Exec(ctx, `UPDATE devices SET last_seen = $3 WHERE email = $1 AND id = $2`, email, id, at)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (pp *pgStorage) GetDevice(email string, id string) (device domain.Device, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pp.pool.QueryRow(ctx, `SELECT id, name, created_at, last_seen, revoked FROM devices WHERE email = $1 AND id = $2`, email, id).
+		Scan(&device.ID, &device.Name, &device.CreatedAt, &device.LastSeen, &device.Revoked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Device{}, ErrDeviceNotFound
+	}
+	return
+}
+
+// ListDevices возвращает устройства пользователя в порядке регистрации
+func (pp *pgStorage) ListDevices(email string) (devices []domain.Device, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := pp.pool.Query(ctx, `SELECT id, name, created_at, last_seen, revoked FROM devices WHERE email = $1 ORDER BY created_at`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var device domain.Device
+		if err = rows.Scan(&device.ID, &device.Name, &device.CreatedAt, &device.LastSeen, &device.Revoked); err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// RekeyRecords заменяет все записи пользователя записями, зашифрованными новым ключом, см. storage.RekeyRecords
+func (pp *pgStorage) RekeyRecords(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams, keep int) (accepted []domain.Record, newRevision int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		errTx := tx.QueryRow(ctx, `SELECT revision FROM users WHERE email = $1 FOR UPDATE`, email).Scan(&newRevision)
+		if errors.Is(errTx, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if errTx != nil {
+			return errTx
+		}
+		if newRevision != revision {
+			return ErrRevisionMismatch
+		}
+		// все текущие значения заменяются, их ссылки на чанки переходят к новым записям
+		refs := chunkRefs{}
+		rows, errTx := tx.Query(ctx, `SELECT chunks FROM records WHERE email = $1 AND chunks IS NOT NULL`, email)
+		if errTx != nil {
+			return errTx
+		}
+		if errTx = addChunkRefs(rows, refs, -1); errTx != nil {
+			return errTx
+		}
+		ids := make([]string, 0, len(records))
+		for _, r := range records {
+			if errTx = pp.checkChunks(ctx, tx, email, r.Chunks); errTx != nil {
+				return errTx
+			}
+			refs.add(r.Chunks, 1)
+			ids = append(ids, r.ID)
+		}
+		rows, errTx = tx.Query(ctx, `SELECT id FROM records WHERE email = $1 AND NOT deleted AND id <> ALL($2) ORDER BY id`,
+			email, ids)
+		if errTx != nil {
+			return errTx
+		}
+		removed, errTx := pgx.CollectRows(rows, pgx.RowTo[string])
+		if errTx != nil {
+			return errTx
+		}
+		for _, id := range removed {
+			newRevision++
+			_, errTx = tx.Exec(ctx, `UPDATE records SET revision = $3, deleted = TRUE, data = NULL, chunks = NULL WHERE email = $1 AND id = $2`,
+				email, id, newRevision)
+			if errTx != nil {
+				return errTx
+			}
+		}
+		for _, r := range records {
+			newRevision++
+			_, errTx = tx.Exec(ctx, `INSERT INTO records (email, id, revision, deleted, data, chunks) VALUES ($1, $2, $3, FALSE, $4, $5)
+				ON CONFLICT (email, id) DO UPDATE SET revision = EXCLUDED.revision, deleted = FALSE, data = EXCLUDED.data,
+					chunks = EXCLUDED.chunks`,
+				email, r.ID, newRevision, r.Data, r.Chunks)
+			if errTx != nil {
+				return errTx
+			}
+			accepted = append(accepted, domain.Record{ID: r.ID, Revision: newRevision})
+		}
+		if errTx = pp.deleteVersions(ctx, tx, email, refs); errTx != nil {
+			return errTx
+		}
+		_, errTx = tx.Exec(ctx, `UPDATE users SET revision = $2, record_salt = $3, key_check = $4 WHERE email = $1`,
+			email, newRevision, params.Salt, params.KeyCheck)
+		if errTx != nil {
+			return errTx
+		}
+		if keep > 0 {
+			errTx = pp.saveVersion(ctx, tx, email, domain.VaultVersion{Number: newRevision, CreatedAt: time.Now(), DeviceID: deviceID}, keep, refs)
+			if errTx != nil {
+				return errTx
+			}
+		}
+		return pp.applyChunkRefs(ctx, tx, email, refs)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return nil, 0, err
+	}
+	return
+}
+
+// SetRecordSalt сохраняет соль ключа записей пользователя
+func (pp *pgStorage) SetRecordSalt(email string, salt []byte) error {
+	return pp.updateUser(`UPDATE users SET record_salt = $2 WHERE email = $1`, email, salt)
+}
+
+// GetRecordKeyParams возвращает соль ключа записей и значение для его проверки. Пустые поля - nil
+func (pp *pgStorage) GetRecordKeyParams(email string) (params domain.RecordKeyParams, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pp.pool.QueryRow(ctx, `SELECT record_salt, key_check FROM users WHERE email = $1`, email).Scan(&params.Salt, &params.KeyCheck)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.RecordKeyParams{}, nil
+	}
+	if len(params.Salt) == 0 {
+		params.Salt = nil
+	}
+	if len(params.KeyCheck) == 0 {
+		params.KeyCheck = nil
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+// SaveRefreshToken сохраняет refresh token по хешу и удаляет истекшие токены пользователя
+func (pp *pgStorage) SaveRefreshToken(hash string, token domain.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		_, errTx := tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE email = $1 AND expires_at < $2`, token.Email, time.Now())
+		if errTx != nil {
+			return errTx
+		}
+		_, errTx = tx.Exec(ctx, `INSERT INTO refresh_tokens (hash, email, family, device_id, expires_at, used)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (hash) DO UPDATE SET email = EXCLUDED.email, family = EXCLUDED.family,
+				device_id = EXCLUDED.device_id, expires_at = EXCLUDED.expires_at, used = EXCLUDED.used`,
+			hash, token.Email, token.Family, token.DeviceID, token.ExpiresAt, token.Used)
+		return errTx
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
+
+// UseRefreshToken помечает refresh token использованным и возвращает его состояние до этого.
+// Строка токена блокируется, поэтому из двух одновременных обновлений одним токеном второе видит Used.
+// Более старые использованные токены семейства удаляются: для обнаружения повторного использования
+// достаточно последнего
+func (pp *pgStorage) UseRefreshToken(hash string) (token domain.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	err = pgx.BeginFunc(ctx, pp.pool, func(tx pgx.Tx) error {
+		errTx := tx.QueryRow(ctx, `SELECT email, family, device_id, expires_at, used FROM refresh_tokens WHERE hash = $1 FOR UPDATE`, hash).
+			Scan(&token.Email, &token.Family, &token.DeviceID, &token.ExpiresAt, &token.Used)
+		if errors.Is(errTx, pgx.ErrNoRows) {
+			return ErrTokenNotFound
+		}
+		if errTx != nil {
+			return errTx
+		}
+		if token.Used {
+			return nil
+		}
+		_, errTx = tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE email = $1 AND family = $2 AND used`, token.Email, token.Family)
+		if errTx != nil {
+			return errTx
+		}
+		_, errTx = tx.Exec(ctx, `UPDATE refresh_tokens SET used = TRUE WHERE hash = $1`, hash)
+		return errTx
+	})
+	return
+}
+
+// RevokeRefreshTokens удаляет refresh token пользователя из семейства family или все, если family пустое
+func (pp *pgStorage) RevokeRefreshTokens(email string, family string) error {
+	return pp.deleteTokens(`DELETE FROM refresh_tokens WHERE email = $1 AND ($2 = '' OR family = $2)`, email, family)
+}
+
+// RevokeDeviceTokens удаляет все refresh token устройства
+func (pp *pgStorage) RevokeDeviceTokens(email string, deviceID string) error {
+	return pp.deleteTokens(`DELETE FROM refresh_tokens WHERE email = $1 AND device_id = $2`, email, deviceID)
+}
+
+func (pp *pgStorage) deleteTokens(sql string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	_, err := pp.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return err
+}
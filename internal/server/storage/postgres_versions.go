@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/jackc/pgx/v5"
+)
+
+// saveVersion сохраняет описание версии в транзакции изменения записей и удаляет самые старые версии
+// сверх keep вместе с историей, которая нужна только им, см. storage.saveVersion
+func (pp *pgStorage) saveVersion(ctx context.Context, tx pgx.Tx, email string, version domain.VaultVersion, keep int, refs chunkRefs) error {
+	errTx := tx.QueryRow(ctx, `SELECT count(*) FROM records WHERE email = $1 AND NOT deleted`, email).Scan(&version.Records)
+	if errTx != nil {
+		return errTx
+	}
+	_, errTx = tx.Exec(ctx, `INSERT INTO vault_versions (email, number, created_at, device_id, records)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (email, number) DO UPDATE SET created_at = EXCLUDED.created_at, device_id = EXCLUDED.device_id,
+			records = EXCLUDED.records, snapshot = NULL`,
+		email, version.Number, version.CreatedAt, version.DeviceID, version.Records)
+	if errTx != nil {
+		return errTx
+	}
+	_, errTx = tx.Exec(ctx, `DELETE FROM vault_versions WHERE email = $1 AND number <= (
+		SELECT number FROM vault_versions WHERE email = $1 ORDER BY number DESC OFFSET $2 LIMIT 1)`, email, keep)
+	if errTx != nil {
+		return errTx
+	}
+	rows, errTx := tx.Query(ctx, `DELETE FROM record_history WHERE email = $1 AND superseded_at <= (
+		SELECT min(number) FROM vault_versions WHERE email = $1) RETURNING chunks`, email)
+	if errTx != nil {
+		return errTx
+	}
+	return addChunkRefs(rows, refs, -1)
+}
+
+// deleteVersions удаляет все версии пользователя и их историю. Ссылки истории на чанки вычитаются в refs
+func (pp *pgStorage) deleteVersions(ctx context.Context, tx pgx.Tx, email string, refs chunkRefs) error {
+	if _, errTx := tx.Exec(ctx, `DELETE FROM vault_versions WHERE email = $1`, email); errTx != nil {
+		return errTx
+	}
+	rows, errTx := tx.Query(ctx, `DELETE FROM record_history WHERE email = $1 RETURNING chunks`, email)
+	if errTx != nil {
+		return errTx
+	}
+	return addChunkRefs(rows, refs, -1)
+}
+
+// ListVersions возвращает версии пользователя, новые первыми
+func (pp *pgStorage) ListVersions(email string) (versions []domain.VaultVersion, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := pp.pool.Query(ctx, `SELECT number, created_at, device_id, records FROM vault_versions
+		WHERE email = $1 ORDER BY number DESC`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version domain.VaultVersion
+		if err = rows.Scan(&version.Number, &version.CreatedAt, &version.DeviceID, &version.Records); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion возвращает версию и ее записи, упорядоченные по ID. Описание и записи читаются из одного снимка базы
+func (pp *pgStorage) GetVersion(email string, number int64) (version domain.VaultVersion, records []domain.Record, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	opts := pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	err = pgx.BeginTxFunc(ctx, pp.pool, opts, func(tx pgx.Tx) error {
+		var snapshot []byte
+		errTx := tx.QueryRow(ctx, `SELECT number, created_at, device_id, records, snapshot FROM vault_versions
+			WHERE email = $1 AND number = $2`, email, number).
+			Scan(&version.Number, &version.CreatedAt, &version.DeviceID, &version.Records, &snapshot)
+		if errors.Is(errTx, pgx.ErrNoRows) {
+			return ErrVersionNotFound
+		}
+		if errTx != nil {
+			return errTx
+		}
+		if snapshot != nil {
+			return gob.NewDecoder(bytes.NewReader(snapshot)).Decode(&records)
+		}
+		// запись, измененная после версии, была в версии в значении, замененном первым после нее
+		rows, errTx := tx.Query(ctx, `SELECT id, revision, data, chunks FROM records
+			WHERE email = $1 AND revision <= $2 AND NOT deleted
+			UNION ALL
+			SELECT id, revision, data, chunks FROM (
+				SELECT DISTINCT ON (id) id, revision, deleted, data, chunks FROM record_history
+				WHERE email = $1 AND superseded_at > $2 ORDER BY id, superseded_at) h
+			WHERE revision <= $2 AND NOT deleted
+			ORDER BY id`, email, number)
+		if errTx != nil {
+			return errTx
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r domain.Record
+			if errTx = rows.Scan(&r.ID, &r.Revision, &r.Data, &r.Chunks); errTx != nil {
+				return errTx
+			}
+			records = append(records, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return domain.VaultVersion{}, nil, err
+	}
+	return
+}
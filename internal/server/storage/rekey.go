@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+	"time"
+)
+
+var ErrRevisionMismatch = errors.New("vault changed on server: sync and try again")
+
+// RekeyRecords заменяет все записи пользователя записями, зашифрованными новым ключом, если ревизия сервера
+// равна revision. Записи, которых нет в records, удаляются, версии хранилища тоже - они зашифрованы старым ключом
+// и новым мастер-паролем не открываются. Если keep больше 0, история начинается с версии после смены ключа.
+// Соль и keyCheck нового ключа сохраняются для других устройств
+func (pp *storage) RekeyRecords(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams, keep int) (accepted []domain.Record, newRevision int64, err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b, errCreate := tx.Bucket([]byte("records")).CreateBucketIfNotExists([]byte(email))
+		if errCreate != nil {
+			return errCreate
+		}
+		if int64(b.Sequence()) != revision {
+			return ErrRevisionMismatch
+		}
+		kept := make(map[string]bool, len(records))
+		for _, r := range records {
+			kept[r.ID] = true
+		}
+		// все текущие значения заменяются, их ссылки на чанки переходят к новым записям
+		refs := chunkRefs{}
+		var removed []domain.Record
+		errForEach := b.ForEach(func(k, v []byte) error {
+			r, ok := decodeRecord(string(k), v)
+			if !ok {
+				return nil
+			}
+			refs.add(r.Chunks, -1)
+			if !r.Deleted && !kept[r.ID] {
+				removed = append(removed, domain.Record{ID: r.ID, Deleted: true})
+			}
+			return nil
+		})
+		if errForEach != nil {
+			return errForEach
+		}
+		for _, r := range records {
+			if errCheck := checkChunks(tx, email, r.Chunks); errCheck != nil {
+				return errCheck
+			}
+			refs.add(r.Chunks, 1)
+		}
+		for _, r := range append(removed, records...) {
+			seq, errSeq := b.NextSequence()
+			if errSeq != nil {
+				return errSeq
+			}
+			r.Revision = int64(seq)
+			if errPut := b.Put([]byte(r.ID), encodeRecord(r)); errPut != nil {
+				return errPut
+			}
+			if !r.Deleted {
+				accepted = append(accepted, domain.Record{ID: r.ID, Revision: r.Revision})
+			}
+		}
+		newRevision = int64(b.Sequence())
+		if errDelete := deleteVersions(tx, email, refs); errDelete != nil {
+			return errDelete
+		}
+		if keep > 0 {
+			version := domain.VaultVersion{Number: newRevision, CreatedAt: time.Now(), DeviceID: deviceID, Records: countRecords(b)}
+			if errSave := saveVersion(tx, email, version, keep, refs); errSave != nil {
+				return errSave
+			}
+		}
+		if errApply := refs.apply(tx, email); errApply != nil {
+			return errApply
+		}
+		if errPut := tx.Bucket([]byte("recordsalts")).Put([]byte(email), params.Salt); errPut != nil {
+			return errPut
+		}
+		return tx.Bucket([]byte("keychecks")).Put([]byte(email), params.KeyCheck)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return nil, 0, err
+	}
+	return
+}
+
+// SetRecordSalt сохраняет соль ключа записей пользователя
+func (pp *storage) SetRecordSalt(email string, salt []byte) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		if len(tx.Bucket([]byte("users")).Get([]byte(email))) == 0 {
+			return ErrUserNotFound
+		}
+		return tx.Bucket([]byte("recordsalts")).Put([]byte(email), salt)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// GetRecordKeyParams возвращает соль ключа записей и значение для его проверки. Пустые поля - nil
+func (pp *storage) GetRecordKeyParams(email string) (params domain.RecordKeyParams, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket([]byte("recordsalts")).Get([]byte(email)); len(v) > 0 {
+			params.Salt = append([]byte(nil), v...)
+		}
+		if v := tx.Bucket([]byte("keychecks")).Get([]byte(email)); len(v) > 0 {
+			params.KeyCheck = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return
+}
@@ -3,18 +3,27 @@ package storage
 import (
 	"encoding/binary"
 	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"go.etcd.io/bbolt"
 	"go.uber.org/zap"
 	"time"
 )
 
+var (
+	ErrUserExists   = errors.New("user exists")
+	ErrUserNotFound = errors.New("user no exists")
+	ErrNoSyncTime   = errors.New("no last sync time")
+	ErrNoData       = errors.New("no data")
+)
+
 type storage struct {
 	db     *bbolt.DB
 	logger *zap.Logger
 }
 
 func New(path string, lg *zap.Logger) (*storage, error) {
-	db, err := bbolt.Open(path, 0600, nil)
+	// файл, открытый запущенным сервером, заблокирован: не ждать его бесконечно
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
 	if err != nil {
 		lg.Debug(err.Error())
 		return nil, err
@@ -32,6 +41,57 @@ func New(path string, lg *zap.Logger) (*storage, error) {
 		if errCreate != nil {
 			return errCreate
 		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("records"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("tokens"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("tokenindex"))
+		if errCreate != nil {
+			return errCreate
+		}
+		if errCreate = migrateTokens(tx); errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("devices"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("chunks"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("versions"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("snapshots"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("history"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("keychecks"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("recordsalts"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("attempts"))
+		if errCreate != nil {
+			return errCreate
+		}
+		_, errCreate = tx.CreateBucketIfNotExists([]byte("chunkrefs"))
+		if errCreate != nil {
+			return errCreate
+		}
 		return nil
 	})
 	if err != nil {
@@ -49,7 +109,7 @@ func (pp *storage) RegisterUser(email string, hashedPassword []byte) (err error)
 		b := tx.Bucket([]byte("users"))
 		user := b.Get([]byte(email))
 		if len(user) > 0 {
-			return errors.New("user exists")
+			return ErrUserExists
 		}
 		return err
 	},
@@ -84,7 +144,7 @@ func (pp *storage) GetUserHashedPassword(email string) (hashedPassword []byte, e
 		b := tx.Bucket([]byte("users"))
 		hashedPassword = b.Get([]byte(email))
 		if len(hashedPassword) == 0 {
-			return errors.New("user no exists")
+			return ErrUserNotFound
 		}
 		return nil
 	},
@@ -92,13 +152,63 @@ func (pp *storage) GetUserHashedPassword(email string) (hashedPassword []byte, e
 	return hashedPassword, err
 }
 
+// UpdateUserPassword заменяет хеш пароля пользователя
+func (pp *storage) UpdateUserPassword(email string, hashedPassword []byte) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("users"))
+		if len(b.Get([]byte(email))) == 0 {
+			return ErrUserNotFound
+		}
+		return b.Put([]byte(email), hashedPassword)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// DeleteUser удаляет пользователя и все его данные: записи, чанки, версии, устройства, refresh token
+// и счетчик попыток входа по email
+func (pp *storage) DeleteUser(email string) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		users := tx.Bucket([]byte("users"))
+		if len(users.Get([]byte(email))) == 0 {
+			return ErrUserNotFound
+		}
+		for _, name := range []string{"users", "sync", "data", "keychecks", "recordsalts"} {
+			if errDelete := tx.Bucket([]byte(name)).Delete([]byte(email)); errDelete != nil {
+				return errDelete
+			}
+		}
+		for _, name := range []string{"records", "devices", "chunks", "chunkrefs", "versions", "snapshots", "history"} {
+			errDelete := tx.Bucket([]byte(name)).DeleteBucket([]byte(email))
+			if errDelete != nil && !errors.Is(errDelete, bbolt.ErrBucketNotFound) {
+				return errDelete
+			}
+		}
+		errDelete := deleteTokens(tx, email, func(domain.RefreshToken) bool { return true })
+		if errDelete != nil {
+			return errDelete
+		}
+		errDelete = tx.Bucket([]byte("tokens")).DeleteBucket([]byte(email))
+		if errDelete != nil && !errors.Is(errDelete, bbolt.ErrBucketNotFound) {
+			return errDelete
+		}
+		return tx.Bucket([]byte("attempts")).Delete([]byte(loginEmailKey(email)))
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
 func (pp *storage) GetLastSyncTime(email string) (lastSync time.Time, err error) {
 	lastSync = time.Time{}
 	err = pp.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte("sync"))
 		bytesLastSync := b.Get([]byte(email))
 		if len(bytesLastSync) == 0 {
-			return errors.New("no last sync time")
+			return ErrNoSyncTime
 		}
 		lastSync = time.Unix(int64(binary.BigEndian.Uint64(bytesLastSync)), 0)
 		return nil
@@ -140,10 +250,150 @@ func (pp *storage) GetData(email string) (data []byte, err error) {
 		b := tx.Bucket([]byte("data"))
 		data = b.Get([]byte(email))
 		if len(data) == 0 {
-			return errors.New("no data")
+			return ErrNoData
 		}
 		return err
 	},
 	)
 	return
 }
+
+// PushRecords сохраняет записи в бакет пользователя. Запись принимается, только если ее ревизия
+// совпадает с текущей ревизией на сервере, иначе серверная версия возвращается в conflicts.
+// Каждой принятой записи присваивается новая ревизия из счетчика бакета пользователя.
+// Для незарегистрированного пользователя возвращается ErrUserNotFound, как и в PostgreSQL.
+// Если keep больше 0, в той же транзакции сохраняется версия хранилища от устройства deviceID
+// и удаляются версии сверх keep, иначе история хранилища удаляется
+func (pp *storage) PushRecords(email string, deviceID string, records []domain.Record, keep int) (accepted []domain.Record, conflicts []domain.Record, revision int64, err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		if len(tx.Bucket([]byte("users")).Get([]byte(email))) == 0 {
+			return ErrUserNotFound
+		}
+		b, errCreate := tx.Bucket([]byte("records")).CreateBucketIfNotExists([]byte(email))
+		if errCreate != nil {
+			return errCreate
+		}
+		refs := chunkRefs{}
+		for _, r := range records {
+			current, ok := decodeRecord(r.ID, b.Get([]byte(r.ID)))
+			if ok && current.Revision != r.Revision {
+				conflicts = append(conflicts, current)
+				continue
+			}
+			if !r.Deleted {
+				if errCheck := checkChunks(tx, email, r.Chunks); errCheck != nil {
+					return errCheck
+				}
+			}
+			seq, errSeq := b.NextSequence()
+			if errSeq != nil {
+				return errSeq
+			}
+			r.Revision = int64(seq)
+			if ok && keep > 0 {
+				// прежнее значение переходит в историю вместе со ссылками на чанки
+				if errHistory := saveHistory(tx, email, current, r.Revision); errHistory != nil {
+					return errHistory
+				}
+			} else if ok {
+				refs.add(current.Chunks, -1)
+			}
+			refs.add(r.Chunks, 1)
+			if errPut := b.Put([]byte(r.ID), encodeRecord(r)); errPut != nil {
+				return errPut
+			}
+			accepted = append(accepted, domain.Record{ID: r.ID, Revision: r.Revision, Deleted: r.Deleted})
+		}
+		revision = int64(b.Sequence())
+		if len(accepted) == 0 {
+			return nil
+		}
+		if keep <= 0 {
+			if errDelete := deleteVersions(tx, email, refs); errDelete != nil {
+				return errDelete
+			}
+			return refs.apply(tx, email)
+		}
+		version := domain.VaultVersion{Number: revision, CreatedAt: time.Now(), DeviceID: deviceID, Records: countRecords(b)}
+		if errSave := saveVersion(tx, email, version, keep, refs); errSave != nil {
+			return errSave
+		}
+		return refs.apply(tx, email)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+		return nil, nil, 0, err
+	}
+	return
+}
+
+// PullRecords возвращает записи пользователя с ревизией больше since и текущую ревизию
+func (pp *storage) PullRecords(email string, since int64) (records []domain.Record, revision int64, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("records")).Bucket([]byte(email))
+		if b == nil {
+			return nil
+		}
+		revision = int64(b.Sequence())
+		return b.ForEach(func(k, v []byte) error {
+			r, ok := decodeRecord(string(k), v)
+			if ok && r.Revision > since {
+				records = append(records, r)
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// Флаги записи
+const (
+	recordDeleted = 1
+	// recordChunks за флагами следует список id чанков: число id (2 байта) и каждый id с длиной (1 байт)
+	recordChunks = 2
+)
+
+// encodeRecord кодирует запись: 8 байт ревизии, 1 байт флагов, id чанков, если есть, данные
+func encodeRecord(r domain.Record) []byte {
+	v := make([]byte, 9, 9+len(r.Data))
+	binary.BigEndian.PutUint64(v, uint64(r.Revision))
+	if r.Deleted {
+		v[8] |= recordDeleted
+	}
+	if len(r.Chunks) > 0 {
+		v[8] |= recordChunks
+		v = binary.BigEndian.AppendUint16(v, uint16(len(r.Chunks)))
+		for _, id := range r.Chunks {
+			v = append(append(v, byte(len(id))), id...)
+		}
+	}
+	return append(v, r.Data...)
+}
+
+func decodeRecord(id string, v []byte) (domain.Record, bool) {
+	if len(v) < 9 {
+		return domain.Record{}, false
+	}
+	r := domain.Record{
+		ID:       id,
+		Revision: int64(binary.BigEndian.Uint64(v[:8])),
+		Deleted:  v[8]&recordDeleted != 0,
+	}
+	data := v[9:]
+	if v[8]&recordChunks != 0 {
+		if len(data) < 2 {
+			return domain.Record{}, false
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		for i := 0; i < n; i++ {
+			if len(data) < 1 || len(data) < 1+int(data[0]) {
+				return domain.Record{}, false
+			}
+			r.Chunks = append(r.Chunks, string(data[1:1+int(data[0])]))
+			data = data[1+int(data[0]):]
+		}
+	}
+	r.Data = append([]byte(nil), data...)
+	return r, true
+}
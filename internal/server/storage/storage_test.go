@@ -0,0 +1,640 @@
+package storage
+
+import (
+	"context"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backend методы хранилища, которые реализуют bbolt и PostgreSQL
+type backend interface {
+	RegisterUser(email string, hashedPassword []byte) error
+	GetUserHashedPassword(email string) ([]byte, error)
+	UpdateUserPassword(email string, hashedPassword []byte) error
+	DeleteUser(email string) error
+	GetLastSyncTime(email string) (time.Time, error)
+	SetLastSyncTime(email string, lastSync time.Time) error
+	SetData(email string, data []byte) error
+	GetData(email string) ([]byte, error)
+	PushRecords(email string, deviceID string, records []domain.Record, keep int) ([]domain.Record, []domain.Record, int64, error)
+	PullRecords(email string, since int64) ([]domain.Record, int64, error)
+	SaveRefreshToken(hash string, token domain.RefreshToken) error
+	UseRefreshToken(hash string) (domain.RefreshToken, error)
+	RevokeRefreshTokens(email string, family string) error
+	RevokeDeviceTokens(email string, deviceID string) error
+	SaveDevice(email string, device domain.Device) error
+	GetDevice(email string, id string) (domain.Device, error)
+	TouchDevice(email string, id string, at time.Time) error
+	ListDevices(email string) ([]domain.Device, error)
+	SaveChunk(email string, id string, data []byte) error
+	GetChunk(email string, id string) ([]byte, error)
+	MissingChunks(email string, ids []string) ([]string, error)
+	ListVersions(email string) ([]domain.VaultVersion, error)
+	GetVersion(email string, number int64) (domain.VaultVersion, []domain.Record, error)
+	RekeyRecords(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams, keep int) ([]domain.Record, int64, error)
+	SetRecordSalt(email string, salt []byte) error
+	GetRecordKeyParams(email string) (domain.RecordKeyParams, error)
+	ReserveLoginAttempt(key string, at time.Time, resetBefore time.Time, allow func(domain.LoginAttempts) bool) (domain.LoginAttempts, bool, error)
+	ReleaseLoginAttempt(key string) error
+	GetLoginAttempts(key string) (domain.LoginAttempts, error)
+	ListLoginAttempts() ([]domain.LoginAttempts, error)
+	DeleteLoginAttempts(key string) error
+}
+
+// backends возвращает хранилища для проверки: bbolt всегда, PostgreSQL - если задан GK_TEST_POSTGRES_DSN
+// (например, postgres://postgres@localhost:5432/gk_test). Таблицы тестовой базы очищаются.
+// В CI (.github/workflows/test.yml) DSN задан всегда, и без него тест падает, а не пропускает PostgreSQL
+func backends(t *testing.T) map[string]func(t *testing.T) backend {
+	lg, _ := logger.New(true)
+	b := map[string]func(t *testing.T) backend{
+		"bolt": func(t *testing.T) backend {
+			s, err := New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+			require.NoError(t, err)
+			return s
+		},
+	}
+	dsn := os.Getenv("GK_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("GK_TEST_POSTGRES_DSN is required in CI")
+		}
+		t.Log("GK_TEST_POSTGRES_DSN is not set, postgres storage is not tested")
+		return b
+	}
+	b["postgres"] = func(t *testing.T) backend {
+		s, err := NewPostgres(dsn, lg)
+		require.NoError(t, err)
+		t.Cleanup(s.Close)
+		_, err = s.pool.Exec(context.Background(), `TRUNCATE users, login_attempts CASCADE`)
+		require.NoError(t, err)
+		return s
+	}
+	return b
+}
+
+func TestStorage(t *testing.T) {
+	for name, newBackend := range backends(t) {
+		newBackend := newBackend
+		t.Run(name, func(t *testing.T) {
+			t.Run("users", func(t *testing.T) { testUsers(t, newBackend(t)) })
+			t.Run("records", func(t *testing.T) { testRecords(t, newBackend(t)) })
+			t.Run("tokens", func(t *testing.T) { testTokens(t, newBackend(t)) })
+			t.Run("devices", func(t *testing.T) { testDevices(t, newBackend(t)) })
+			t.Run("chunks", func(t *testing.T) { testChunks(t, newBackend(t)) })
+			t.Run("chunk refs", func(t *testing.T) { testChunkRefs(t, newBackend(t)) })
+			t.Run("versions", func(t *testing.T) { testVersions(t, newBackend(t)) })
+			t.Run("rekey", func(t *testing.T) { testRekey(t, newBackend(t)) })
+			t.Run("delete user", func(t *testing.T) { testDeleteUser(t, newBackend(t)) })
+			t.Run("login attempts", func(t *testing.T) { testLoginAttempts(t, newBackend(t)) })
+		})
+	}
+}
+
+const email = "test@test.ts"
+
+func testUsers(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	require.ErrorIs(t, s.RegisterUser(email, []byte("other")), ErrUserExists)
+	hash, err := s.GetUserHashedPassword(email)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hash"), hash)
+	_, err = s.GetUserHashedPassword("unknown@test.ts")
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = s.GetLastSyncTime(email)
+	require.ErrorIs(t, err, ErrNoSyncTime)
+	now := time.Now()
+	require.NoError(t, s.SetLastSyncTime(email, now))
+	lastSync, err := s.GetLastSyncTime(email)
+	require.NoError(t, err)
+	require.Equal(t, now.Unix(), lastSync.Unix())
+
+	_, err = s.GetData(email)
+	require.ErrorIs(t, err, ErrNoData)
+	require.NoError(t, s.SetData(email, []byte("data")))
+	data, err := s.GetData(email)
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), data)
+}
+
+func testRecords(t *testing.T, s backend) {
+	_, _, _, err := s.PushRecords(email, "d1", []domain.Record{{ID: "a", Data: []byte("a0")}}, 0)
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	records, revision, err := s.PullRecords(email, 0)
+	require.NoError(t, err)
+	require.Empty(t, records)
+	require.Zero(t, revision)
+
+	accepted, conflicts, revision, err := s.PushRecords(email, "d1", []domain.Record{{ID: "b", Data: []byte("b1")}, {ID: "a", Data: []byte("a1")}}, 0)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+	require.Equal(t, []domain.Record{{ID: "b", Revision: 1}, {ID: "a", Revision: 2}}, accepted)
+	require.Equal(t, int64(2), revision)
+
+	// запись с устаревшей ревизией не принимается, возвращается серверная версия
+	accepted, conflicts, revision, err = s.PushRecords(email, "d1", []domain.Record{
+		{ID: "a", Revision: 0, Data: []byte("a2")},
+		{ID: "b", Revision: 1, Deleted: true},
+	}, 0)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Record{{ID: "a", Revision: 2, Data: []byte("a1")}}, conflicts)
+	require.Equal(t, []domain.Record{{ID: "b", Revision: 3, Deleted: true}}, accepted)
+	require.Equal(t, int64(3), revision)
+
+	records, revision, err = s.PullRecords(email, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), revision)
+	require.Len(t, records, 2)
+	require.Equal(t, domain.Record{ID: "a", Revision: 2, Data: []byte("a1")}, records[0])
+	require.Equal(t, "b", records[1].ID)
+	require.True(t, records[1].Deleted)
+
+	records, _, err = s.PullRecords(email, 2)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "b", records[0].ID)
+}
+
+func testTokens(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	token := domain.RefreshToken{Email: email, Family: "f1", DeviceID: "d1", ExpiresAt: expiresAt}
+	require.NoError(t, s.SaveRefreshToken("expired", domain.RefreshToken{Email: email, Family: "f0", ExpiresAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, s.SaveRefreshToken("t1", token))
+	// истекшие токены удаляются при сохранении нового
+	_, err := s.UseRefreshToken("expired")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+
+	used, err := s.UseRefreshToken("t1")
+	require.NoError(t, err)
+	used.ExpiresAt = used.ExpiresAt.UTC()
+	require.Equal(t, token, used)
+	used, err = s.UseRefreshToken("t1")
+	require.NoError(t, err)
+	require.True(t, used.Used)
+
+	require.NoError(t, s.SaveRefreshToken("t2", domain.RefreshToken{Email: email, Family: "f2", DeviceID: "d1", ExpiresAt: expiresAt}))
+	require.NoError(t, s.SaveRefreshToken("t3", domain.RefreshToken{Email: email, Family: "f3", DeviceID: "d2", ExpiresAt: expiresAt}))
+	require.NoError(t, s.RevokeRefreshTokens(email, "f1"))
+	_, err = s.UseRefreshToken("t1")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	require.NoError(t, s.RevokeDeviceTokens(email, "d1"))
+	_, err = s.UseRefreshToken("t2")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	_, err = s.UseRefreshToken("t3")
+	require.NoError(t, err)
+
+	// после следующей ротации семейства прежний использованный токен удаляется,
+	// последний использованный остается для обнаружения повторного использования
+	require.NoError(t, s.SaveRefreshToken("r1", domain.RefreshToken{Email: email, Family: "f4", DeviceID: "d3", ExpiresAt: expiresAt}))
+	_, err = s.UseRefreshToken("r1")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveRefreshToken("r2", domain.RefreshToken{Email: email, Family: "f4", DeviceID: "d3", ExpiresAt: expiresAt}))
+	_, err = s.UseRefreshToken("r2")
+	require.NoError(t, err)
+	_, err = s.UseRefreshToken("r1")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	used, err = s.UseRefreshToken("r2")
+	require.NoError(t, err)
+	require.True(t, used.Used)
+
+	// отзыв затрагивает только токены пользователя
+	other := "other@test.ts"
+	require.NoError(t, s.RegisterUser(other, []byte("hash")))
+	require.NoError(t, s.SaveRefreshToken("o1", domain.RefreshToken{Email: other, Family: "f1", DeviceID: "d1", ExpiresAt: expiresAt}))
+	require.NoError(t, s.RevokeRefreshTokens(email, ""))
+	_, err = s.UseRefreshToken("t3")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	_, err = s.UseRefreshToken("r2")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	_, err = s.UseRefreshToken("o1")
+	require.NoError(t, err)
+}
+
+func testDevices(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	created := time.Now().Truncate(time.Second).UTC()
+	phone := domain.Device{ID: "phone", Name: "phone", CreatedAt: created.Add(time.Minute), LastSeen: created.Add(time.Minute)}
+	laptop := domain.Device{ID: "laptop", Name: "laptop", CreatedAt: created, LastSeen: created}
+	require.NoError(t, s.SaveDevice(email, phone))
+	require.NoError(t, s.SaveDevice(email, laptop))
+	laptop.Revoked = true
+	require.NoError(t, s.SaveDevice(email, laptop))
+
+	utc := func(d domain.Device) domain.Device {
+		d.CreatedAt, d.LastSeen = d.CreatedAt.UTC(), d.LastSeen.UTC()
+		return d
+	}
+	device, err := s.GetDevice(email, "laptop")
+	require.NoError(t, err)
+	require.Equal(t, laptop, utc(device))
+	_, err = s.GetDevice(email, "unknown")
+	require.ErrorIs(t, err, ErrDeviceNotFound)
+
+	devices, err := s.ListDevices(email)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	require.Equal(t, laptop, utc(devices[0]))
+	require.Equal(t, phone, utc(devices[1]))
+
+	// отзыв между проверкой устройства и обновлением времени запроса не теряется
+	device, err = s.GetDevice(email, "phone")
+	require.NoError(t, err)
+	require.False(t, device.Revoked)
+	phone.Revoked = true
+	require.NoError(t, s.SaveDevice(email, phone))
+	seen := created.Add(time.Hour)
+	require.NoError(t, s.TouchDevice(email, "phone", seen))
+	device, err = s.GetDevice(email, "phone")
+	require.NoError(t, err)
+	require.True(t, device.Revoked)
+	require.True(t, seen.Equal(device.LastSeen))
+	require.ErrorIs(t, s.TouchDevice(email, "unknown", seen), ErrDeviceNotFound)
+}
+
+func testChunks(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	missing, err := s.MissingChunks(email, []string{"c1", "c2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c2"}, missing)
+
+	require.NoError(t, s.SaveChunk(email, "c2", []byte("data")))
+	// чанк с тем же id не перезаписывается
+	require.NoError(t, s.SaveChunk(email, "c2", []byte("other")))
+	data, err := s.GetChunk(email, "c2")
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), data)
+	_, err = s.GetChunk(email, "c1")
+	require.ErrorIs(t, err, ErrChunkNotFound)
+
+	missing, err = s.MissingChunks(email, []string{"c1", "c2", "c3"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1", "c3"}, missing)
+}
+
+func testChunkRefs(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	for _, id := range []string{"c1", "c2", "c3"} {
+		require.NoError(t, s.SaveChunk(email, id, []byte(id)))
+	}
+	requireChunks := func(present []string, gone []string) {
+		t.Helper()
+		for _, id := range present {
+			_, err := s.GetChunk(email, id)
+			require.NoError(t, err, id)
+		}
+		for _, id := range gone {
+			_, err := s.GetChunk(email, id)
+			require.ErrorIs(t, err, ErrChunkNotFound, id)
+		}
+	}
+
+	// запись не принимается, пока не загружены ее чанки
+	_, _, _, err := s.PushRecords(email, "dev", []domain.Record{{ID: "a", Chunks: []string{"c4"}}}, 0)
+	require.ErrorIs(t, err, ErrMissingChunks)
+
+	// без истории чанк удаляется вместе с последней ссылающейся на него записью
+	accepted, _, _, err := s.PushRecords(email, "dev", []domain.Record{
+		{ID: "a", Data: []byte("a"), Chunks: []string{"c1"}},
+		{ID: "b", Data: []byte("b"), Chunks: []string{"c1", "c2"}},
+	}, 0)
+	require.NoError(t, err)
+	require.Len(t, accepted, 2)
+	_, _, _, err = s.PushRecords(email, "dev", []domain.Record{{ID: "a", Revision: accepted[0].Revision, Deleted: true}}, 0)
+	require.NoError(t, err)
+	requireChunks([]string{"c1", "c2", "c3"}, nil)
+	_, _, _, err = s.PushRecords(email, "dev", []domain.Record{{ID: "b", Revision: accepted[1].Revision, Data: []byte("b2"), Chunks: []string{"c2", "c3"}}}, 0)
+	require.NoError(t, err)
+	requireChunks([]string{"c2", "c3"}, []string{"c1"})
+
+	// с историей чанк нужен, пока есть версия, в которой запись на него ссылалась
+	_, _, revision, err := s.PushRecords(email, "dev", []domain.Record{{ID: "x", Data: []byte("x")}}, 2)
+	require.NoError(t, err)
+	records, _, err := s.PullRecords(email, 0)
+	require.NoError(t, err)
+	var b domain.Record
+	for _, r := range records {
+		if r.ID == "b" {
+			b = r
+		}
+	}
+	require.Equal(t, []string{"c2", "c3"}, b.Chunks)
+	_, _, _, err = s.PushRecords(email, "dev", []domain.Record{{ID: "b", Revision: b.Revision, Deleted: true}}, 2)
+	require.NoError(t, err)
+	requireChunks([]string{"c2", "c3"}, nil)
+	_, versionRecords, err := s.GetVersion(email, revision)
+	require.NoError(t, err)
+	require.Len(t, versionRecords, 2)
+	require.Equal(t, "b", versionRecords[0].ID)
+	require.Equal(t, []string{"c2", "c3"}, versionRecords[0].Chunks)
+	for _, id := range []string{"y", "z"} {
+		_, _, _, err = s.PushRecords(email, "dev", []domain.Record{{ID: id, Data: []byte(id)}}, 2)
+		require.NoError(t, err)
+	}
+	requireChunks(nil, []string{"c2", "c3"})
+
+	// при смене ключа ссылки прежних значений переходят к новым
+	require.NoError(t, s.SaveChunk(email, "c4", []byte("c4")))
+	require.NoError(t, s.SaveChunk(email, "c5", []byte("c5")))
+	_, _, revision, err = s.PushRecords(email, "dev", []domain.Record{{ID: "d", Data: []byte("d"), Chunks: []string{"c4"}}}, 2)
+	require.NoError(t, err)
+	_, _, err = s.RekeyRecords(email, "dev", revision, []domain.Record{{ID: "d", Data: []byte("d2"), Chunks: []string{"c5"}}}, domain.RecordKeyParams{KeyCheck: []byte("check")}, 2)
+	require.NoError(t, err)
+	requireChunks([]string{"c5"}, []string{"c4"})
+}
+
+func testVersions(t *testing.T, s backend) {
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	versions, err := s.ListVersions(email)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+	_, _, err = s.GetVersion(email, 1)
+	require.ErrorIs(t, err, ErrVersionNotFound)
+
+	// отправки двух устройств чередуются, каждая принятая отправка - версия
+	push := func(deviceID string, records ...domain.Record) {
+		_, _, _, err := s.PushRecords(email, deviceID, records, 4)
+		require.NoError(t, err)
+	}
+	push("laptop", domain.Record{ID: "a", Data: []byte("a1")}, domain.Record{ID: "b", Data: []byte("b1")})
+	push("phone", domain.Record{ID: "c", Data: []byte("c1")})
+	push("laptop", domain.Record{ID: "a", Revision: 1, Data: []byte("a2")})
+	push("phone", domain.Record{ID: "b", Revision: 2, Deleted: true})
+	push("laptop", domain.Record{ID: "a", Revision: 4, Data: []byte("a3")})
+	// конфликт не создает версию
+	_, conflicts, _, err := s.PushRecords(email, "phone", []domain.Record{{ID: "a", Revision: 4, Data: []byte("x")}}, 4)
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	push("phone", domain.Record{ID: "b", Revision: 5, Data: []byte("b2")})
+
+	versions, err = s.ListVersions(email)
+	require.NoError(t, err)
+	require.Len(t, versions, 4)
+	require.Equal(t, int64(7), versions[0].Number)
+	require.Equal(t, "phone", versions[0].DeviceID)
+	require.Equal(t, int64(3), versions[0].Records)
+	require.Equal(t, int64(4), versions[3].Number)
+	require.Equal(t, "laptop", versions[3].DeviceID)
+	require.Equal(t, int64(2), versions[2].Records)
+
+	want := map[int64][]domain.Record{
+		4: {{ID: "a", Revision: 4, Data: []byte("a2")}, {ID: "b", Revision: 2, Data: []byte("b1")}, {ID: "c", Revision: 3, Data: []byte("c1")}},
+		5: {{ID: "a", Revision: 4, Data: []byte("a2")}, {ID: "c", Revision: 3, Data: []byte("c1")}},
+		6: {{ID: "a", Revision: 6, Data: []byte("a3")}, {ID: "c", Revision: 3, Data: []byte("c1")}},
+		7: {{ID: "a", Revision: 6, Data: []byte("a3")}, {ID: "b", Revision: 7, Data: []byte("b2")}, {ID: "c", Revision: 3, Data: []byte("c1")}},
+	}
+	for number, records := range want {
+		version, got, err := s.GetVersion(email, number)
+		require.NoError(t, err)
+		require.Equal(t, number, version.Number)
+		require.Equal(t, records, got, "version %d", number)
+	}
+	// старые версии удаляются
+	_, _, err = s.GetVersion(email, 3)
+	require.ErrorIs(t, err, ErrVersionNotFound)
+
+	// пустое хранилище тоже версия
+	push("laptop", domain.Record{ID: "a", Revision: 6, Deleted: true}, domain.Record{ID: "b", Revision: 7, Deleted: true},
+		domain.Record{ID: "c", Revision: 3, Deleted: true})
+	_, records, err := s.GetVersion(email, 10)
+	require.NoError(t, err)
+	require.Empty(t, records)
+	_, records, err = s.GetVersion(email, 7)
+	require.NoError(t, err)
+	require.Equal(t, want[7], records)
+
+	// без истории версии удаляются
+	_, _, _, err = s.PushRecords(email, "laptop", []domain.Record{{ID: "d", Data: []byte("d1")}}, 0)
+	require.NoError(t, err)
+	versions, err = s.ListVersions(email)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+func testRekey(t *testing.T, s backend) {
+	require.ErrorIs(t, s.SetRecordSalt(email, []byte("salt")), ErrUserNotFound)
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	params, err := s.GetRecordKeyParams(email)
+	require.NoError(t, err)
+	require.Equal(t, domain.RecordKeyParams{}, params)
+	require.NoError(t, s.SetRecordSalt(email, []byte("salt")))
+	_, _, revision, err := s.PushRecords(email, "d1", []domain.Record{{ID: "a", Data: []byte("a1")}, {ID: "b", Data: []byte("b1")}}, 3)
+	require.NoError(t, err)
+	_, _, revision, err = s.PushRecords(email, "d1", []domain.Record{{ID: "b", Revision: 2, Data: []byte("b2")}}, 3)
+	require.NoError(t, err)
+
+	_, _, err = s.RekeyRecords(email, "d1", revision-1, []domain.Record{{ID: "a", Data: []byte("a2")}}, domain.RecordKeyParams{Salt: []byte("salt2"), KeyCheck: []byte("check")}, 3)
+	require.ErrorIs(t, err, ErrRevisionMismatch)
+
+	accepted, newRevision, err := s.RekeyRecords(email, "d1", revision, []domain.Record{{ID: "a", Data: []byte("a2")}}, domain.RecordKeyParams{Salt: []byte("salt2"), KeyCheck: []byte("check")}, 3)
+	require.NoError(t, err)
+	require.Equal(t, revision+2, newRevision)
+	require.Equal(t, []domain.Record{{ID: "a", Revision: newRevision}}, accepted)
+	records, _, err := s.PullRecords(email, revision)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, domain.Record{ID: "a", Revision: newRevision, Data: []byte("a2")}, records[0])
+	require.Equal(t, revision+1, records[1].Revision)
+	require.True(t, records[1].Deleted)
+	params, err = s.GetRecordKeyParams(email)
+	require.NoError(t, err)
+	require.Equal(t, domain.RecordKeyParams{Salt: []byte("salt2"), KeyCheck: []byte("check")}, params)
+	// история, зашифрованная старым ключом, удаляется и начинается с версии после смены ключа
+	versions, err := s.ListVersions(email)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, newRevision, versions[0].Number)
+	require.Equal(t, int64(1), versions[0].Records)
+	_, records, err = s.GetVersion(email, newRevision)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Record{{ID: "a", Revision: newRevision, Data: []byte("a2")}}, records)
+}
+
+func testDeleteUser(t *testing.T, s backend) {
+	require.ErrorIs(t, s.UpdateUserPassword(email, []byte("new")), ErrUserNotFound)
+	require.ErrorIs(t, s.DeleteUser(email), ErrUserNotFound)
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	require.NoError(t, s.UpdateUserPassword(email, []byte("new")))
+	hash, err := s.GetUserHashedPassword(email)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), hash)
+
+	require.NoError(t, s.SetLastSyncTime(email, time.Now()))
+	require.NoError(t, s.SetData(email, []byte("data")))
+	_, _, revision, err := s.PushRecords(email, "d1", []domain.Record{{ID: "a", Data: []byte("a1")}}, 3)
+	require.NoError(t, err)
+	_, _, revision, err = s.PushRecords(email, "d1", []domain.Record{{ID: "a", Revision: revision, Data: []byte("a2")}}, 3)
+	require.NoError(t, err)
+	_, _, err = s.RekeyRecords(email, "d1", revision, []domain.Record{{ID: "a", Data: []byte("a3")}}, domain.RecordKeyParams{Salt: []byte("salt2"), KeyCheck: []byte("check")}, 3)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveDevice(email, domain.Device{ID: "d1", Name: "laptop", CreatedAt: time.Now(), LastSeen: time.Now()}))
+	require.NoError(t, s.SaveChunk(email, "c1", []byte("chunk")))
+	require.NoError(t, s.SaveRefreshToken("h1", domain.RefreshToken{Email: email, Family: "f1", DeviceID: "d1", ExpiresAt: time.Now().Add(time.Hour)}))
+	allow := func(domain.LoginAttempts) bool { return true }
+	_, _, err = s.ReserveLoginAttempt("email:"+email, time.Now(), time.Time{}, allow)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteUser(email))
+	attempts, err := s.GetLoginAttempts("email:" + email)
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+	_, err = s.GetUserHashedPassword(email)
+	require.ErrorIs(t, err, ErrUserNotFound)
+	_, err = s.UseRefreshToken("h1")
+	require.ErrorIs(t, err, ErrTokenNotFound)
+	_, err = s.GetChunk(email, "c1")
+	require.ErrorIs(t, err, ErrChunkNotFound)
+
+	// зарегистрированный заново с тем же email пользователь не видит старых данных
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	_, err = s.GetLastSyncTime(email)
+	require.ErrorIs(t, err, ErrNoSyncTime)
+	_, err = s.GetData(email)
+	require.ErrorIs(t, err, ErrNoData)
+	records, revision, err := s.PullRecords(email, 0)
+	require.NoError(t, err)
+	require.Empty(t, records)
+	require.Zero(t, revision)
+	devices, err := s.ListDevices(email)
+	require.NoError(t, err)
+	require.Empty(t, devices)
+	versions, err := s.ListVersions(email)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+	params, err := s.GetRecordKeyParams(email)
+	require.NoError(t, err)
+	require.Equal(t, domain.RecordKeyParams{}, params)
+}
+
+func testLoginAttempts(t *testing.T, s backend) {
+	attempts, err := s.GetLoginAttempts("email:" + email)
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+	require.ErrorIs(t, s.DeleteLoginAttempts("email:"+email), ErrNoLoginAttempts)
+
+	now := time.Now().Truncate(time.Second)
+	below := func(n int) func(domain.LoginAttempts) bool {
+		return func(a domain.LoginAttempts) bool { return a.Failures < n }
+	}
+	for i := 1; i <= 3; i++ {
+		var reserved bool
+		attempts, reserved, err = s.ReserveLoginAttempt("email:"+email, now, now.Add(-time.Hour), below(3))
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.Equal(t, i, attempts.Failures)
+	}
+	// попытка, которую allow не разрешает, не засчитывается
+	attempts, reserved, err := s.ReserveLoginAttempt("email:"+email, now.Add(time.Second), now.Add(-time.Hour), below(3))
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.Equal(t, 3, attempts.Failures)
+	_, _, err = s.ReserveLoginAttempt("addr:10.0.0.1", now, now.Add(-time.Hour), below(3))
+	require.NoError(t, err)
+	attempts, err = s.GetLoginAttempts("email:" + email)
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+	require.True(t, now.Equal(attempts.LastFailure))
+
+	// неудача после окна сброса начинает счетчик заново
+	later := now.Add(2 * time.Hour)
+	attempts, reserved, err = s.ReserveLoginAttempt("email:"+email, later, later.Add(-time.Hour), below(3))
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.Equal(t, 1, attempts.Failures)
+
+	// отмена последней попытки удаляет счетчик
+	_, _, err = s.ReserveLoginAttempt("addr:10.0.0.2", now, now.Add(-time.Hour), below(3))
+	require.NoError(t, err)
+	require.NoError(t, s.ReleaseLoginAttempt("addr:10.0.0.2"))
+	attempts, err = s.GetLoginAttempts("addr:10.0.0.2")
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+
+	list, err := s.ListLoginAttempts()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.NoError(t, s.DeleteLoginAttempts("email:"+email))
+	attempts, err = s.GetLoginAttempts("email:" + email)
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+}
+
+func TestMigrateTokens(t *testing.T) {
+	lg, _ := logger.New(true)
+	path := filepath.Join(t.TempDir(), "test.pbb")
+	db, err := bbolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	token := domain.RefreshToken{Email: email, Family: "f1", DeviceID: "d1", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("refresh"))
+		if err != nil {
+			return err
+		}
+		v, err := encodeToken(token)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("t1"), v)
+	}))
+	require.NoError(t, db.Close())
+
+	s, err := New(path, lg)
+	require.NoError(t, err)
+	got, err := s.UseRefreshToken("t1")
+	require.NoError(t, err)
+	require.Equal(t, token.Family, got.Family)
+	require.NoError(t, s.db.View(func(tx *bbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("refresh")))
+		return nil
+	}))
+}
+
+func TestLegacySnapshot(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	require.NoError(t, s.RegisterUser(email, []byte("hash")))
+	_, _, _, err = s.PushRecords(email, "d1", []domain.Record{{ID: "a", Data: []byte("a1")}}, 0)
+	require.NoError(t, err)
+	// версия, сохраненная прежней версией сервера полным снимком
+	legacy := []domain.Record{{ID: "a", Revision: 1, Data: []byte("a1")}}
+	require.NoError(t, s.db.Update(func(tx *bbolt.Tx) error {
+		versions, err := tx.Bucket([]byte("versions")).CreateBucketIfNotExists([]byte(email))
+		if err != nil {
+			return err
+		}
+		snapshots, err := tx.Bucket([]byte("snapshots")).CreateBucketIfNotExists([]byte(email))
+		if err != nil {
+			return err
+		}
+		meta, err := encodeGob(domain.VaultVersion{Number: 1, DeviceID: "d1", Records: 1})
+		if err != nil {
+			return err
+		}
+		snapshot, err := encodeGob(legacy)
+		if err != nil {
+			return err
+		}
+		if err = versions.Put(versionKey(1), meta); err != nil {
+			return err
+		}
+		return snapshots.Put(versionKey(1), snapshot)
+	}))
+	_, _, _, err = s.PushRecords(email, "d1", []domain.Record{{ID: "a", Revision: 1, Data: []byte("a2")}}, 3)
+	require.NoError(t, err)
+
+	_, records, err := s.GetVersion(email, 1)
+	require.NoError(t, err)
+	require.Equal(t, legacy, records)
+	_, records, err = s.GetVersion(email, 2)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Record{{ID: "a", Revision: 2, Data: []byte("a2")}}, records)
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+	"time"
+)
+
+var ErrTokenNotFound = errors.New("refresh token not found")
+
+// Refresh token хранятся по пользователям и семействам: tokens/email/family/hash -> токен,
+// а индекс tokenindex/hash -> email, family находит токен по хешу. Отзыв и очистка
+// просматривают только токены одного пользователя
+
+// tokenRef положение токена в бакете tokens
+type tokenRef struct {
+	Email  string
+	Family string
+}
+
+// SaveRefreshToken сохраняет refresh token по хешу и удаляет истекшие токены пользователя
+func (pp *storage) SaveRefreshToken(hash string, token domain.RefreshToken) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		errDelete := deleteTokens(tx, token.Email, func(t domain.RefreshToken) bool {
+			return time.Now().After(t.ExpiresAt)
+		})
+		if errDelete != nil {
+			return errDelete
+		}
+		return putToken(tx, hash, token)
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// UseRefreshToken помечает refresh token использованным и возвращает его состояние до этого.
+// Использованный токен остается в хранилище, чтобы обнаружить повторное использование,
+// пока семейство не обновится снова: более старые использованные токены семейства удаляются
+func (pp *storage) UseRefreshToken(hash string) (token domain.RefreshToken, err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		ref, ok := getTokenRef(tx, hash)
+		if !ok {
+			return ErrTokenNotFound
+		}
+		family := familyBucket(tx, ref)
+		if family == nil {
+			return ErrTokenNotFound
+		}
+		v := family.Get([]byte(hash))
+		if v == nil {
+			return ErrTokenNotFound
+		}
+		var errDecode error
+		token, errDecode = decodeToken(v)
+		if errDecode != nil {
+			return errDecode
+		}
+		if token.Used {
+			return nil
+		}
+		errDelete := deleteTokens(tx, ref.Email, func(t domain.RefreshToken) bool {
+			return t.Family == ref.Family && t.Used
+		})
+		if errDelete != nil {
+			return errDelete
+		}
+		used := token
+		used.Used = true
+		return putToken(tx, hash, used)
+	})
+	return
+}
+
+// RevokeRefreshTokens удаляет refresh token пользователя из семейства family или все, если family пустое
+func (pp *storage) RevokeRefreshTokens(email string, family string) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		return deleteTokens(tx, email, func(t domain.RefreshToken) bool {
+			return family == "" || t.Family == family
+		})
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+// RevokeDeviceTokens удаляет все refresh token устройства
+func (pp *storage) RevokeDeviceTokens(email string, deviceID string) (err error) {
+	err = pp.db.Update(func(tx *bbolt.Tx) error {
+		return deleteTokens(tx, email, func(t domain.RefreshToken) bool {
+			return t.DeviceID == deviceID
+		})
+	})
+	if err != nil {
+		pp.logger.Debug("err", zap.Error(err))
+	}
+	return
+}
+
+func putToken(tx *bbolt.Tx, hash string, token domain.RefreshToken) error {
+	v, err := encodeToken(token)
+	if err != nil {
+		return err
+	}
+	ref, err := encodeTokenRef(tokenRef{Email: token.Email, Family: token.Family})
+	if err != nil {
+		return err
+	}
+	user, err := tx.Bucket([]byte("tokens")).CreateBucketIfNotExists([]byte(token.Email))
+	if err != nil {
+		return err
+	}
+	family, err := user.CreateBucketIfNotExists([]byte(token.Family))
+	if err != nil {
+		return err
+	}
+	if err = family.Put([]byte(hash), v); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("tokenindex")).Put([]byte(hash), ref)
+}
+
+func getTokenRef(tx *bbolt.Tx, hash string) (tokenRef, bool) {
+	v := tx.Bucket([]byte("tokenindex")).Get([]byte(hash))
+	if v == nil {
+		return tokenRef{}, false
+	}
+	var ref tokenRef
+	if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&ref); err != nil {
+		return tokenRef{}, false
+	}
+	return ref, true
+}
+
+func familyBucket(tx *bbolt.Tx, ref tokenRef) *bbolt.Bucket {
+	user := tx.Bucket([]byte("tokens")).Bucket([]byte(ref.Email))
+	if user == nil {
+		return nil
+	}
+	return user.Bucket([]byte(ref.Family))
+}
+
+// deleteTokens удаляет токены пользователя email, для которых match возвращает true,
+// вместе с записями индекса. Опустевшие семейства удаляются
+func deleteTokens(tx *bbolt.Tx, email string, match func(domain.RefreshToken) bool) error {
+	user := tx.Bucket([]byte("tokens")).Bucket([]byte(email))
+	if user == nil {
+		return nil
+	}
+	index := tx.Bucket([]byte("tokenindex"))
+	var families [][]byte
+	err := user.ForEachBucket(func(name []byte) error {
+		family := user.Bucket(name)
+		var keys [][]byte
+		errEach := family.ForEach(func(k, v []byte) error {
+			t, err := decodeToken(v)
+			if err != nil || match(t) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if errEach != nil {
+			return errEach
+		}
+		for _, k := range keys {
+			if errDelete := family.Delete(k); errDelete != nil {
+				return errDelete
+			}
+			if errDelete := index.Delete(k); errDelete != nil {
+				return errDelete
+			}
+		}
+		if k, _ := family.Cursor().First(); k == nil {
+			families = append(families, append([]byte(nil), name...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range families {
+		if err = user.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTokens переносит токены из бакета refresh прежних версий, где все токены лежали
+// в одном бакете по хешу
+func migrateTokens(tx *bbolt.Tx) error {
+	legacy := tx.Bucket([]byte("refresh"))
+	if legacy == nil {
+		return nil
+	}
+	err := legacy.ForEach(func(k, v []byte) error {
+		t, err := decodeToken(v)
+		if err != nil || time.Now().After(t.ExpiresAt) {
+			return nil
+		}
+		return putToken(tx, string(k), t)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.DeleteBucket([]byte("refresh"))
+}
+
+func encodeToken(token domain.RefreshToken) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(token)
+	return buf.Bytes(), err
+}
+
+func decodeToken(v []byte) (token domain.RefreshToken, err error) {
+	err = gob.NewDecoder(bytes.NewReader(v)).Decode(&token)
+	return
+}
+
+func encodeTokenRef(ref tokenRef) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(ref)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.etcd.io/bbolt"
+	"sort"
+)
+
+var ErrVersionNotFound = errors.New("vault version not found")
+
+// Версия - состояние записей пользователя на ревизии с ее номером. Описание версии хранится в бакете
+// пользователя versions с ключом - номером версии. Записи версии не копируются: PushRecords в той же
+// транзакции сохраняет в history прежнее значение каждой замененной записи с ключом - ревизией замены
+// и ID записи. Версия N собирается из текущих записей с ревизией не больше N и, для остальных,
+// из первых замененных после N значений. В snapshots остались полные снимки версий прежних версий сервера
+
+// saveVersion сохраняет описание версии и удаляет самые старые версии сверх keep
+// вместе с историей, которая нужна только им. Ссылки удаленной истории на чанки вычитаются в refs
+func saveVersion(tx *bbolt.Tx, email string, version domain.VaultVersion, keep int, refs chunkRefs) error {
+	meta, err := encodeGob(version)
+	if err != nil {
+		return err
+	}
+	versions, err := tx.Bucket([]byte("versions")).CreateBucketIfNotExists([]byte(email))
+	if err != nil {
+		return err
+	}
+	if err = versions.Put(versionKey(version.Number), meta); err != nil {
+		return err
+	}
+	// ключи упорядочены по номеру, удаляются первые сверх keep
+	var keys [][]byte
+	c := versions.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	if len(keys) > keep {
+		snapshots := tx.Bucket([]byte("snapshots")).Bucket([]byte(email))
+		for _, k := range keys[:len(keys)-keep] {
+			if err = versions.Delete(k); err != nil {
+				return err
+			}
+			if snapshots != nil {
+				if err = snapshots.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		keys = keys[len(keys)-keep:]
+	}
+	// значения, замененные не позже самой старой версии, ни в одну версию не попадут
+	history := tx.Bucket([]byte("history")).Bucket([]byte(email))
+	if history == nil || len(keys) == 0 {
+		return nil
+	}
+	var stale [][]byte
+	c = history.Cursor()
+	for k, v := c.First(); k != nil && bytes.Compare(k[:8], keys[0]) <= 0; k, v = c.Next() {
+		stale = append(stale, append([]byte(nil), k...))
+		if r, ok := decodeRecord(string(k[8:]), v); ok {
+			refs.add(r.Chunks, -1)
+		}
+	}
+	for _, k := range stale {
+		if err = history.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveHistory сохраняет значение записи old, замененное на ревизии supersededAt
+func saveHistory(tx *bbolt.Tx, email string, old domain.Record, supersededAt int64) error {
+	history, err := tx.Bucket([]byte("history")).CreateBucketIfNotExists([]byte(email))
+	if err != nil {
+		return err
+	}
+	return history.Put(append(versionKey(supersededAt), old.ID...), encodeRecord(old))
+}
+
+// deleteVersions удаляет все версии пользователя и их историю. Ссылки истории на чанки вычитаются в refs
+func deleteVersions(tx *bbolt.Tx, email string, refs chunkRefs) error {
+	if history := tx.Bucket([]byte("history")).Bucket([]byte(email)); history != nil {
+		_ = history.ForEach(func(k, v []byte) error {
+			if r, ok := decodeRecord(string(k[8:]), v); ok {
+				refs.add(r.Chunks, -1)
+			}
+			return nil
+		})
+	}
+	for _, name := range []string{"versions", "snapshots", "history"} {
+		err := tx.Bucket([]byte(name)).DeleteBucket([]byte(email))
+		if err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// countRecords возвращает число неудаленных записей
+func countRecords(b *bbolt.Bucket) (n int64) {
+	_ = b.ForEach(func(k, v []byte) error {
+		if r, ok := decodeRecord(string(k), v); ok && !r.Deleted {
+			n++
+		}
+		return nil
+	})
+	return
+}
+
+// ListVersions возвращает версии пользователя, новые первыми
+func (pp *storage) ListVersions(email string) (versions []domain.VaultVersion, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("versions")).Bucket([]byte(email))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var version domain.VaultVersion
+			if errDecode := gob.NewDecoder(bytes.NewReader(v)).Decode(&version); errDecode != nil {
+				return errDecode
+			}
+			versions = append(versions, version)
+		}
+		return nil
+	})
+	return
+}
+
+// GetVersion возвращает версию и ее записи, упорядоченные по ID
+func (pp *storage) GetVersion(email string, number int64) (version domain.VaultVersion, records []domain.Record, err error) {
+	err = pp.db.View(func(tx *bbolt.Tx) error {
+		versions := tx.Bucket([]byte("versions")).Bucket([]byte(email))
+		if versions == nil {
+			return ErrVersionNotFound
+		}
+		meta := versions.Get(versionKey(number))
+		if meta == nil {
+			return ErrVersionNotFound
+		}
+		if errDecode := gob.NewDecoder(bytes.NewReader(meta)).Decode(&version); errDecode != nil {
+			return errDecode
+		}
+		if snapshots := tx.Bucket([]byte("snapshots")).Bucket([]byte(email)); snapshots != nil {
+			if snapshot := snapshots.Get(versionKey(number)); snapshot != nil {
+				return gob.NewDecoder(bytes.NewReader(snapshot)).Decode(&records)
+			}
+		}
+		if b := tx.Bucket([]byte("records")).Bucket([]byte(email)); b != nil {
+			errForEach := b.ForEach(func(k, v []byte) error {
+				if r, ok := decodeRecord(string(k), v); ok && r.Revision <= number && !r.Deleted {
+					records = append(records, r)
+				}
+				return nil
+			})
+			if errForEach != nil {
+				return errForEach
+			}
+		}
+		if history := tx.Bucket([]byte("history")).Bucket([]byte(email)); history != nil {
+			// запись, измененная после версии, была в версии в значении, замененном первым после нее
+			seen := make(map[string]bool)
+			c := history.Cursor()
+			for k, v := c.Seek(versionKey(number + 1)); k != nil; k, v = c.Next() {
+				id := string(k[8:])
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if r, ok := decodeRecord(id, v); ok && r.Revision <= number && !r.Deleted {
+					records = append(records, r)
+				}
+			}
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		return nil
+	})
+	return
+}
+
+func versionKey(number int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(number))
+	return k
+}
+
+func encodeGob(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
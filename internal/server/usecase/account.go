@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrWrongPassword = errors.New("wrong password")
+
+// ChangePassword меняет пароль аккаунта. Все refresh token отзываются, другие устройства отзываются
+// и должны войти заново с новым паролем. Текущее устройство получает новые токены.
+// Неверный пароль засчитывается в те же счетчики, что и при входе
+func (uc *usecase) ChangePassword(email string, deviceID string, password string, newPassword string, addr string) (domain.Tokens, error) {
+	if err := uc.verifyPassword(email, password, addr); err != nil {
+		return domain.Tokens{}, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	if err = uc.storage.UpdateUserPassword(email, hashedPassword); err != nil {
+		return domain.Tokens{}, err
+	}
+	if err = uc.storage.RevokeRefreshTokens(email, ""); err != nil {
+		return domain.Tokens{}, err
+	}
+	devices, err := uc.storage.ListDevices(email)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	for _, device := range devices {
+		if device.ID == deviceID || device.Revoked {
+			continue
+		}
+		device.Revoked = true
+		if err = uc.storage.SaveDevice(email, device); err != nil {
+			return domain.Tokens{}, err
+		}
+	}
+	uc.logger.Info("password changed", zap.String("email", email), zap.String("device", deviceID))
+	return uc.issueTokens(email, "", deviceID)
+}
+
+// DeleteAccount удаляет аккаунт и все данные пользователя на сервере.
+// Неверный пароль засчитывается в те же счетчики, что и при входе
+func (uc *usecase) DeleteAccount(email string, password string, addr string) error {
+	if err := uc.verifyPassword(email, password, addr); err != nil {
+		return err
+	}
+	if err := uc.storage.DeleteUser(email); err != nil {
+		return err
+	}
+	uc.logger.Info("account deleted", zap.String("email", email))
+	return nil
+}
+
+// checkPassword сверяет пароль с хешем пользователя
+func (uc *usecase) checkPassword(email string, password string) error {
+	hash, err := uc.storage.GetUserHashedPassword(email)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package usecase_test
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccount(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+	email := "test@test.ts"
+
+	login, err := uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+	laptop, laptopTokens, err := uc.RegisterDevice(email, "laptop", login.Refresh)
+	require.NoError(t, err)
+	login, err = uc.LoginUser(email, "pass", "")
+	require.NoError(t, err)
+	phone, _, err := uc.RegisterDevice(email, "phone", login.Refresh)
+	require.NoError(t, err)
+
+	_, err = uc.ChangePassword(email, phone, "wrong", "new pass", "")
+	require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	tokens, err := uc.ChangePassword(email, phone, "pass", "new pass", "")
+	require.NoError(t, err)
+	// другие устройства входят заново, текущее продолжает работать с новыми токенами
+	require.ErrorIs(t, uc.CheckDevice(email, laptop), usecase.ErrDeviceRevoked)
+	_, err = uc.RefreshToken(laptopTokens.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+	require.NoError(t, uc.CheckDevice(email, phone))
+	_, err = uc.RefreshToken(tokens.Refresh)
+	require.NoError(t, err)
+	_, err = uc.LoginUser(email, "pass", "")
+	require.Error(t, err)
+	_, err = uc.LoginUser(email, "new pass", "")
+	require.NoError(t, err)
+
+	require.ErrorIs(t, uc.DeleteAccount(email, "pass", ""), usecase.ErrWrongPassword)
+	require.NoError(t, uc.DeleteAccount(email, "new pass", ""))
+	_, err = uc.LoginUser(email, "new pass", "")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	require.ErrorIs(t, uc.CheckDevice(email, phone), usecase.ErrDeviceNotRegistered)
+}
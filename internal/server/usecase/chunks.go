@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+)
+
+// MaxChunkSize наибольший размер чанка. Клиент шифрует содержимое по 1 MiB, запас - на случай
+// другого размера у будущих клиентов при лимите сообщения gRPC в 4 MiB
+const MaxChunkSize = 3 << 20
+
+// MaxRecordChunks наибольшее число чанков записи, ограничено форматом записи в хранилище: 64 GiB по 1 MiB
+const MaxRecordChunks = 1<<16 - 1
+
+var (
+	ErrChunkHash     = errors.New("chunk id does not match sha256 of data")
+	ErrChunkTooLarge = errors.New("chunk is too large")
+	ErrInvalidChunks = errors.New("invalid record chunks")
+)
+
+// SaveChunk проверяет, что id чанка - sha256 его данных, и сохраняет чанк пользователя
+func (uc *usecase) SaveChunk(email string, id string, data []byte) error {
+	if len(data) > MaxChunkSize {
+		return ErrChunkTooLarge
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != id {
+		return ErrChunkHash
+	}
+	return uc.storage.SaveChunk(email, id, data)
+}
+
+// checkChunks проверяет, что id чанков записей - sha256 в hex, а чанков не больше MaxRecordChunks
+func checkChunks(records []domain.Record) error {
+	for _, r := range records {
+		if len(r.Chunks) > MaxRecordChunks {
+			return fmt.Errorf("%w: record %s has %d chunks, max %d", ErrInvalidChunks, r.ID, len(r.Chunks), MaxRecordChunks)
+		}
+		for _, id := range r.Chunks {
+			if b, err := hex.DecodeString(id); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("%w: record %s: chunk id %.80q is not sha256 hex", ErrInvalidChunks, r.ID, id)
+			}
+		}
+	}
+	return nil
+}
+
+// GetChunk возвращает чанк пользователя
+func (uc *usecase) GetChunk(email string, id string) ([]byte, error) {
+	return uc.storage.GetChunk(email, id)
+}
+
+// MissingChunks возвращает id чанков, которых нет на сервере
+func (uc *usecase) MissingChunks(email string, ids []string) ([]string, error) {
+	return uc.storage.MissingChunks(email, ids)
+}
@@ -0,0 +1,60 @@
+package usecase_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunks(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+
+	data := []byte("encrypted chunk")
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	missing, err := uc.MissingChunks("a@test.ts", []string{id})
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, missing)
+
+	require.ErrorIs(t, uc.SaveChunk("a@test.ts", id, []byte("other data")), usecase.ErrChunkHash)
+	require.ErrorIs(t, uc.SaveChunk("a@test.ts", id, make([]byte, usecase.MaxChunkSize+1)), usecase.ErrChunkTooLarge)
+	require.NoError(t, uc.SaveChunk("a@test.ts", id, data))
+	require.NoError(t, uc.SaveChunk("a@test.ts", id, data))
+
+	missing, err = uc.MissingChunks("a@test.ts", []string{id})
+	require.NoError(t, err)
+	require.Empty(t, missing)
+	got, err := uc.GetChunk("a@test.ts", id)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	// чанки других пользователей недоступны
+	_, err = uc.GetChunk("b@test.ts", id)
+	require.ErrorIs(t, err, storage.ErrChunkNotFound)
+	missing, err = uc.MissingChunks("b@test.ts", []string{id})
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, missing)
+
+	// запись ссылается только на чанки с id в виде sha256 и не больше MaxRecordChunks
+	for _, chunks := range [][]string{{"c1"}, {id[:62] + "zz"}, {id + id}, make([]string, usecase.MaxRecordChunks+1)} {
+		_, _, _, err = uc.PushRecords("a@test.ts", "d1", []domain.Record{{ID: "a", Data: []byte("a1"), Chunks: chunks}})
+		require.ErrorIs(t, err, usecase.ErrInvalidChunks)
+		_, _, err = uc.Rekey("a@test.ts", "d1", 0, []domain.Record{{ID: "a", Data: []byte("a1"), Chunks: chunks}}, domain.RecordKeyParams{KeyCheck: []byte("check")})
+		require.ErrorIs(t, err, usecase.ErrInvalidChunks)
+	}
+	_, err = uc.RegisterUser("a@test.ts", "pass")
+	require.NoError(t, err)
+	accepted, _, _, err := uc.PushRecords("a@test.ts", "d1", []domain.Record{{ID: "a", Data: []byte("a1"), Chunks: []string{id}}})
+	require.NoError(t, err)
+	require.Len(t, accepted, 1)
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"time"
+)
+
+// lastSeenPeriod как часто обновляется время последнего запроса устройства
+const lastSeenPeriod = time.Minute
+
+var (
+	ErrDeviceNotRegistered = errors.New("device is not registered: login again")
+	ErrDeviceRevoked       = errors.New("device is revoked")
+)
+
+// RegisterDevice регистрирует устройство и обменивает токены входа на токены, привязанные к нему.
+// Токены входа отзываются, дальше устройство обновляет только свои токены
+func (uc *usecase) RegisterDevice(email string, name string, refreshToken string) (deviceID string, tokens domain.Tokens, err error) {
+	token, err := uc.useRefreshToken(refreshToken)
+	if err != nil {
+		return "", domain.Tokens{}, err
+	}
+	if token.Email != email || token.DeviceID != "" {
+		return "", domain.Tokens{}, ErrInvalidRefreshToken
+	}
+	if err = uc.storage.RevokeRefreshTokens(email, token.Family); err != nil {
+		return "", domain.Tokens{}, err
+	}
+	deviceID, err = newDeviceID()
+	if err != nil {
+		return "", domain.Tokens{}, err
+	}
+	now := time.Now()
+	err = uc.storage.SaveDevice(email, domain.Device{ID: deviceID, Name: name, CreatedAt: now, LastSeen: now})
+	if err != nil {
+		return "", domain.Tokens{}, err
+	}
+	uc.logger.Info("device registered", zap.String("email", email), zap.String("device", deviceID))
+	tokens, err = uc.issueTokens(email, "", deviceID)
+	return deviceID, tokens, err
+}
+
+// ListDevices возвращает устройства пользователя, current отмечает устройство запроса
+func (uc *usecase) ListDevices(email string, current string) ([]domain.Device, error) {
+	devices, err := uc.storage.ListDevices(email)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		devices[i].Current = devices[i].ID == current
+	}
+	return devices, nil
+}
+
+// RevokeDevice отзывает устройство и его refresh token. Уже выданный JWT перестает
+// приниматься сразу, так как устройство проверяется при каждом запросе
+func (uc *usecase) RevokeDevice(email string, id string) error {
+	device, err := uc.storage.GetDevice(email, id)
+	if err != nil {
+		return err
+	}
+	device.Revoked = true
+	if err = uc.storage.SaveDevice(email, device); err != nil {
+		return err
+	}
+	uc.logger.Info("device revoked", zap.String("email", email), zap.String("device", id))
+	return uc.storage.RevokeDeviceTokens(email, id)
+}
+
+// CheckDevice проверяет, что устройство из JWT не отозвано, и обновляет время последнего запроса
+func (uc *usecase) CheckDevice(email string, id string) error {
+	if id == "" {
+		return ErrDeviceNotRegistered
+	}
+	device, err := uc.storage.GetDevice(email, id)
+	if err != nil {
+		uc.logger.Debug("device check error", zap.Error(err))
+		return ErrDeviceNotRegistered
+	}
+	if device.Revoked {
+		return ErrDeviceRevoked
+	}
+	if time.Since(device.LastSeen) > lastSeenPeriod {
+		if err = uc.storage.TouchDevice(email, id, time.Now()); err != nil {
+			uc.logger.Debug("device last seen error", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// newDeviceID ID устройства в hex, чтобы его было удобно передавать аргументом командной строки
+func newDeviceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,51 @@
+package usecase_test
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestDevices(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+	email := "test@test.ts"
+
+	login, err := uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+	require.ErrorIs(t, uc.CheckDevice(email, ""), usecase.ErrDeviceNotRegistered)
+	laptop, laptopTokens, err := uc.RegisterDevice(email, "laptop", login.Refresh)
+	require.NoError(t, err)
+	require.NoError(t, uc.CheckDevice(email, laptop))
+	// токены входа обменяны на токены устройства
+	_, err = uc.RefreshToken(login.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+	_, _, err = uc.RegisterDevice(email, "again", login.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+
+	login, err = uc.LoginUser(email, "pass", "")
+	require.NoError(t, err)
+	phone, phoneTokens, err := uc.RegisterDevice(email, "phone", login.Refresh)
+	require.NoError(t, err)
+
+	devices, err := uc.ListDevices(email, phone)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	require.Equal(t, "laptop", devices[0].Name)
+	require.False(t, devices[0].Current)
+	require.True(t, devices[1].Current)
+
+	require.NoError(t, uc.RevokeDevice(email, laptop))
+	require.ErrorIs(t, uc.CheckDevice(email, laptop), usecase.ErrDeviceRevoked)
+	_, err = uc.RefreshToken(laptopTokens.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+	require.NoError(t, uc.CheckDevice(email, phone))
+	_, err = uc.RefreshToken(phoneTokens.Refresh)
+	require.NoError(t, err)
+	require.ErrorIs(t, uc.RevokeDevice(email, "unknown"), storage.ErrDeviceNotFound)
+}
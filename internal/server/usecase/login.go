@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// ErrNoLockout по email или адресу нет неудачных попыток входа
+var ErrNoLockout = errors.New("no failed login attempts for email or address")
+
+// LoginLimit ограничение неудачных попыток входа. Попытки считаются отдельно по email и по адресу клиента:
+// после Attempts (AddrAttempts для адреса) неудач вход блокируется на Lockout, каждая следующая неудача
+// удваивает блокировку до MaxLockout. Счетчик сбрасывается успешным входом или через Window после последней неудачи
+type LoginLimit struct {
+	Attempts     int
+	AddrAttempts int
+	Lockout      time.Duration
+	MaxLockout   time.Duration
+	Window       time.Duration
+}
+
+// DefaultLoginLimit ограничение попыток входа по умолчанию
+var DefaultLoginLimit = LoginLimit{
+	Attempts:     5,
+	AddrAttempts: 20,
+	Lockout:      30 * time.Second,
+	MaxLockout:   time.Hour,
+	Window:       24 * time.Hour,
+}
+
+// Lockout блокировка входа по email или адресу клиента
+type Lockout struct {
+	domain.LoginAttempts
+	// LockedUntil до какого времени вход заблокирован, нулевое - не заблокирован
+	LockedUntil time.Time
+}
+
+const (
+	emailKeyPrefix = "email:"
+	addrKeyPrefix  = "addr:"
+)
+
+// SetLoginLimit задает ограничение попыток входа, 0 в Attempts или AddrAttempts отключает соответствующий счетчик
+func (uc *usecase) SetLoginLimit(limit LoginLimit) {
+	uc.loginLimit = limit
+}
+
+// LoginUser проверяет пароль и выдает токены
+func (uc *usecase) LoginUser(email string, password string, addr string) (tokens domain.Tokens, err error) {
+	if err = uc.verifyPassword(email, password, addr); err != nil {
+		return domain.Tokens{}, err
+	}
+	return uc.issueTokens(email, "", "")
+}
+
+// verifyPassword проверяет пароль с ограничением попыток. Пока email или адрес клиента заблокирован, пароль не проверяется.
+// Попытка засчитывается как неудача до проверки пароля, поэтому параллельные попытки не проходят сверх лимита,
+// и отменяется после успешной проверки. Неизвестный email считается неудачей так же, как неверный пароль,
+// чтобы перебор email тоже ограничивался
+func (uc *usecase) verifyPassword(email string, password string, addr string) error {
+	keys := uc.loginKeys(email, addr)
+	now := time.Now()
+	var reserved []string
+	for _, key := range sortedKeys(keys) {
+		free := keys[key]
+		var until time.Time
+		_, ok, err := uc.storage.ReserveLoginAttempt(key, now, now.Add(-uc.loginLimit.Window), func(attempts domain.LoginAttempts) bool {
+			until = uc.lockedUntil(attempts, free)
+			return !until.After(now)
+		})
+		if err != nil || !ok {
+			uc.releaseLoginAttempts(reserved)
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			uc.logger.Info("login locked", zap.String("key", key), zap.Time("until", until))
+			return fmt.Errorf("%w, retry in %s", ErrTooManyAttempts, until.Sub(now).Round(time.Second))
+		}
+		reserved = append(reserved, key)
+	}
+	if err := uc.checkPassword(email, password); err != nil {
+		return err
+	}
+	// счетчик адреса успешный вход не сбрасывает: иначе перебор можно чередовать со входом в свой аккаунт
+	for _, key := range reserved {
+		if !strings.HasPrefix(key, emailKeyPrefix) {
+			uc.releaseLoginAttempts([]string{key})
+			continue
+		}
+		// счетчик мог уже сбросить параллельный успешный вход
+		if err := uc.storage.DeleteLoginAttempts(key); err != nil {
+			uc.logger.Debug("reset login failures", zap.String("key", key), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// releaseLoginAttempts отменяет засчитанные попытки входа
+func (uc *usecase) releaseLoginAttempts(keys []string) {
+	for _, key := range keys {
+		if err := uc.storage.ReleaseLoginAttempt(key); err != nil {
+			uc.logger.Error("release login attempt", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// ListLockouts возвращает счетчики неудачных попыток входа, заблокированные первыми
+func (uc *usecase) ListLockouts() ([]Lockout, error) {
+	list, err := uc.storage.ListLoginAttempts()
+	if err != nil {
+		return nil, err
+	}
+	lockouts := make([]Lockout, 0, len(list))
+	for _, attempts := range list {
+		free := uc.loginLimit.Attempts
+		if strings.HasPrefix(attempts.Key, addrKeyPrefix) {
+			free = uc.loginLimit.AddrAttempts
+		}
+		lockouts = append(lockouts, Lockout{LoginAttempts: attempts, LockedUntil: uc.lockedUntil(attempts, free)})
+	}
+	sort.SliceStable(lockouts, func(i, j int) bool { return lockouts[i].LockedUntil.After(lockouts[j].LockedUntil) })
+	return lockouts, nil
+}
+
+// ClearLockout сбрасывает неудачные попытки входа и блокировку по email или адресу клиента
+func (uc *usecase) ClearLockout(subject string) error {
+	var cleared bool
+	for _, key := range []string{emailKeyPrefix + normalizeEmail(subject), addrKeyPrefix + strings.TrimSpace(subject)} {
+		attempts, err := uc.storage.GetLoginAttempts(key)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures == 0 {
+			continue
+		}
+		if err = uc.storage.DeleteLoginAttempts(key); err != nil {
+			return err
+		}
+		cleared = true
+	}
+	if !cleared {
+		return ErrNoLockout
+	}
+	uc.logger.Info("login lockout cleared", zap.String("subject", subject))
+	return nil
+}
+
+// loginKeys возвращает ключи счетчиков попыток входа с числом неудач без блокировки
+func (uc *usecase) loginKeys(email string, addr string) map[string]int {
+	keys := make(map[string]int, 2)
+	if uc.loginLimit.Attempts > 0 {
+		keys[emailKeyPrefix+normalizeEmail(email)] = uc.loginLimit.Attempts
+	}
+	if uc.loginLimit.AddrAttempts > 0 && addr != "" {
+		keys[addrKeyPrefix+addr] = uc.loginLimit.AddrAttempts
+	}
+	return keys
+}
+
+// sortedKeys ключи счетчиков в постоянном порядке
+func sortedKeys(keys map[string]int) []string {
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// normalizeEmail приводит email к виду ключа счетчика, чтобы блокировку не обходили регистром и пробелами
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// lockedUntil время окончания блокировки после attempts, нулевое - блокировки нет
+func (uc *usecase) lockedUntil(attempts domain.LoginAttempts, free int) time.Time {
+	if free <= 0 || attempts.Failures < free || time.Since(attempts.LastFailure) > uc.loginLimit.Window {
+		return time.Time{}
+	}
+	lockout := uc.loginLimit.Lockout
+	for i := free; i < attempts.Failures && lockout < uc.loginLimit.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > uc.loginLimit.MaxLockout {
+		lockout = uc.loginLimit.MaxLockout
+	}
+	return attempts.LastFailure.Add(lockout)
+}
@@ -0,0 +1,142 @@
+package usecase_test
+
+import (
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginLimit(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+	uc.SetLoginLimit(usecase.LoginLimit{Attempts: 3, AddrAttempts: 5, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour})
+	email := "test@test.ts"
+	_, err = uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+
+	// неудачи ниже порога сбрасываются успешным входом
+	for i := 0; i < 2; i++ {
+		_, err = uc.LoginUser(email, "wrong", "10.0.0.1")
+		require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	}
+	_, err = uc.LoginUser(email, "pass", "10.0.0.1")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = uc.LoginUser(email, "wrong", "10.0.0.2")
+		require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	}
+	// email заблокирован и с другого адреса, даже с верным паролем
+	_, err = uc.LoginUser(email, "pass", "10.0.0.3")
+	require.ErrorIs(t, err, usecase.ErrTooManyAttempts)
+	lockouts, err := uc.ListLockouts()
+	require.NoError(t, err)
+	require.Equal(t, "email:"+email, lockouts[0].Key)
+	require.WithinDuration(t, time.Now().Add(time.Minute), lockouts[0].LockedUntil, 5*time.Second)
+
+	require.ErrorIs(t, uc.ClearLockout("nobody@test.ts"), usecase.ErrNoLockout)
+	require.NoError(t, uc.ClearLockout(email))
+	_, err = uc.LoginUser(email, "pass", "10.0.0.3")
+	require.NoError(t, err)
+
+	// перебор email с одного адреса блокирует адрес, вход с другого адреса работает.
+	// 10.0.0.1 уже имеет 2 неудачи, успешный вход счетчик адреса не сбрасывает
+	for i := 0; i < 3; i++ {
+		_, err = uc.LoginUser("user"+string(rune('a'+i))+"@test.ts", "pass", "10.0.0.1")
+		require.ErrorIs(t, err, storage.ErrUserNotFound)
+	}
+	_, err = uc.LoginUser(email, "pass", "10.0.0.1")
+	require.ErrorIs(t, err, usecase.ErrTooManyAttempts)
+	_, err = uc.LoginUser(email, "pass", "10.0.0.3")
+	require.NoError(t, err)
+	require.NoError(t, uc.ClearLockout("10.0.0.1"))
+	_, err = uc.LoginUser(email, "pass", "10.0.0.1")
+	require.NoError(t, err)
+}
+
+func TestLoginLimitConcurrent(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+	uc.SetLoginLimit(usecase.LoginLimit{Attempts: 3, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour})
+	email := "test@test.ts"
+	_, err = uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+
+	// параллельные попытки проверяют пароль не больше Attempts раз
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errLogin := uc.LoginUser(email, "wrong", "10.0.0.1")
+			errs <- errLogin
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var checked, locked int
+	for errLogin := range errs {
+		switch {
+		case errors.Is(errLogin, usecase.ErrWrongPassword):
+			checked++
+		case errors.Is(errLogin, usecase.ErrTooManyAttempts):
+			locked++
+		default:
+			t.Fatalf("unexpected error %v", errLogin)
+		}
+	}
+	require.Equal(t, 3, checked)
+	require.Equal(t, n-3, locked)
+
+	// блокировку не обойти регистром и пробелами, снять - тоже
+	_, err = uc.LoginUser(" Test@Test.ts ", "pass", "10.0.0.2")
+	require.ErrorIs(t, err, usecase.ErrTooManyAttempts)
+	require.NoError(t, uc.ClearLockout("TEST@test.ts"))
+	_, err = uc.LoginUser(email, "pass", "10.0.0.2")
+	require.NoError(t, err)
+}
+
+func TestAccountLoginLimit(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+	uc.SetLoginLimit(usecase.LoginLimit{Attempts: 3, AddrAttempts: 5, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour})
+	email := "test@test.ts"
+	_, err = uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+
+	// подбор пароля через смену пароля и удаление аккаунта считается вместе со входом
+	_, err = uc.ChangePassword(email, "", "wrong", "new pass", "10.0.0.1")
+	require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	require.ErrorIs(t, uc.DeleteAccount(email, "wrong", "10.0.0.1"), usecase.ErrWrongPassword)
+	_, err = uc.LoginUser(email, "wrong", "10.0.0.1")
+	require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	_, err = uc.ChangePassword(email, "", "pass", "new pass", "10.0.0.2")
+	require.ErrorIs(t, err, usecase.ErrTooManyAttempts)
+	require.ErrorIs(t, uc.DeleteAccount(email, "pass", "10.0.0.2"), usecase.ErrTooManyAttempts)
+
+	// успешная проверка сбрасывает счетчик email
+	require.NoError(t, uc.ClearLockout(email))
+	_, err = uc.ChangePassword(email, "", "wrong", "new pass", "10.0.0.2")
+	require.ErrorIs(t, err, usecase.ErrWrongPassword)
+	_, err = uc.ChangePassword(email, "", "pass", "new pass", "10.0.0.2")
+	require.NoError(t, err)
+	lockouts, err := uc.ListLockouts()
+	require.NoError(t, err)
+	for _, lockout := range lockouts {
+		require.NotEqual(t, "email:"+email, lockout.Key)
+	}
+}
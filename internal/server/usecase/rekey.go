@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"time"
+)
+
+// recordSaltSize размер соли ключа записей
+const recordSaltSize = 16
+
+// Rekey заменяет записи пользователя записями, зашифрованными новым мастер-паролем. История хранилища
+// начинается заново с версии после смены ключа
+func (uc *usecase) Rekey(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams) (accepted []domain.Record, newRevision int64, err error) {
+	if err = checkChunks(records); err != nil {
+		return nil, 0, err
+	}
+	accepted, newRevision, err = uc.storage.RekeyRecords(email, deviceID, revision, records, params, uc.history)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = uc.storage.SetLastSyncTime(email, time.Now())
+	return
+}
+
+// GetRecordKeyParams возвращает соль ключа записей и значение для его проверки после смены мастер-пароля
+func (uc *usecase) GetRecordKeyParams(email string) (domain.RecordKeyParams, error) {
+	return uc.storage.GetRecordKeyParams(email)
+}
+
+// setRecordSalt задает новому аккаунту случайную соль ключа записей. Соль выдается только после входа,
+// поэтому подбирать мастер-пароль заранее по известному email нельзя
+func (uc *usecase) setRecordSalt(email string) error {
+	salt := make([]byte, recordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	return uc.storage.SetRecordSalt(email, salt)
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"go.uber.org/zap"
+	"time"
+)
+
+// refreshTTL время жизни refresh token. Каждый обмен выдает новый токен с новым сроком
+const refreshTTL = 30 * 24 * time.Hour
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// RefreshToken обменивает refresh token на новую пару токенов (ротация).
+// Повторное предъявление уже использованного токена означает его кражу:
+// все токены этого входа отзываются, и пользователю нужно войти заново
+func (uc *usecase) RefreshToken(refreshToken string) (tokens domain.Tokens, err error) {
+	token, err := uc.useRefreshToken(refreshToken)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	return uc.issueTokens(token.Email, token.Family, token.DeviceID)
+}
+
+// useRefreshToken помечает refresh token использованным и проверяет, что он действителен
+func (uc *usecase) useRefreshToken(refreshToken string) (domain.RefreshToken, error) {
+	token, err := uc.storage.UseRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		uc.logger.Debug("refresh token error", zap.Error(err))
+		return domain.RefreshToken{}, ErrInvalidRefreshToken
+	}
+	if token.Used {
+		uc.logger.Warn("refresh token reuse, revoking session", zap.String("email", token.Email))
+		if err = uc.storage.RevokeRefreshTokens(token.Email, token.Family); err != nil {
+			return domain.RefreshToken{}, err
+		}
+		return domain.RefreshToken{}, ErrInvalidRefreshToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return domain.RefreshToken{}, ErrInvalidRefreshToken
+	}
+	return token, nil
+}
+
+// Logout отзывает refresh token и все токены, полученные из него ротацией
+func (uc *usecase) Logout(refreshToken string) error {
+	token, err := uc.storage.UseRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidRefreshToken
+	}
+	return uc.storage.RevokeRefreshTokens(token.Email, token.Family)
+}
+
+// issueTokens выдает JWT и refresh token. Пустое family начинает новое семейство токенов
+func (uc *usecase) issueTokens(email string, family string, deviceID string) (domain.Tokens, error) {
+	access, err := genJWT(uc.secretKey, email, deviceID)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	if family == "" {
+		if family, err = randomToken(16); err != nil {
+			return domain.Tokens{}, err
+		}
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	err = uc.storage.SaveRefreshToken(hashToken(refresh), domain.RefreshToken{
+		Email:     email,
+		Family:    family,
+		DeviceID:  deviceID,
+		ExpiresAt: time.Now().Add(refreshTTL),
+	})
+	if err != nil {
+		return domain.Tokens{}, err
+	}
+	return domain.Tokens{Access: access, Refresh: refresh}, nil
+}
+
+// hashToken в хранилище попадают только хеши refresh token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,41 @@
+package usecase_test
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestRefreshToken(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 10)
+
+	tokens, err := uc.RegisterUser("test@test.ts", "pass")
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens.Access)
+	require.NotEmpty(t, tokens.Refresh)
+
+	rotated, err := uc.RefreshToken(tokens.Refresh)
+	require.NoError(t, err)
+	require.NotEqual(t, tokens.Refresh, rotated.Refresh)
+
+	// повторное использование старого токена отзывает всю цепочку
+	_, err = uc.RefreshToken(tokens.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+	_, err = uc.RefreshToken(rotated.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+
+	// другой вход не затронут, Logout отзывает его токены
+	other, err := uc.LoginUser("test@test.ts", "pass", "")
+	require.NoError(t, err)
+	require.NoError(t, uc.Logout(other.Refresh))
+	_, err = uc.RefreshToken(other.Refresh)
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+	_, err = uc.RefreshToken("unknown")
+	require.ErrorIs(t, err, usecase.ErrInvalidRefreshToken)
+}
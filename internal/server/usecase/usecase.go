@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
@@ -10,68 +11,88 @@ import (
 type storage interface {
 	RegisterUser(email string, hashedPassword []byte) (err error)
 	GetUserHashedPassword(email string) (hashedPassword []byte, err error)
+	UpdateUserPassword(email string, hashedPassword []byte) (err error)
+	DeleteUser(email string) (err error)
 	GetLastSyncTime(email string) (lastSync time.Time, err error)
 	SetLastSyncTime(email string, lastSync time.Time) (err error)
 	SetData(email string, data []byte) (err error)
 	GetData(email string) (data []byte, err error)
+	PushRecords(email string, deviceID string, records []domain.Record, keep int) (accepted []domain.Record, conflicts []domain.Record, revision int64, err error)
+	PullRecords(email string, since int64) (records []domain.Record, revision int64, err error)
+	SaveRefreshToken(hash string, token domain.RefreshToken) (err error)
+	UseRefreshToken(hash string) (token domain.RefreshToken, err error)
+	RevokeRefreshTokens(email string, family string) (err error)
+	RevokeDeviceTokens(email string, deviceID string) (err error)
+	SaveDevice(email string, device domain.Device) (err error)
+	GetDevice(email string, id string) (device domain.Device, err error)
+	TouchDevice(email string, id string, at time.Time) (err error)
+	ListDevices(email string) (devices []domain.Device, err error)
+	SaveChunk(email string, id string, data []byte) (err error)
+	GetChunk(email string, id string) (data []byte, err error)
+	MissingChunks(email string, ids []string) (missing []string, err error)
+	ListVersions(email string) (versions []domain.VaultVersion, err error)
+	GetVersion(email string, number int64) (version domain.VaultVersion, records []domain.Record, err error)
+	RekeyRecords(email string, deviceID string, revision int64, records []domain.Record, params domain.RecordKeyParams, keep int) (accepted []domain.Record, newRevision int64, err error)
+	SetRecordSalt(email string, salt []byte) (err error)
+	GetRecordKeyParams(email string) (params domain.RecordKeyParams, err error)
+	ReserveLoginAttempt(key string, at time.Time, resetBefore time.Time, allow func(domain.LoginAttempts) bool) (attempts domain.LoginAttempts, reserved bool, err error)
+	ReleaseLoginAttempt(key string) (err error)
+	GetLoginAttempts(key string) (attempts domain.LoginAttempts, err error)
+	ListLoginAttempts() (list []domain.LoginAttempts, err error)
+	DeleteLoginAttempts(key string) (err error)
 }
 
 type usecase struct {
 	storage   storage
 	logger    *zap.Logger
 	secretKey string
+	// history сколько версий хранилища хранить, 0 - не хранить
+	history int
+	// loginLimit ограничение неудачных попыток входа
+	loginLimit LoginLimit
 }
 
-func New(s storage, lg *zap.Logger, secret string) *usecase {
+func New(s storage, lg *zap.Logger, secret string, history int) *usecase {
 	return &usecase{
-		storage:   s,
-		logger:    lg,
-		secretKey: secret,
+		storage:    s,
+		logger:     lg,
+		secretKey:  secret,
+		history:    history,
+		loginLimit: DefaultLoginLimit,
 	}
 }
 
-func (uc *usecase) RegisterUser(email string, password string) (token string, err error) {
+func (uc *usecase) RegisterUser(email string, password string) (tokens domain.Tokens, err error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		uc.logger.Debug("genToken error", zap.Error(err))
-		return "", err
+		return domain.Tokens{}, err
 	}
 	err = uc.storage.RegisterUser(email, hashedPassword)
 	if err != nil {
 		uc.logger.Debug("Register error", zap.Error(err))
-		return "", err
+		return domain.Tokens{}, err
 	}
 	err = uc.storage.SetLastSyncTime(email, time.Now())
 	if err != nil {
 		uc.logger.Debug("Set Last Sync error", zap.Error(err))
-		return "", err
+		return domain.Tokens{}, err
 	}
+	err = uc.setRecordSalt(email)
 	if err != nil {
-		uc.logger.Debug("Register error", zap.Error(err))
-		return "", err
-	}
-	token, err = genJWT(uc.secretKey, email)
-	if err != nil {
-		uc.logger.Debug("genToken error", zap.Error(err))
-		return "", err
+		uc.logger.Debug("Set record salt error", zap.Error(err))
+		return domain.Tokens{}, err
 	}
-	return token, err
-}
-
-func (uc *usecase) LoginUser(email string, password string) (token string, err error) {
-	hash, err := uc.storage.GetUserHashedPassword(email)
 	if err != nil {
-		return "", err
-	}
-	err = bcrypt.CompareHashAndPassword(hash, []byte(password))
-	if err != nil {
-		return "", err
+		uc.logger.Debug("Register error", zap.Error(err))
+		return domain.Tokens{}, err
 	}
-	token, err = genJWT(uc.secretKey, email)
+	tokens, err = uc.issueTokens(email, "", "")
 	if err != nil {
-		return "", err
+		uc.logger.Debug("genToken error", zap.Error(err))
+		return domain.Tokens{}, err
 	}
-	return token, err
+	return tokens, err
 }
 
 func (uc *usecase) GetLastSyncTime(email string) (lastSync time.Time, err error) {
@@ -91,11 +112,37 @@ func (uc *usecase) GetData(email string) (data []byte, err error) {
 	return uc.storage.GetData(email)
 }
 
-func genJWT(secretKey string, email string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// PushRecords сохраняет измененные записи пользователя и, в той же транзакции, новую версию хранилища.
+// Обновляет время синхронизации
+func (uc *usecase) PushRecords(email string, deviceID string, records []domain.Record) (accepted []domain.Record, conflicts []domain.Record, revision int64, err error) {
+	if err = checkChunks(records); err != nil {
+		return nil, nil, 0, err
+	}
+	accepted, conflicts, revision, err = uc.storage.PushRecords(email, deviceID, records, uc.history)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if len(accepted) > 0 {
+		err = uc.storage.SetLastSyncTime(email, time.Now())
+	}
+	return
+}
+
+// PullRecords возвращает записи пользователя, измененные после ревизии since
+func (uc *usecase) PullRecords(email string, since int64) (records []domain.Record, revision int64, err error) {
+	return uc.storage.PullRecords(email, since)
+}
+
+// genJWT выдает токен на час. Токен, выданный после регистрации устройства, содержит его ID
+func genJWT(secretKey string, email string, deviceID string) (string, error) {
+	claims := jwt.MapClaims{
 		"email": email,
 		"exp":   time.Now().Add(time.Hour).Unix(),
-	})
+	}
+	if deviceID != "" {
+		claims["device"] = deviceID
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(secretKey))
 	return tokenString, err
 }
@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+)
+
+// ListVersions возвращает сохраненные версии хранилища пользователя, новые первыми, с именами устройств
+func (uc *usecase) ListVersions(email string) ([]domain.VaultVersion, error) {
+	versions, err := uc.storage.ListVersions(email)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := uc.storage.ListDevices(email)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(devices))
+	for _, d := range devices {
+		names[d.ID] = d.Name
+	}
+	for i := range versions {
+		versions[i].DeviceName = names[versions[i].DeviceID]
+	}
+	return versions, nil
+}
+
+// GetVersion возвращает версию хранилища и ее записи
+func (uc *usecase) GetVersion(email string, number int64) (domain.VaultVersion, []domain.Record, error) {
+	return uc.storage.GetVersion(email, number)
+}
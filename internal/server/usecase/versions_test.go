@@ -0,0 +1,119 @@
+package usecase_test
+
+import (
+	"fmt"
+	"github.com/Spear5030/yagophkeeper/internal/domain"
+	"github.com/Spear5030/yagophkeeper/internal/server/storage"
+	"github.com/Spear5030/yagophkeeper/internal/server/usecase"
+	"github.com/Spear5030/yagophkeeper/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 2)
+	email := "test@test.ts"
+
+	login, err := uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+	laptop, _, err := uc.RegisterDevice(email, "laptop", login.Refresh)
+	require.NoError(t, err)
+
+	_, _, _, err = uc.PushRecords(email, laptop, []domain.Record{{ID: "a", Data: []byte("a1")}, {ID: "b", Data: []byte("b1")}})
+	require.NoError(t, err)
+	_, _, _, err = uc.PushRecords(email, laptop, []domain.Record{{ID: "b", Revision: 2, Deleted: true}})
+	require.NoError(t, err)
+	// отправка без принятых записей версию не создает
+	_, conflicts, _, err := uc.PushRecords(email, laptop, []domain.Record{{ID: "a", Revision: 0, Data: []byte("a2")}})
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+
+	versions, err := uc.ListVersions(email)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, int64(3), versions[0].Number)
+	require.Equal(t, int64(1), versions[0].Records)
+	require.Equal(t, "laptop", versions[0].DeviceName)
+	require.Equal(t, int64(2), versions[1].Records)
+
+	// удаленная запись есть только в старой версии
+	_, records, err := uc.GetVersion(email, 2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	_, records, err = uc.GetVersion(email, 3)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Record{{ID: "a", Revision: 1, Data: []byte("a1")}}, records)
+
+	_, _, _, err = uc.PushRecords(email, laptop, []domain.Record{{ID: "a", Revision: 1, Data: []byte("a2")}})
+	require.NoError(t, err)
+	versions, err = uc.ListVersions(email)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	_, _, err = uc.GetVersion(email, 2)
+	require.ErrorIs(t, err, storage.ErrVersionNotFound)
+}
+
+// TestVersionsConcurrentPush каждая версия после параллельных отправок с разных устройств
+// восстанавливает ровно то, что было на сервере на ее ревизии
+func TestVersionsConcurrentPush(t *testing.T) {
+	lg, _ := logger.New(true)
+	s, err := storage.New(filepath.Join(t.TempDir(), "test.pbb"), lg)
+	require.NoError(t, err)
+	uc := usecase.New(s, lg, "secret", 100)
+	email := "test@test.ts"
+	_, err = uc.RegisterUser(email, "pass")
+	require.NoError(t, err)
+
+	const devices, pushes = 4, 5
+	// history[id] принятые значения записи id по ревизиям
+	history := make([]map[int64]string, devices)
+	var wg sync.WaitGroup
+	for d := 0; d < devices; d++ {
+		d := d
+		history[d] = make(map[int64]string)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("r%d", d)
+			var revision int64
+			for i := 0; i < pushes; i++ {
+				data := fmt.Sprintf("%s-%d", id, i)
+				accepted, conflicts, _, err := uc.PushRecords(email, id, []domain.Record{{ID: id, Revision: revision, Data: []byte(data)}})
+				if !assert.NoError(t, err) || !assert.Empty(t, conflicts) || !assert.Len(t, accepted, 1) {
+					return
+				}
+				revision = accepted[0].Revision
+				history[d][revision] = data
+			}
+		}()
+	}
+	wg.Wait()
+
+	versions, err := uc.ListVersions(email)
+	require.NoError(t, err)
+	require.Len(t, versions, devices*pushes)
+	for _, version := range versions {
+		var want []domain.Record
+		for d := 0; d < devices; d++ {
+			var last int64
+			for revision := range history[d] {
+				if revision <= version.Number && revision > last {
+					last = revision
+				}
+			}
+			if last > 0 {
+				want = append(want, domain.Record{ID: fmt.Sprintf("r%d", d), Revision: last, Data: []byte(history[d][last])})
+			}
+		}
+		_, records, err := uc.GetVersion(email, version.Number)
+		require.NoError(t, err)
+		require.Equal(t, want, records, "version %d", version.Number)
+		require.Equal(t, int64(len(want)), version.Records)
+	}
+}
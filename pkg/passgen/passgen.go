@@ -0,0 +1,166 @@
+// Package passgen генерация паролей и diceware-фраз на crypto/rand
+package passgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	lower     = "abcdefghijklmnopqrstuvwxyz"
+	upper     = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits    = "0123456789"
+	symbols   = "!#$%&*+-.:;=?@^_~"
+	ambiguous = "Il1O0o"
+
+	// DefaultSeparator разделитель слов фразы по умолчанию
+	DefaultSeparator = "-"
+	// MaxLength ограничение длины пароля и количества слов
+	MaxLength = 1024
+)
+
+var (
+	ErrNoClasses     = errors.New("no character classes selected")
+	ErrInvalidLength = errors.New("invalid password length")
+)
+
+//go:embed eff_large_wordlist.txt
+var wordlistData string
+
+// words список EFF large wordlist, 7776 слов
+var words = parseWordlist(wordlistData)
+
+// Policy параметры генерации. При Words > 0 генерируется diceware-фраза,
+// остальные поля кроме Separator игнорируются
+type Policy struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+	Words            int
+	Separator        string
+}
+
+// Validate проверяет политику
+func (p Policy) Validate() error {
+	if p.Words > 0 {
+		if p.Words > MaxLength {
+			return ErrInvalidLength
+		}
+		return nil
+	}
+	classes := p.classes()
+	if len(classes) == 0 {
+		return ErrNoClasses
+	}
+	if p.Length < len(classes) || p.Length > MaxLength {
+		return ErrInvalidLength
+	}
+	return nil
+}
+
+// Generate генерирует пароль по политике. В пароле есть хотя бы один символ каждого выбранного класса
+func Generate(p Policy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	if p.Words > 0 {
+		return passphrase(p.Words, p.Separator)
+	}
+
+	classes := p.classes()
+	all := strings.Join(classes, "")
+	password := make([]byte, 0, p.Length)
+	for _, class := range classes {
+		c, err := pick(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < p.Length {
+		c, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	// перемешиваем, чтобы обязательные символы не стояли в начале
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+func (p Policy) classes() []string {
+	var classes []string
+	for _, c := range []struct {
+		enabled bool
+		chars   string
+	}{{p.Lower, lower}, {p.Upper, upper}, {p.Digits, digits}, {p.Symbols, symbols}} {
+		if !c.enabled {
+			continue
+		}
+		if p.ExcludeAmbiguous {
+			c.chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguous, r) {
+					return -1
+				}
+				return r
+			}, c.chars)
+		}
+		classes = append(classes, c.chars)
+	}
+	return classes
+}
+
+func passphrase(n int, separator string) (string, error) {
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+	phrase := make([]string, n)
+	for i := range phrase {
+		j, err := randInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		phrase[i] = words[j]
+	}
+	return strings.Join(phrase, separator), nil
+}
+
+func pick(chars string) (byte, error) {
+	i, err := randInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+func randInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// parseWordlist разбирает строки вида "11111\tword"
+func parseWordlist(data string) []string {
+	var list []string
+	for _, line := range strings.Split(data, "\n") {
+		if _, word, ok := strings.Cut(line, "\t"); ok {
+			list = append(list, strings.TrimSpace(word))
+		}
+	}
+	return list
+}
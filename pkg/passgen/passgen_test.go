@@ -0,0 +1,51 @@
+package passgen
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestWordlist(t *testing.T) {
+	require.Len(t, words, 7776)
+	require.Equal(t, "abacus", words[0])
+	require.Equal(t, "zoom", words[len(words)-1])
+}
+
+func TestGenerate(t *testing.T) {
+	p := Policy{Length: 4, Lower: true, Upper: true, Digits: true, Symbols: true}
+	for i := 0; i < 100; i++ {
+		password, err := Generate(p)
+		require.NoError(t, err)
+		require.Len(t, password, 4)
+		for _, class := range []string{lower, upper, digits, symbols} {
+			require.True(t, strings.ContainsAny(password, class), "%q has no chars from %q", password, class)
+		}
+	}
+}
+
+func TestGenerateExcludeAmbiguous(t *testing.T) {
+	p := Policy{Length: 200, Lower: true, Upper: true, Digits: true, ExcludeAmbiguous: true}
+	password, err := Generate(p)
+	require.NoError(t, err)
+	require.False(t, strings.ContainsAny(password, ambiguous), password)
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	phrase, err := Generate(Policy{Words: 5, Separator: " "})
+	require.NoError(t, err)
+	require.Len(t, strings.Split(phrase, " "), 5)
+
+	phrase, err = Generate(Policy{Words: 3})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, strings.Count(phrase, DefaultSeparator), 2)
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	_, err := Generate(Policy{Length: 10})
+	require.ErrorIs(t, err, ErrNoClasses)
+	_, err = Generate(Policy{Length: 2, Lower: true, Upper: true, Digits: true})
+	require.ErrorIs(t, err, ErrInvalidLength)
+	_, err = Generate(Policy{Length: MaxLength + 1, Lower: true})
+	require.ErrorIs(t, err, ErrInvalidLength)
+}
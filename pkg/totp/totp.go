@@ -0,0 +1,153 @@
+// Package totp генерация одноразовых паролей по RFC 6238 и разбор otpauth:// URI
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAlgorithm = "SHA1"
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+var (
+	ErrInvalidURI    = errors.New("invalid otpauth uri")
+	ErrInvalidSecret = errors.New("invalid base32 secret")
+)
+
+// Key параметры TOTP. Secret в base32, как в otpauth URI
+type Key struct {
+	Issuer    string
+	Account   string
+	Secret    string
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// ParseURI разбирает URI вида otpauth://totp/Issuer:account?secret=...&issuer=...&algorithm=...&digits=...&period=...
+func ParseURI(uri string) (Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+	if u.Scheme != "otpauth" || !strings.EqualFold(u.Host, "totp") {
+		return Key{}, fmt.Errorf("%w: only otpauth://totp is supported", ErrInvalidURI)
+	}
+	q := u.Query()
+	k := Key{
+		Secret:    q.Get("secret"),
+		Issuer:    q.Get("issuer"),
+		Algorithm: strings.ToUpper(q.Get("algorithm")),
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Account = strings.TrimSpace(account)
+		if k.Issuer == "" {
+			k.Issuer = issuer
+		}
+	} else {
+		k.Account = label
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil {
+			return Key{}, fmt.Errorf("%w: digits %q", ErrInvalidURI, v)
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		if k.Period, err = strconv.Atoi(v); err != nil {
+			return Key{}, fmt.Errorf("%w: period %q", ErrInvalidURI, v)
+		}
+	}
+	if k.Algorithm == "" {
+		k.Algorithm = DefaultAlgorithm
+	}
+	return k, k.Validate()
+}
+
+// Validate проверяет параметры ключа
+func (k Key) Validate() error {
+	if _, err := k.secret(); err != nil {
+		return err
+	}
+	if _, err := k.hash(); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 10 {
+		return fmt.Errorf("unsupported digits %d: must be 6..10", k.Digits)
+	}
+	if k.Period <= 0 {
+		return fmt.Errorf("invalid period %d", k.Period)
+	}
+	return nil
+}
+
+// Code возвращает код для момента t и время до его смены
+func (k Key) Code(t time.Time) (string, time.Duration, error) {
+	if err := k.Validate(); err != nil {
+		return "", 0, err
+	}
+	secret, _ := k.secret()
+	h, _ := k.hash()
+	period := int64(k.Period)
+	counter := t.Unix() / period
+	remaining := time.Duration(period-t.Unix()%period) * time.Second
+	return hotp(h, secret, uint64(counter), k.Digits), remaining, nil
+}
+
+// hotp алгоритм RFC 4226 с динамическим усечением
+func hotp(h func() hash.Hash, secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := int64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := int64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// secret раскодирует base32 секрет. Пробелы, регистр и отсутствие padding допускаются
+func (k Key) secret() ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(k.Secret, " ", ""))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, ErrInvalidSecret
+	}
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return b, nil
+}
+
+func (k Key) hash() (func() hash.Hash, error) {
+	switch strings.ToUpper(k.Algorithm) {
+	case "", "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+}
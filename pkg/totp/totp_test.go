@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestCodeRFC6238 тестовые векторы из приложения B RFC 6238
+func TestCodeRFC6238(t *testing.T) {
+	seeds := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{"SHA1": "94287082", "SHA256": "46119246", "SHA512": "90693936"}},
+		{1111111109, map[string]string{"SHA1": "07081804", "SHA256": "68084774", "SHA512": "25091201"}},
+		{1111111111, map[string]string{"SHA1": "14050471", "SHA256": "67062674", "SHA512": "99943326"}},
+		{1234567890, map[string]string{"SHA1": "89005924", "SHA256": "91819424", "SHA512": "93441116"}},
+		{2000000000, map[string]string{"SHA1": "69279037", "SHA256": "90698825", "SHA512": "38618901"}},
+		{20000000000, map[string]string{"SHA1": "65353130", "SHA256": "77737706", "SHA512": "47863826"}},
+	}
+	for _, tt := range tests {
+		for alg, want := range tt.want {
+			k := Key{
+				Secret:    base32.StdEncoding.EncodeToString([]byte(seeds[alg])),
+				Algorithm: alg,
+				Digits:    8,
+				Period:    30,
+			}
+			code, _, err := k.Code(time.Unix(tt.unix, 0))
+			require.NoError(t, err)
+			require.Equal(t, want, code, "%s at %d", alg, tt.unix)
+		}
+	}
+}
+
+func TestCodeRemaining(t *testing.T) {
+	k := Key{Secret: "JBSWY3DPEHPK3PXP", Digits: 6, Period: 30}
+	code, remaining, err := k.Code(time.Unix(65, 0))
+	require.NoError(t, err)
+	require.Len(t, code, 6)
+	require.Equal(t, 25*time.Second, remaining)
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    Key
+		wantErr bool
+	}{
+		{
+			name: "full",
+			uri:  "otpauth://totp/ACME%20Co:john@example.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60",
+			want: Key{Issuer: "ACME Co", Account: "john@example.com", Secret: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ", Algorithm: "SHA256", Digits: 8, Period: 60},
+		},
+		{
+			name: "defaults and issuer from label",
+			uri:  "otpauth://totp/GitHub:octocat?secret=jbswy3dpehpk3pxp",
+			want: Key{Issuer: "GitHub", Account: "octocat", Secret: "jbswy3dpehpk3pxp", Algorithm: "SHA1", Digits: 6, Period: 30},
+		},
+		{name: "hotp", uri: "otpauth://hotp/x?secret=JBSWY3DPEHPK3PXP&counter=1", wantErr: true},
+		{name: "no secret", uri: "otpauth://totp/x", wantErr: true},
+		{name: "bad secret", uri: "otpauth://totp/x?secret=1111", wantErr: true},
+		{name: "bad algorithm", uri: "otpauth://totp/x?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", wantErr: true},
+		{name: "bad scheme", uri: "https://totp/x?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseURI(tt.uri)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, k)
+		})
+	}
+}